@@ -395,3 +395,64 @@ func BenchmarkQuery(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkStructCodec(b *testing.B) {
+	codec := msgpack.NewCodec[benchmarkStruct]()
+	in := structForBenchmark()
+	out := new(benchmarkStruct)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		buf, err := codec.Marshal(*in)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		err = codec.Unmarshal(buf, out)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStructAppendMarshal(b *testing.B) {
+	in := structForBenchmark()
+	buf := make([]byte, 0, 4096)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var err error
+		buf, err = msgpack.AppendMarshal(buf[:0], in)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSliceAppendMarshal(b *testing.B) {
+	in := make([]int64, 1000)
+	for i := range in {
+		in[i] = int64(i) * 1000
+	}
+	buf := make([]byte, 0, 8192)
+
+	b.Run("Marshal", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := msgpack.Marshal(in); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("AppendMarshal", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var err error
+			buf, err = msgpack.AppendMarshal(buf[:0], in)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func marshalCanonical(t *testing.T, v interface{}) []byte {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseCanonicalEncoding(true)
+	require.Nil(t, enc.Encode(v))
+	return buf.Bytes()
+}
+
+type canonicalItem struct {
+	Zeta  int
+	B     string
+	Alpha map[int]bool
+}
+
+func TestCanonicalMaps(t *testing.T) {
+	m := map[int]string{}
+	for i := -100; i < 100; i++ {
+		m[i] = "x"
+	}
+	want := marshalCanonical(t, m)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, want, marshalCanonical(t, m))
+	}
+
+	b := marshalCanonical(t, map[string]interface{}{
+		"bb": 1,
+		"a":  map[interface{}]interface{}{int8(2): true, "k": false, int64(1): nil},
+	})
+	require.Equal(t, []byte{
+		0x82,
+		0xa1, 'a', 0x83, 0x01, 0xc0, 0x02, 0xc3, 0xa1, 'k', 0xc2,
+		0xa2, 'b', 'b', 0x01,
+	}, b)
+
+	m2 := map[string]string{"ccc": "", "dd": "", "e": ""}
+	require.Equal(t, []byte{
+		0x83, 0xa1, 'e', 0xa0, 0xa2, 'd', 'd', 0xa0, 0xa3, 'c', 'c', 'c', 0xa0,
+	}, marshalCanonical(t, m2))
+}
+
+func TestCanonicalStruct(t *testing.T) {
+	v := canonicalItem{Zeta: 1, B: "b", Alpha: map[int]bool{2: true, 1: false}}
+	b := marshalCanonical(t, v)
+
+	// Structs are encoded like maps with the same content.
+	require.Equal(t, marshalCanonical(t, map[string]interface{}{
+		"Zeta":  1,
+		"B":     "b",
+		"Alpha": map[int]bool{1: false, 2: true},
+	}), b)
+
+	var out canonicalItem
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, v, out)
+}
+
+func TestCanonicalNumbers(t *testing.T) {
+	require.Equal(t, []byte{0x01}, marshalCanonical(t, int64(1)))
+	require.Equal(t, []byte{0xcd, 0x01, 0x00}, marshalCanonical(t, uint32(256)))
+	require.Equal(t, []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, marshalCanonical(t, 1.5))
+	require.Equal(t, []byte{0xcb, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a},
+		marshalCanonical(t, 0.1))
+
+	nan := []byte{0xca, 0x7f, 0xc0, 0x00, 0x00}
+	require.Equal(t, nan, marshalCanonical(t, math.NaN()))
+	require.Equal(t, nan, marshalCanonical(t, math.Float64frombits(0x7ff8000000000001)))
+	require.Equal(t, nan, marshalCanonical(t, float32(math.NaN())))
+}
+
+func TestCanonicalDuplicateKeys(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseCanonicalEncoding(true)
+	err := enc.Encode(map[interface{}]bool{int8(1): true, int64(1): false})
+	require.EqualError(t, err,
+		"msgpack: map[interface {}]bool has keys with the same encoding 01")
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func runToJSON(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	config := jsonFlags(fs, true)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := config()
+	if err != nil {
+		return err
+	}
+
+	r, closeInput, err := openInput(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	return cfg.ToJSON(stdout, r)
+}
+
+func runFromJSON(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	config := jsonFlags(fs, true)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := config()
+	if err != nil {
+		return err
+	}
+
+	r, closeInput, err := openInput(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	return cfg.FromJSON(stdout, r)
+}
+
+func runQuery(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	config := jsonFlags(fs, false)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := config()
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	query := fs.Arg(0)
+	if _, err := msgpack.CompileQuery(query); err != nil {
+		return err
+	}
+
+	r, closeInput, err := openInput(fs.Args()[1:], stdin)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	w := bufio.NewWriter(stdout)
+	defer w.Flush()
+
+	// Query may stop in the middle of a value, so every value
+	// is read whole first and queried separately.
+	sub := msgpack.NewDecoder(nil)
+	err = eachValue(msgpack.NewDecoder(r), func(raw msgpack.RawMessage) error {
+		sub.ResetBytes(raw, true)
+		values, err := sub.QueryRaw(query)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			if err := cfg.ToJSON(w, bytes.NewReader(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func runValidate(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	quiet := fs.Bool("q", false, "do not print the number of values")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r, closeInput, err := openInput(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	var n int
+	dec := msgpack.NewDecoder(r)
+	for {
+		err := dec.Validate()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		n++
+	}
+
+	if !*quiet {
+		fmt.Fprintf(stdout, "%d values\n", n)
+	}
+	return nil
+}
+
+func runCat(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	skip := fs.Int("skip", 0, "number of values to skip")
+	limit := fs.Int("n", -1, "maximum number of values to copy, negative means all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r, closeInput, err := openInput(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	w := bufio.NewWriter(stdout)
+	defer w.Flush()
+
+	var i int
+	errStop := errors.New("stop")
+	err = eachValue(msgpack.NewDecoder(r), func(raw msgpack.RawMessage) error {
+		i++
+		if i <= *skip {
+			return nil
+		}
+		if *limit >= 0 && i > *skip+*limit {
+			return errStop
+		}
+		_, err := w.Write(raw)
+		return err
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return err
+	}
+	return w.Flush()
+}
+
+// eachValue calls fn with every value in the stream. Errors are annotated
+// with the index and the offset of the value.
+func eachValue(d *msgpack.Decoder, fn func(raw msgpack.RawMessage) error) error {
+	for i := 0; ; i++ {
+		if _, err := d.PeekCode(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		offset := d.InputOffset()
+		raw, err := d.DecodeRaw()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return fmt.Errorf("value %d at offset %d: %w", i, offset, err)
+		}
+
+		if err := fn(raw); err != nil {
+			return fmt.Errorf("value %d at offset %d: %w", i, offset, err)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+const maxDumpDepth = 10000
+
+func runDump(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error {
+	width := fs.Int("width", 8, "maximum number of bytes printed on a line")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r, closeInput, err := openInput(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(stdout)
+	defer w.Flush()
+
+	d := &dumper{
+		w:     w,
+		b:     b,
+		width: *width,
+	}
+	for d.pos < len(d.b) {
+		if err := d.value(); err != nil {
+			w.Flush()
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// dumper prints a line for every value with its offset, the leading bytes
+// and a description of the msgpcode and its payload. Arrays and maps
+// are followed by their indented elements.
+type dumper struct {
+	w     io.Writer
+	b     []byte
+	pos   int
+	width int
+	depth int
+}
+
+func (d *dumper) line(start int, desc string) {
+	n := d.pos - start
+	if n > d.width {
+		n = d.width
+	}
+
+	hx := hex.EncodeToString(d.b[start : start+n])
+	var sb strings.Builder
+	for i := 0; i < len(hx); i += 2 {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(hx[i : i+2])
+	}
+	if d.pos-start > n {
+		sb.WriteString(" ..")
+	}
+
+	fmt.Fprintf(d.w, "%08x  %-*s  %s%s\n",
+		start, 3*d.width+2, sb.String(), strings.Repeat("  ", d.depth), desc)
+}
+
+func (d *dumper) read(start, n int) ([]byte, error) {
+	if n < 0 || len(d.b)-d.pos < n {
+		return nil, fmt.Errorf("value at offset %d: %w", start, io.ErrUnexpectedEOF)
+	}
+	b := d.b[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *dumper) uint(start, size int) (uint64, error) {
+	b, err := d.read(start, size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *dumper) value() error {
+	start := d.pos
+	c := d.b[d.pos]
+	d.pos++
+
+	switch {
+	case msgpcode.IsFixedNum(c):
+		d.line(start, fmt.Sprintf("fixint %d", int8(c)))
+		return nil
+	case msgpcode.IsFixedMap(c):
+		return d.container(start, "fixmap", int(c&msgpcode.FixedMapMask), 2)
+	case msgpcode.IsFixedArray(c):
+		return d.container(start, "fixarray", int(c&msgpcode.FixedArrayMask), 1)
+	case msgpcode.IsFixedString(c):
+		return d.str(start, "fixstr", int(c&msgpcode.FixedStrMask))
+	}
+
+	switch c {
+	case msgpcode.Nil:
+		d.line(start, "nil")
+		return nil
+	case msgpcode.False:
+		d.line(start, "false")
+		return nil
+	case msgpcode.True:
+		d.line(start, "true")
+		return nil
+	case msgpcode.Uint8, msgpcode.Uint16, msgpcode.Uint32, msgpcode.Uint64:
+		size := 1 << (c - msgpcode.Uint8)
+		n, err := d.uint(start, size)
+		if err != nil {
+			return err
+		}
+		d.line(start, fmt.Sprintf("uint%d %d", size*8, n))
+		return nil
+	case msgpcode.Int8, msgpcode.Int16, msgpcode.Int32, msgpcode.Int64:
+		size := 1 << (c - msgpcode.Int8)
+		n, err := d.uint(start, size)
+		if err != nil {
+			return err
+		}
+		shift := 64 - size*8
+		d.line(start, fmt.Sprintf("int%d %d", size*8, int64(n<<shift)>>shift))
+		return nil
+	case msgpcode.Float:
+		n, err := d.uint(start, 4)
+		if err != nil {
+			return err
+		}
+		f := math.Float32frombits(uint32(n))
+		d.line(start, "float32 "+strconv.FormatFloat(float64(f), 'g', -1, 32))
+		return nil
+	case msgpcode.Double:
+		n, err := d.uint(start, 8)
+		if err != nil {
+			return err
+		}
+		d.line(start, "float64 "+strconv.FormatFloat(math.Float64frombits(n), 'g', -1, 64))
+		return nil
+	case msgpcode.Str8, msgpcode.Str16, msgpcode.Str32:
+		name, size := sizedName("str", c-msgpcode.Str8)
+		n, err := d.uint(start, size)
+		if err != nil {
+			return err
+		}
+		return d.str(start, name, int(n))
+	case msgpcode.Bin8, msgpcode.Bin16, msgpcode.Bin32:
+		name, size := sizedName("bin", c-msgpcode.Bin8)
+		n, err := d.uint(start, size)
+		if err != nil {
+			return err
+		}
+		if _, err := d.read(start, int(n)); err != nil {
+			return err
+		}
+		d.line(start, fmt.Sprintf("%s len=%d", name, n))
+		return nil
+	case msgpcode.Array16, msgpcode.Array32:
+		name, size := sizedName("array", (c-msgpcode.Array16)+1)
+		n, err := d.uint(start, size)
+		if err != nil {
+			return err
+		}
+		return d.container(start, name, int(n), 1)
+	case msgpcode.Map16, msgpcode.Map32:
+		name, size := sizedName("map", (c-msgpcode.Map16)+1)
+		n, err := d.uint(start, size)
+		if err != nil {
+			return err
+		}
+		return d.container(start, name, int(n), 2)
+	case msgpcode.FixExt1, msgpcode.FixExt2, msgpcode.FixExt4, msgpcode.FixExt8, msgpcode.FixExt16:
+		n := 1 << (c - msgpcode.FixExt1)
+		return d.ext(start, "fixext"+strconv.Itoa(n), n)
+	case msgpcode.Ext8, msgpcode.Ext16, msgpcode.Ext32:
+		name, size := sizedName("ext", c-msgpcode.Ext8)
+		n, err := d.uint(start, size)
+		if err != nil {
+			return err
+		}
+		return d.ext(start, name, int(n))
+	}
+
+	d.line(start, "never used")
+	return fmt.Errorf("invalid code=%x at offset %d", c, start)
+}
+
+// sizedName returns the name of the 8, 16 or 32 bit variant of the code
+// and the size of its length in bytes.
+func sizedName(name string, i byte) (string, int) {
+	size := 1 << i
+	return name + strconv.Itoa(size*8), size
+}
+
+func (d *dumper) str(start int, name string, n int) error {
+	b, err := d.read(start, n)
+	if err != nil {
+		return err
+	}
+
+	const maxQuoted = 64
+	s := string(b)
+	var suffix string
+	if len(s) > maxQuoted {
+		s = s[:maxQuoted]
+		suffix = "..."
+	}
+	d.line(start, fmt.Sprintf("%s len=%d %s%s", name, n, strconv.Quote(s), suffix))
+	return nil
+}
+
+func (d *dumper) ext(start int, name string, n int) error {
+	typ, err := d.read(start, 1)
+	if err != nil {
+		return err
+	}
+	id := int8(typ[0])
+	if id == msgpack.TypeNameExtID {
+		return d.typeName(start, name, n)
+	}
+	data, err := d.read(start, n)
+	if err != nil {
+		return err
+	}
+
+	desc := fmt.Sprintf("%s type=%d len=%d", name, id, n)
+	switch id {
+	case msgpack.TimeExtID, msgpack.TimeZoneExtID:
+		tm, err := msgpack.DecodeTimeExt(id, data)
+		if err != nil {
+			break
+		}
+		// The zone ext starts with the zone kind, which is 0 for UTC.
+		if id == msgpack.TimeExtID || data[0] == 0 {
+			desc += " timestamp " + tm.UTC().Format(time.RFC3339Nano)
+		} else {
+			zone := string(data[6 : 6+int(data[5])])
+			desc += fmt.Sprintf(" timestamp %s zone=%q", tm.Format(time.RFC3339Nano), zone)
+		}
+	case msgpack.InternedStringExtID:
+		switch n {
+		case 1, 2, 4:
+			idx, _ := (&dumper{b: data}).uint(0, n)
+			desc += fmt.Sprintf(" interned string index=%d", idx)
+		}
+	}
+	d.line(start, desc)
+	return nil
+}
+
+// typeName prints the header of a value wrapped by Encoder.UseTypeNames
+// followed by the indented type name and value.
+func (d *dumper) typeName(start int, name string, n int) error {
+	d.line(start, fmt.Sprintf("%s type=%d len=%d type name", name, msgpack.TypeNameExtID, n))
+
+	if len(d.b)-d.pos < n {
+		return fmt.Errorf("value at offset %d: %w", start, io.ErrUnexpectedEOF)
+	}
+	if d.depth >= maxDumpDepth {
+		return fmt.Errorf("value at offset %d: exceeded max depth of %d", start, maxDumpDepth)
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	end := d.pos + n
+	for i := 0; i < 2 && d.pos < end; i++ {
+		if err := d.value(); err != nil {
+			return err
+		}
+	}
+	if d.pos != end {
+		return fmt.Errorf("%s at offset %d: invalid type name length=%d", name, start, n)
+	}
+	return nil
+}
+
+// container prints the header of an array or a map followed by
+// its n entries, each consisting of per values.
+func (d *dumper) container(start int, name string, n, per int) error {
+	d.line(start, fmt.Sprintf("%s len=%d", name, n))
+
+	if d.depth >= maxDumpDepth {
+		return fmt.Errorf("value at offset %d: exceeded max depth of %d", start, maxDumpDepth)
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	for i := 0; i < n*per; i++ {
+		if d.pos >= len(d.b) {
+			return fmt.Errorf("%s at offset %d: %w", name, start, io.ErrUnexpectedEOF)
+		}
+		if err := d.value(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Command msgpack inspects and converts MessagePack data.
+//
+// Usage:
+//
+//	msgpack <command> [flags] [file ...]
+//
+// The commands are:
+//
+//	dump      print an annotated hex view of every value
+//	tojson    convert MessagePack values to JSON, one value per line
+//	fromjson  convert JSON values to MessagePack
+//	query     print the values matching a Decoder.Query path as JSON
+//	validate  check that the input is a well-formed stream of values
+//	cat       copy a range of values from concatenated streams
+//
+// Input is read from the named files, concatenated, or from the standard
+// input when no file is given. Run "msgpack <command> -h" for the flags
+// of a command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5/msgpjson"
+)
+
+type command struct {
+	name  string
+	args  string
+	short string
+	run   func(fs *flag.FlagSet, args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = []*command{
+	{"dump", "[file ...]", "print an annotated hex view of every value", runDump},
+	{"tojson", "[file ...]", "convert MessagePack values to JSON, one value per line", runToJSON},
+	{"fromjson", "[file ...]", "convert JSON values to MessagePack", runFromJSON},
+	{"query", "query [file ...]", "print the values matching a Decoder.Query path as JSON", runQuery},
+	{"validate", "[file ...]", "check that the input is a well-formed stream of values", runValidate},
+	{"cat", "[file ...]", "copy a range of values from concatenated streams", runCat},
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		// Errors returned by the library are already prefixed.
+		msg := err.Error()
+		if !strings.HasPrefix(msg, "msgpack: ") {
+			msg = "msgpack: " + msg
+		}
+		fmt.Fprintln(os.Stderr, msg)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		usage(stderr)
+		return flag.ErrHelp
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprintf(stderr, "Usage: msgpack %s [flags] %s\n\n%s%s.\n",
+				cmd.name, cmd.args, strings.ToUpper(cmd.short[:1]), cmd.short[1:])
+			var hasFlags bool
+			fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+			if hasFlags {
+				fmt.Fprintf(stderr, "\nFlags:\n")
+				fs.PrintDefaults()
+			}
+		}
+		return cmd.run(fs, args[1:], stdin, stdout)
+	}
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		return flag.ErrHelp
+	}
+	return fmt.Errorf("unknown command %q, run \"msgpack help\"", args[0])
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: msgpack <command> [flags] [file ...]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.short)
+	}
+}
+
+// openInput returns the concatenation of the named files
+// or stdin if no file is named.
+func openInput(names []string, stdin io.Reader) (io.Reader, func(), error) {
+	if len(names) == 0 {
+		return stdin, func() {}, nil
+	}
+
+	files := make([]*os.File, 0, len(names))
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	readers := make([]io.Reader, 0, len(names))
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		files = append(files, f)
+		readers = append(readers, f)
+	}
+	return io.MultiReader(readers...), closeAll, nil
+}
+
+// jsonFlags registers the msgpjson.Config flags shared by the commands
+// that print JSON.
+func jsonFlags(fs *flag.FlagSet, interned bool) func() (*msgpjson.Config, error) {
+	bin := fs.String("bin", "base64", "encoding of bin values: base64 or hex")
+	nonFinite := fs.String("nonfinite", "error", "NaN and Inf policy: error, null or string")
+	var internedStrings *bool
+	if interned {
+		internedStrings = fs.Bool("interned", false, "resolve strings interned by Encoder.UseInternedStrings")
+	}
+
+	return func() (*msgpjson.Config, error) {
+		cfg := new(msgpjson.Config)
+		switch strings.ToLower(*bin) {
+		case "base64":
+			cfg.Bin = msgpjson.Base64
+		case "hex":
+			cfg.Bin = msgpjson.Hex
+		default:
+			return nil, fmt.Errorf("invalid -bin=%q", *bin)
+		}
+		switch strings.ToLower(*nonFinite) {
+		case "error":
+			cfg.NonFinite = msgpjson.NonFiniteError
+		case "null":
+			cfg.NonFinite = msgpjson.NonFiniteNull
+		case "string":
+			cfg.NonFinite = msgpjson.NonFiniteString
+		default:
+			return nil, fmt.Errorf("invalid -nonfinite=%q", *nonFinite)
+		}
+		if internedStrings != nil {
+			cfg.InternedStrings = *internedStrings
+		}
+		return cfg, nil
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func runCommand(t *testing.T, stdin []byte, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	err := run(args, bytes.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func encodeValues(t *testing.T, values ...interface{}) []byte {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for _, v := range values {
+		require.Nil(t, enc.Encode(v))
+	}
+	return buf.Bytes()
+}
+
+func TestDump(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseInternedStrings(true)
+	require.Nil(t, enc.Encode([]interface{}{"abc", "abc", int16(-300), []byte{1}}))
+
+	out, err := runCommand(t, buf.Bytes(), "dump")
+	require.Nil(t, err)
+	require.Equal(t, strings.Join([]string{
+		`00000000  94                          fixarray len=4`,
+		`00000001  a3 61 62 63                   fixstr len=3 "abc"`,
+		`00000005  d4 80 00                      fixext1 type=-128 len=1 interned string index=0`,
+		`00000008  d1 fe d4                      int16 -300`,
+		`0000000b  c4 01 01                      bin8 len=1`,
+		``,
+	}, "\n"), out)
+}
+
+func TestDumpTimeZone(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetTimeFormat(msgpack.TimeFormatExtZone)
+	require.Nil(t, enc.Encode(time.Unix(1, 0).In(time.FixedZone("CET", 3600))))
+
+	out, err := runCommand(t, buf.Bytes(), "dump", "-width", "4")
+	require.Nil(t, err)
+	require.Equal(t, "00000000  c7 0d 7f 01 ..  "+
+		`ext8 type=127 len=13 timestamp 1970-01-01T01:00:01+01:00 zone="CET"`+"\n", out)
+}
+
+func TestDumpTypeName(t *testing.T) {
+	type point struct{ X int }
+	r := msgpack.NewRegistry()
+	r.RegisterName("point", point{})
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetRegistry(r)
+	enc.UseTypeNames(true)
+	require.Nil(t, enc.Encode([]interface{}{point{X: 1}}))
+
+	out, err := runCommand(t, buf.Bytes(), "dump")
+	require.Nil(t, err)
+	require.Equal(t, strings.Join([]string{
+		`00000000  91                          fixarray len=1`,
+		`00000001  c7 0a 7e                      ext8 type=126 len=10 type name`,
+		`00000004  a5 70 6f 69 6e 74               fixstr len=5 "point"`,
+		`0000000a  81                              fixmap len=1`,
+		`0000000b  a1 58                             fixstr len=1 "X"`,
+		`0000000d  01                                fixint 1`,
+		``,
+	}, "\n"), out)
+
+	_, err = runCommand(t, []byte{0xc7, 0x01, 0x7e, 0xa1, 'x'}, "dump")
+	require.EqualError(t, err, "ext8 at offset 0: invalid type name length=1")
+}
+
+func TestDumpInvalid(t *testing.T) {
+	_, err := runCommand(t, []byte{0x92, 0x01}, "dump")
+	require.EqualError(t, err, "fixarray at offset 0: unexpected EOF")
+
+	_, err = runCommand(t, []byte{0xc1}, "dump")
+	require.EqualError(t, err, "invalid code=c1 at offset 0")
+}
+
+func TestToJSONFromJSON(t *testing.T) {
+	in := `{"a":[1,"x",null]}` + "\n"
+
+	mp, err := runCommand(t, []byte(in), "fromjson")
+	require.Nil(t, err)
+
+	out, err := runCommand(t, []byte(mp), "tojson")
+	require.Nil(t, err)
+	require.Equal(t, in, out)
+
+	out, err = runCommand(t, encodeValues(t, []byte{0xff}), "tojson", "-bin", "hex")
+	require.Nil(t, err)
+	require.Equal(t, "\"ff\"\n", out)
+
+	_, err = runCommand(t, nil, "tojson", "-bin", "base32")
+	require.EqualError(t, err, `invalid -bin="base32"`)
+}
+
+func TestQuery(t *testing.T) {
+	data := encodeValues(t,
+		map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": 1}}},
+		map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": 2}}},
+	)
+
+	out, err := runCommand(t, data, "query", "items.*.id")
+	require.Nil(t, err)
+	require.Equal(t, "1\n2\n", out)
+
+	_, err = runCommand(t, data, "query", "items[")
+	require.NotNil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	data := encodeValues(t, 1, "two", []int{3})
+
+	out, err := runCommand(t, data, "validate")
+	require.Nil(t, err)
+	require.Equal(t, "3 values\n", out)
+
+	_, err = runCommand(t, data[:len(data)-1], "validate")
+	require.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	require.EqualError(t, err, "msgpack: missing element at offset 6: unexpected EOF")
+}
+
+func TestCat(t *testing.T) {
+	data := encodeValues(t, 1, 2, 3, 4)
+
+	out, err := runCommand(t, data, "cat", "-skip", "1", "-n", "2")
+	require.Nil(t, err)
+	require.Equal(t, string(encodeValues(t, 2, 3)), out)
+}
+
+func TestUnknownCommand(t *testing.T) {
+	_, err := runCommand(t, nil, "foo")
+	require.EqualError(t, err, `unknown command "foo", run "msgpack help"`)
+}
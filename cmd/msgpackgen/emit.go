@@ -0,0 +1,1023 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	msgpackPath  = "github.com/vmihailenco/msgpack/v5"
+	msgpcodePath = "github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+type emitter struct {
+	g   *generator
+	buf bytes.Buffer
+
+	imports map[string]string // path -> name
+	names   map[string]string // name -> path
+
+	addrCache map[types.Type]bool
+}
+
+func (g *generator) emit() ([]byte, error) {
+	e := &emitter{
+		g:         g,
+		imports:   make(map[string]string),
+		names:     make(map[string]string),
+		addrCache: make(map[types.Type]bool),
+	}
+	e.addImport(msgpackPath, "msgpack")
+	e.addImport(msgpcodePath, "msgpcode")
+	e.addImport("errors", "errors")
+
+	for _, typ := range g.targets {
+		fs, err := g.getFields(typ)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.encodeMethod(typ, fs); err != nil {
+			return nil, err
+		}
+		if err := e.decodeMethods(typ, fs); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by msgpackgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", g.pkgName)
+
+	paths := make([]string, 0, len(e.imports))
+	for path := range e.imports {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if a, b := isStdlib(paths[i]), isStdlib(paths[j]); a != b {
+			return a
+		}
+		return paths[i] < paths[j]
+	})
+
+	out.WriteString("import (\n")
+	for i, path := range paths {
+		if i > 0 && isStdlib(paths[i-1]) && !isStdlib(path) {
+			out.WriteString("\n")
+		}
+		name := e.imports[path]
+		if name == defaultImportName(path) {
+			fmt.Fprintf(&out, "%q\n", path)
+		} else {
+			fmt.Fprintf(&out, "%s %q\n", name, path)
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(e.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, out.Bytes())
+	}
+	return src, nil
+}
+
+func isStdlib(path string) bool {
+	elem := path
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		elem = path[:i]
+	}
+	return !strings.Contains(elem, ".")
+}
+
+func defaultImportName(path string) string {
+	name := path[strings.LastIndex(path, "/")+1:]
+	if len(name) > 1 && name[0] == 'v' {
+		if _, err := strconv.Atoi(name[1:]); err == nil {
+			prev := strings.TrimSuffix(path, "/"+name)
+			name = prev[strings.LastIndex(prev, "/")+1:]
+		}
+	}
+	return name
+}
+
+func (e *emitter) p(format string, args ...interface{}) {
+	fmt.Fprintf(&e.buf, format, args...)
+	e.buf.WriteByte('\n')
+}
+
+func (e *emitter) addImport(path, name string) string {
+	if name, ok := e.imports[path]; ok {
+		return name
+	}
+	base := name
+	for i := 1; ; i++ {
+		if _, ok := e.names[name]; !ok {
+			break
+		}
+		name = base + strconv.Itoa(i)
+	}
+	e.imports[path] = name
+	e.names[name] = path
+	return name
+}
+
+func (e *emitter) qualifier(pkg *types.Package) string {
+	if pkg == e.g.pkg {
+		return ""
+	}
+	return e.addImport(pkg.Path(), pkg.Name())
+}
+
+func (e *emitter) typeString(typ types.Type) string {
+	return types.TypeString(typ, e.qualifier)
+}
+
+func (e *emitter) reflect() string {
+	return e.addImport("reflect", "reflect")
+}
+
+//------------------------------------------------------------------------------
+
+// accessor returns the selector expression for the first n elements of the path.
+func (e *emitter) accessor(f *field, n int) (string, error) {
+	var b strings.Builder
+	b.WriteString("v")
+	for _, v := range f.path[:n] {
+		if !v.Exported() && v.Pkg() != e.g.pkg {
+			return "", fmt.Errorf("field %s of %s is not accessible from package %s",
+				v.Name(), v.Pkg().Path(), e.g.pkgName)
+		}
+		b.WriteByte('.')
+		b.WriteString(v.Name())
+	}
+	return b.String(), nil
+}
+
+// nilChecks returns the conditions that are true when the field can't be
+// reached because an embedded pointer on its path is nil.
+func (e *emitter) nilChecks(f *field) ([]string, error) {
+	var checks []string
+	for i, v := range f.path[:len(f.path)-1] {
+		if _, ok := v.Type().(*types.Pointer); !ok {
+			continue
+		}
+		x, err := e.accessor(f, i+1)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, x+" == nil")
+	}
+	return checks, nil
+}
+
+func (e *emitter) omitCond(fs *fields, f *field) (string, error) {
+	if !fs.hasOmitEmpty {
+		return "", nil
+	}
+	conds, err := e.nilChecks(f)
+	if err != nil {
+		return "", err
+	}
+	if f.omitEmpty || f.omitZero {
+		x, err := e.accessor(f, len(f.path))
+		if err != nil {
+			return "", err
+		}
+		if f.omitZero {
+			conds = append(conds, e.zeroExpr(x, f.Type()))
+		}
+		if f.omitEmpty {
+			conds = append(conds, e.emptyExpr(x, f.Type()))
+		}
+	}
+	return strings.Join(conds, " || "), nil
+}
+
+// zeroExpr mirrors isZeroValue.
+func (e *emitter) zeroExpr(x string, typ types.Type) string {
+	if hasIsZero(typ) {
+		switch typ.Underlying().(type) {
+		case *types.Pointer, *types.Interface:
+			return fmt.Sprintf("(%s == nil || %s.IsZero())", x, x)
+		}
+		return fmt.Sprintf("%s.IsZero()", x)
+	}
+
+	switch u := typ.Underlying().(type) {
+	case *types.Pointer, *types.Interface, *types.Slice, *types.Map, *types.Chan, *types.Signature:
+		return fmt.Sprintf("%s == nil", x)
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsString != 0:
+			return fmt.Sprintf("len(%s) == 0", x)
+		case info&types.IsBoolean != 0:
+			return "!" + x
+		case info&(types.IsInteger|types.IsFloat) != 0:
+			return x + " == 0"
+		}
+	}
+	return fmt.Sprintf("%s.ValueOf(%s).IsZero()", e.reflect(), x)
+}
+
+// emptyExpr mirrors Encoder.isEmptyValue.
+func (e *emitter) emptyExpr(x string, typ types.Type) string {
+	if _, ok := typ.Underlying().(*types.Interface); ok {
+		return fmt.Sprintf("enc.IsEmpty(%s)", x)
+	}
+
+	if hasIsZero(typ) {
+		switch typ.Underlying().(type) {
+		case *types.Pointer, *types.Slice, *types.Map, *types.Chan, *types.Signature:
+			return fmt.Sprintf("(%s == nil || %s.IsZero())", x, x)
+		}
+		return fmt.Sprintf("%s.IsZero()", x)
+	}
+
+	switch u := typ.Underlying().(type) {
+	case *types.Array, *types.Slice, *types.Map:
+		return fmt.Sprintf("len(%s) == 0", x)
+	case *types.Struct:
+		return fmt.Sprintf("enc.IsEmpty(%s)", x)
+	case *types.Pointer:
+		return fmt.Sprintf("%s == nil", x)
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsString != 0:
+			return fmt.Sprintf("len(%s) == 0", x)
+		case info&types.IsBoolean != 0:
+			return "!" + x
+		case info&(types.IsInteger|types.IsFloat) != 0:
+			return x + " == 0"
+		}
+	}
+	return "false"
+}
+
+func hasIsZero(typ types.Type) bool {
+	sel := types.NewMethodSet(typ).Lookup(nil, "IsZero")
+	if sel == nil {
+		return false
+	}
+	sig := sel.Type().(*types.Signature)
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return false
+	}
+	res, ok := sig.Results().At(0).Type().(*types.Basic)
+	return ok && res.Kind() == types.Bool
+}
+
+//------------------------------------------------------------------------------
+
+func (e *emitter) encodeMethod(typ *types.Named, fs *fields) error {
+	e.p("")
+	e.p("// EncodeMsgpack implements msgpack.CustomEncoder.")
+	e.p("func (v %s) EncodeMsgpack(enc *msgpack.Encoder) error {", typ.Obj().Name())
+
+	if fs.AsArray {
+		e.p("if err := enc.EncodeArrayLen(%d); err != nil {", len(fs.List))
+		e.p("return err")
+		e.p("}")
+		for _, f := range fs.List {
+			if err := e.encodeField(f, false); err != nil {
+				return err
+			}
+		}
+		e.p("return nil")
+		e.p("}")
+		return nil
+	}
+
+	conds := make([]string, len(fs.List))
+	if fs.hasOmitEmpty {
+		e.p("n := %d", len(fs.List))
+		for i, f := range fs.List {
+			cond, err := e.omitCond(fs, f)
+			if err != nil {
+				return err
+			}
+			if cond == "" {
+				continue
+			}
+			conds[i] = cond
+			e.p("omit%d := %s", i, cond)
+			e.p("if omit%d {", i)
+			e.p("n--")
+			e.p("}")
+		}
+		e.p("if err := enc.EncodeMapLen(n); err != nil {")
+	} else {
+		e.p("if err := enc.EncodeMapLen(%d); err != nil {", len(fs.List))
+	}
+	e.p("return err")
+	e.p("}")
+
+	for i, f := range fs.List {
+		guarded := conds[i] != ""
+		if guarded {
+			e.p("if !omit%d {", i)
+		}
+		e.p("if err := enc.EncodeString(%q); err != nil {", f.name)
+		e.p("return err")
+		e.p("}")
+		if err := e.encodeField(f, guarded); err != nil {
+			return err
+		}
+		if guarded {
+			e.p("}")
+		}
+	}
+
+	e.p("return nil")
+	e.p("}")
+	return nil
+}
+
+// encodeField encodes the field value or nil if the field is unreachable.
+// When reachable is true the caller has already checked the path.
+func (e *emitter) encodeField(f *field, reachable bool) error {
+	x, err := e.accessor(f, len(f.path))
+	if err != nil {
+		return err
+	}
+
+	var checks []string
+	if !reachable {
+		checks, err = e.nilChecks(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(checks) > 0 {
+		e.p("if %s {", strings.Join(checks, " || "))
+		e.p("if err := enc.EncodeNil(); err != nil {")
+		e.p("return err")
+		e.p("}")
+		e.p("} else {")
+	}
+	if f.timeFormat != "" {
+		e.p("if err := enc.EncodeTimeFormat(%s, msgpack.%s); err != nil {", x, f.timeFormat)
+		e.p("return err")
+		e.p("}")
+	} else if f.union != "" {
+		e.p("if err := enc.EncodeUnion(%q, %s); err != nil {", f.union, x)
+		e.p("return err")
+		e.p("}")
+	} else if f.asString {
+		e.encodeNumberString(x, f.Type())
+	} else {
+		e.encodeValue(x, f.Type(), f.intern)
+	}
+	if len(checks) > 0 {
+		e.p("}")
+	}
+	return nil
+}
+
+func (e *emitter) encodeValue(x string, typ types.Type, intern bool) {
+	if intern {
+		if _, ok := typ.Underlying().(*types.Interface); ok {
+			reflect := e.reflect()
+			e.p("if %s == nil {", x)
+			e.p("if err := enc.EncodeNil(); err != nil {")
+			e.p("return err")
+			e.p("}")
+			e.p("} else if rv := %s.ValueOf(%s); rv.Kind() == %s.String {", reflect, x, reflect)
+			e.p("if err := enc.EncodeInternedString(rv.String()); err != nil {")
+			e.p("return err")
+			e.p("}")
+			e.p("} else if err := enc.EncodeValue(rv); err != nil {")
+			e.p("return err")
+			e.p("}")
+			return
+		}
+		e.p("if err := enc.EncodeInternedString(%s); err != nil {", convert(x, typ, types.String))
+		e.p("return err")
+		e.p("}")
+		return
+	}
+
+	if kind, ok := e.basicKind(typ); ok && isSizedInt(kind) {
+		// Sized integers respect UseCompactInts.
+		name := types.Typ[kind].Name()
+		call := fmt.Sprintf("enc.EncodeInt(int64(%s))", x)
+		if strings.HasPrefix(name, "uint") {
+			call = fmt.Sprintf("enc.EncodeUint(uint64(%s))", x)
+		}
+		e.p("if enc.CompactInts() {")
+		e.p("if err := %s; err != nil {", call)
+		e.p("return err")
+		e.p("}")
+		e.p("} else if err := enc.Encode%s(%s); err != nil {",
+			strings.ToUpper(name[:1])+name[1:], convert(x, typ, kind))
+		e.p("return err")
+		e.p("}")
+		return
+	}
+
+	e.p("if err := %s; err != nil {", e.encodeCall(x, typ))
+	e.p("return err")
+	e.p("}")
+}
+
+func isSizedInt(kind types.BasicKind) bool {
+	switch kind {
+	case types.Int8, types.Int16, types.Int32, types.Int64,
+		types.Uint8, types.Uint16, types.Uint32, types.Uint64:
+		return true
+	}
+	return false
+}
+
+// encodeNumberString encodes a bool or number or a pointer to it as a string
+// for the string tag option. Nil pointers are encoded as nil.
+func (e *emitter) encodeNumberString(x string, typ types.Type) {
+	if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+		e.p("if %s == nil {", x)
+		e.p("if err := enc.EncodeNil(); err != nil {")
+		e.p("return err")
+		e.p("}")
+		e.p("} else {")
+		e.encodeNumberString("*"+x, ptr.Elem())
+		e.p("}")
+		return
+	}
+
+	strconv := e.addImport("strconv", "strconv")
+	var call string
+	switch kind := typ.Underlying().(*types.Basic).Kind(); kind {
+	case types.Bool:
+		call = fmt.Sprintf("%s.FormatBool(%s)", strconv, convert(x, typ, kind))
+	case types.Float32:
+		call = fmt.Sprintf("%s.FormatFloat(float64(%s), 'g', -1, 32)", strconv, x)
+	case types.Float64:
+		call = fmt.Sprintf("%s.FormatFloat(%s, 'g', -1, 64)", strconv, convert(x, typ, kind))
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+		call = fmt.Sprintf("%s.FormatInt(int64(%s), 10)", strconv, x)
+	default:
+		call = fmt.Sprintf("%s.FormatUint(uint64(%s), 10)", strconv, x)
+	}
+	e.p("if err := enc.EncodeString(%s); err != nil {", call)
+	e.p("return err")
+	e.p("}")
+}
+
+func (e *emitter) encodeCall(x string, typ types.Type) string {
+	if named, ok := typ.(*types.Named); ok && e.g.isTgt[named] {
+		return x + ".EncodeMsgpack(enc)"
+	}
+	if isTime(typ) {
+		return fmt.Sprintf("enc.EncodeTime(%s)", x)
+	}
+	if isByteSlice(typ) {
+		return fmt.Sprintf("enc.EncodeBytes(%s)", x)
+	}
+
+	if kind, ok := e.basicKind(typ); ok {
+		switch kind {
+		case types.String:
+			return fmt.Sprintf("enc.EncodeString(%s)", convert(x, typ, kind))
+		case types.Bool:
+			return fmt.Sprintf("enc.EncodeBool(%s)", convert(x, typ, kind))
+		case types.Int:
+			return fmt.Sprintf("enc.EncodeInt(int64(%s))", x)
+		case types.Uint:
+			return fmt.Sprintf("enc.EncodeUint(uint64(%s))", x)
+		case types.Float32:
+			return fmt.Sprintf("enc.EncodeFloat32(%s)", convert(x, typ, kind))
+		case types.Float64:
+			return fmt.Sprintf("enc.EncodeFloat64(%s)", convert(x, typ, kind))
+		}
+	}
+
+	reflect := e.reflect()
+	if _, ok := typ.Underlying().(*types.Interface); ok {
+		// Encoding the interface rather than the value it holds
+		// respects Encoder.UseTypeNames.
+		return fmt.Sprintf("enc.EncodeValue(%s.ValueOf(&%s).Elem())", reflect, x)
+	}
+	if e.needsAddr(typ) {
+		return fmt.Sprintf("enc.EncodeValue(%s.ValueOf(&%s).Elem())", reflect, x)
+	}
+	return fmt.Sprintf("enc.EncodeValue(%s.ValueOf(%s))", reflect, x)
+}
+
+// basicKind reports the kind of types that are encoded by the number,
+// string and bool encoders without any custom methods.
+func (e *emitter) basicKind(typ types.Type) (types.BasicKind, bool) {
+	b, ok := typ.Underlying().(*types.Basic)
+	if !ok {
+		return 0, false
+	}
+	switch b.Kind() {
+	case types.Bool, types.String,
+		types.Int, types.Int8, types.Int16, types.Int32, types.Int64,
+		types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64,
+		types.Float32, types.Float64:
+	default:
+		return 0, false
+	}
+	if e.g.hasCustomCodec(typ) {
+		return 0, false
+	}
+	return b.Kind(), true
+}
+
+// needsAddr reports whether encoding typ uses methods with pointer receivers,
+// which msgpack only calls on addressable values.
+func (e *emitter) needsAddr(typ types.Type) bool {
+	if v, ok := e.addrCache[typ]; ok {
+		return v
+	}
+	e.addrCache[typ] = false
+
+	var needs bool
+	switch u := typ.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields() && !needs; i++ {
+			needs = e.needsAddr(u.Field(i).Type())
+		}
+	case *types.Array:
+		needs = e.needsAddr(u.Elem())
+	}
+	if _, ok := typ.Underlying().(*types.Interface); !ok && !needs {
+		if _, ok := typ.(*types.Pointer); !ok {
+			valueSet := types.NewMethodSet(typ)
+			ptrSet := types.NewMethodSet(types.NewPointer(typ))
+			for _, name := range encodeMethods {
+				if ptrSet.Lookup(nil, name) != nil && valueSet.Lookup(nil, name) == nil {
+					needs = true
+					break
+				}
+			}
+		}
+	}
+
+	e.addrCache[typ] = needs
+	return needs
+}
+
+func isByteSlice(typ types.Type) bool {
+	s, ok := typ.(*types.Slice)
+	if !ok {
+		return false
+	}
+	b, ok := s.Elem().(*types.Basic)
+	return ok && b.Kind() == types.Byte
+}
+
+func convert(x string, typ types.Type, kind types.BasicKind) string {
+	if types.Identical(typ, types.Typ[kind]) {
+		return x
+	}
+	return fmt.Sprintf("%s(%s)", types.Typ[kind].Name(), x)
+}
+
+//------------------------------------------------------------------------------
+
+func (e *emitter) decodeMethods(typ *types.Named, fs *fields) error {
+	name := typ.Obj().Name()
+
+	// Fields that are only reachable by name, e.g. inlined embedded structs,
+	// get slots after the list.
+	slots := append([]*field(nil), fs.List...)
+	slotIndex := make(map[*field]int, len(fs.List))
+	for i, f := range fs.List {
+		slotIndex[f] = i
+	}
+
+	var names []string
+	nameSlot := make(map[string]int)
+	for _, k := range fs.Keys {
+		idx, ok := slotIndex[k.field]
+		if !ok {
+			if _, err := e.accessor(k.field, len(k.field.path)); err != nil {
+				// Unexported embedded types from other packages can't be
+				// decoded as a whole; treat their names as unknown fields.
+				continue
+			}
+			idx = len(slots)
+			slotIndex[k.field] = idx
+			slots = append(slots, k.field)
+		}
+		if _, ok := nameSlot[k.name]; !ok {
+			names = append(names, k.name)
+		}
+		nameSlot[k.name] = idx
+	}
+
+	// Required fields and fields with default values are tracked in
+	// the order of the list, the same way fields.setAbsent handles them.
+	trackedIndex := make(map[int]int)
+	var tracked []*field
+	var trackedList []int
+	for i, f := range fs.List {
+		if f.required || f.defaultValue != nil {
+			trackedIndex[i] = len(tracked)
+			tracked = append(tracked, f)
+			trackedList = append(trackedList, i)
+		}
+	}
+
+	e.p("")
+	e.p("// DecodeMsgpack implements msgpack.CustomDecoder.")
+	e.p("func (v *%s) DecodeMsgpack(dec *msgpack.Decoder) error {", name)
+	e.p("c, err := dec.PeekCode()")
+	e.p("if err != nil {")
+	e.p("return err")
+	e.p("}")
+	e.p("")
+	e.p("if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {")
+	e.p("n, err := dec.DecodeArrayLen()")
+	e.p("if err != nil {")
+	e.p("return err")
+	e.p("}")
+	e.p("if n <= 0 {")
+	e.p("*v = %s{}", name)
+	if len(tracked) > 0 {
+		e.p("if n == -1 {")
+		e.p("return nil")
+		e.p("}")
+	} else {
+		e.p("return nil")
+	}
+	e.p("}")
+	// Shorter arrays may only miss the optional trailing fields,
+	// the same way fields.canBeAbsent checks them.
+	minLen := 0
+	for i, f := range fs.List {
+		if !f.required && f.defaultValue == nil && !f.omitEmpty && !f.omitZero {
+			minLen = i + 1
+		}
+	}
+	cond := fmt.Sprintf("n > %d", len(fs.List))
+	switch {
+	case minLen == 0:
+		// All fields are optional.
+	case len(tracked) > 0:
+		// Empty arrays are handled with the absent fields.
+		cond += fmt.Sprintf(" || n > 0 && n < %d", minLen)
+	case minLen == len(fs.List):
+		cond = fmt.Sprintf("n != %d", minLen)
+	default:
+		cond += fmt.Sprintf(" || n < %d", minLen)
+	}
+	e.p("if %s {", cond)
+	e.p("return errors.New(%q)", "msgpack: number of fields in array-encoded struct has changed")
+	e.p("}")
+	e.p("for i := 0; i < n; i++ {")
+	e.p("if err := v.decodeMsgpackField(dec, i); err != nil {")
+	e.p("return err")
+	e.p("}")
+	e.p("}")
+	if len(tracked) > 0 {
+		absent := make([]string, len(tracked))
+		for i, idx := range trackedList {
+			absent[i] = fmt.Sprintf("n <= %d", idx)
+		}
+		if err := e.setAbsent(tracked, absent); err != nil {
+			return err
+		}
+	}
+	e.p("return nil")
+	e.p("}")
+	e.p("")
+	e.p("n, err := dec.DecodeMapLen()")
+	e.p("if err != nil {")
+	e.p("return err")
+	e.p("}")
+	e.p("if n == -1 {")
+	e.p("*v = %s{}", name)
+	e.p("return nil")
+	e.p("}")
+	if len(tracked) > 0 {
+		e.p("var seen [%d]bool", len(tracked))
+	}
+	e.p("for i := 0; i < n; i++ {")
+	e.p("name, err := dec.DecodeStringTemp()")
+	e.p("if err != nil {")
+	e.p("return err")
+	e.p("}")
+	if len(names) == 0 {
+		e.p("if err := dec.SkipUnknownField(name); err != nil {")
+		e.p("return err")
+		e.p("}")
+	} else {
+		e.p("var idx int")
+		e.p("switch name {")
+		for idx := range slots {
+			var cases []string
+			for _, name := range names {
+				if nameSlot[name] == idx {
+					cases = append(cases, strconv.Quote(name))
+				}
+			}
+			if len(cases) == 0 {
+				continue
+			}
+			e.p("case %s:", strings.Join(cases, ", "))
+			e.p("idx = %d", idx)
+			if i, ok := trackedIndex[idx]; ok {
+				e.p("seen[%d] = true", i)
+			}
+		}
+		e.p("default:")
+		e.p("if err := dec.SkipUnknownField(name); err != nil {")
+		e.p("return err")
+		e.p("}")
+		e.p("continue")
+		e.p("}")
+		e.p("if err := v.decodeMsgpackField(dec, idx); err != nil {")
+		e.p("return err")
+		e.p("}")
+	}
+	e.p("}")
+	if len(tracked) > 0 {
+		absent := make([]string, len(tracked))
+		for i := range tracked {
+			absent[i] = fmt.Sprintf("!seen[%d]", i)
+		}
+		if err := e.setAbsent(tracked, absent); err != nil {
+			return err
+		}
+	}
+	e.p("return nil")
+	e.p("}")
+
+	e.p("")
+	e.p("func (v *%s) decodeMsgpackField(dec *msgpack.Decoder, i int) error {", name)
+	if len(slots) > 0 {
+		e.p("switch i {")
+		for i, f := range slots {
+			e.p("case %d:", i)
+			if err := e.decodeField(f); err != nil {
+				return err
+			}
+		}
+		e.p("}")
+	}
+	e.p("return nil")
+	e.p("}")
+	return nil
+}
+
+// setAbsent emits statements that set the default values of the tracked
+// fields that are absent and still zero and return *msgpack.MissingFieldsError
+// if any of them is required. absent holds the conditions that are true when
+// the fields are absent.
+func (e *emitter) setAbsent(tracked []*field, absent []string) error {
+	var hasRequired bool
+	for _, f := range tracked {
+		if f.required {
+			hasRequired = true
+		}
+	}
+
+	if hasRequired {
+		e.p("var missing []string")
+	}
+	for i, f := range tracked {
+		e.p("if %s {", absent[i])
+		if f.required {
+			e.p("missing = append(missing, %q)", f.name)
+		} else {
+			x, err := e.allocPath(f)
+			if err != nil {
+				return err
+			}
+			e.p("if %s {", e.zeroExpr(x, f.Type()))
+			e.p("%s = %s", x, e.defaultExpr(f))
+			e.p("}")
+		}
+		e.p("}")
+	}
+	if hasRequired {
+		e.p("if len(missing) > 0 {")
+		e.p("return &msgpack.MissingFieldsError{Type: %s.TypeOf(*v), Fields: missing}", e.reflect())
+		e.p("}")
+	}
+	return nil
+}
+
+// defaultExpr returns the expression of the field default value.
+func (e *emitter) defaultExpr(f *field) string {
+	switch v := f.defaultValue.(type) {
+	case time.Time:
+		pkg := e.addImport("time", "time")
+		loc := pkg + ".UTC"
+		if _, offset := v.Zone(); offset != 0 {
+			loc = fmt.Sprintf("%s.FixedZone(\"\", %d)", pkg, offset)
+		}
+		return fmt.Sprintf("%s.Date(%d, %d, %d, %d, %d, %d, %d, %s)",
+			pkg, v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), loc)
+	case time.Duration:
+		return fmt.Sprintf("%d // %s", int64(v), v)
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			pkg := e.addImport("math", "math")
+			x := pkg + ".NaN()"
+			if math.IsInf(v, 1) {
+				x = pkg + ".Inf(1)"
+			} else if math.IsInf(v, -1) {
+				x = pkg + ".Inf(-1)"
+			}
+			return fmt.Sprintf("%s(%s)", e.typeString(f.Type()), x)
+		}
+		bits := 64
+		if f.Type().Underlying().(*types.Basic).Kind() == types.Float32 {
+			bits = 32
+		}
+		return strconv.FormatFloat(v, 'g', -1, bits)
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// allocPath emits statements that allocate the nil embedded pointers
+// on the field path and returns the field selector expression.
+func (e *emitter) allocPath(f *field) (string, error) {
+	for i, v := range f.path[:len(f.path)-1] {
+		ptr, ok := v.Type().(*types.Pointer)
+		if !ok {
+			continue
+		}
+		x, err := e.accessor(f, i+1)
+		if err != nil {
+			return "", err
+		}
+		e.p("if %s == nil {", x)
+		e.p("%s = new(%s)", x, e.typeString(ptr.Elem()))
+		e.p("}")
+	}
+	return e.accessor(f, len(f.path))
+}
+
+func (e *emitter) decodeField(f *field) error {
+	x, err := e.allocPath(f)
+	if err != nil {
+		return err
+	}
+	if f.timeFormat != "" {
+		e.decodeInto(x, f.Type(), types.Invalid,
+			fmt.Sprintf("dec.DecodeTimeFormat(msgpack.%s)", f.timeFormat))
+		return nil
+	}
+	if f.union != "" {
+		e.p("return dec.DecodeUnion(%q, &%s)", f.union, x)
+		return nil
+	}
+	typ := f.Type()
+	if f.asString {
+		if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+			e.p("if c, err := dec.PeekCode(); err != nil {")
+			e.p("return err")
+			e.p("} else if c == msgpcode.Nil {")
+			e.p("%s = nil", x)
+			e.p("return dec.DecodeNil()")
+			e.p("}")
+			e.p("if %s == nil {", x)
+			e.p("%s = new(%s)", x, e.typeString(ptr.Elem()))
+			e.p("}")
+			x, typ = "*"+x, ptr.Elem()
+		}
+		e.decodeNumberString(x, typ)
+	}
+	e.decodeValue(x, typ, f.intern)
+	return nil
+}
+
+// decodeValue emits statements that decode into x and return.
+func (e *emitter) decodeValue(x string, typ types.Type, intern bool) {
+	if intern {
+		if _, ok := typ.Underlying().(*types.Interface); ok {
+			e.p("c, err := dec.PeekCode()")
+			e.p("if err != nil {")
+			e.p("return err")
+			e.p("}")
+			e.p("if msgpcode.IsString(c) || msgpcode.IsBin(c) || c == msgpcode.Nil ||")
+			e.p("c == msgpcode.FixExt1 || c == msgpcode.FixExt2 || c == msgpcode.FixExt4 {")
+			e.p("s, err := dec.DecodeInternedString()")
+			e.p("if err != nil {")
+			e.p("return err")
+			e.p("}")
+			e.p("%s = s", x)
+			e.p("return nil")
+			e.p("}")
+			e.p("return dec.DecodeValue(%s.ValueOf(&%s).Elem())", e.reflect(), x)
+			return
+		}
+		e.decodeInto(x, typ, types.String, "dec.DecodeInternedString()")
+		return
+	}
+
+	if named, ok := typ.(*types.Named); ok && e.g.isTgt[named] {
+		e.p("return %s.DecodeMsgpack(dec)", x)
+		return
+	}
+	if isByteSlice(typ) {
+		e.decodeInto(x, typ, types.Invalid, "dec.DecodeBytes()")
+		return
+	}
+
+	if kind, ok := e.basicKind(typ); ok {
+		switch kind {
+		case types.String:
+			e.decodeInto(x, typ, kind, "dec.DecodeString()")
+		case types.Bool:
+			e.decodeInto(x, typ, kind, "dec.DecodeBool()")
+		case types.Float32:
+			e.decodeInto(x, typ, kind, "dec.DecodeFloat32()")
+		case types.Float64:
+			e.decodeInto(x, typ, kind, "dec.DecodeFloat64()")
+		case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+			e.decodeInto(x, typ, types.Int64, "dec.DecodeInt64()")
+		default:
+			e.decodeInto(x, typ, types.Uint64, "dec.DecodeUint64()")
+		}
+		return
+	}
+
+	e.p("return dec.DecodeValue(%s.ValueOf(&%s).Elem())", e.reflect(), x)
+}
+
+// decodeNumberString emits statements that decode a bool or number encoded
+// as a string by the string tag option into x and return. The regular
+// encodings are handled by the statements that follow.
+func (e *emitter) decodeNumberString(x string, typ types.Type) {
+	strconv := e.addImport("strconv", "strconv")
+	fmtName := e.addImport("fmt", "fmt")
+
+	var call string
+	var kind types.BasicKind
+	switch b := typ.Underlying().(*types.Basic); b.Kind() {
+	case types.Bool:
+		call, kind = fmt.Sprintf("%s.ParseBool(s)", strconv), types.Bool
+	case types.Float32, types.Float64:
+		call, kind = fmt.Sprintf("%s.ParseFloat(s, %d)", strconv, bitSize(b.Kind())), types.Float64
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+		call, kind = fmt.Sprintf("%s.ParseInt(s, 10, %d)", strconv, bitSize(b.Kind())), types.Int64
+	default:
+		call, kind = fmt.Sprintf("%s.ParseUint(s, 10, %d)", strconv, bitSize(b.Kind())), types.Uint64
+	}
+
+	e.p("c, err := dec.PeekCode()")
+	e.p("if err != nil {")
+	e.p("return err")
+	e.p("}")
+	e.p("if msgpcode.IsString(c) || msgpcode.IsExt(c) {")
+	e.p("s, err := dec.DecodeString()")
+	e.p("if err != nil {")
+	e.p("return err")
+	e.p("}")
+	e.p("val, err := %s", call)
+	e.p("if err != nil {")
+	e.p(`return %s.Errorf("msgpack: %%w", err)`, fmtName)
+	e.p("}")
+	if types.Identical(typ, types.Typ[kind]) {
+		e.p("%s = val", x)
+	} else {
+		e.p("%s = %s(val)", x, e.typeString(typ))
+	}
+	e.p("return nil")
+	e.p("}")
+}
+
+// bitSize returns the bitSize argument of strconv parse functions for kind.
+func bitSize(kind types.BasicKind) int {
+	switch kind {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32, types.Float32:
+		return 32
+	case types.Int64, types.Uint64, types.Float64:
+		return 64
+	}
+	// The size of int and uint.
+	return 0
+}
+
+func (e *emitter) decodeInto(x string, typ types.Type, kind types.BasicKind, call string) {
+	e.p("val, err := %s", call)
+	e.p("if err != nil {")
+	e.p("return err")
+	e.p("}")
+	if kind == types.Invalid || types.Identical(typ, types.Typ[kind]) {
+		e.p("%s = val", x)
+	} else {
+		e.p("%s = %s(val)", x, e.typeString(typ))
+	}
+	e.p("return nil")
+}
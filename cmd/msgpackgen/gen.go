@@ -0,0 +1,562 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/tagparser/v2"
+)
+
+const (
+	defaultStructTag = "msgpack"
+	genDirective     = "//msgpack:gen"
+)
+
+type config struct {
+	Dir    string
+	Output string
+	Tag    string
+	Types  []string
+}
+
+type generator struct {
+	cfg *config
+
+	pkgName string
+	pkg     *types.Package
+	targets []*types.Named
+	isTgt   map[*types.Named]bool
+}
+
+func generate(cfg *config) ([]byte, error) {
+	g := &generator{
+		cfg:   cfg,
+		isTgt: make(map[*types.Named]bool),
+	}
+	if err := g.load(); err != nil {
+		return nil, err
+	}
+	if len(g.targets) == 0 {
+		return nil, fmt.Errorf("no types marked with %s in %s", genDirective, cfg.Dir)
+	}
+	return g.emit()
+}
+
+func (g *generator) load() error {
+	bpkg, err := build.ImportDir(g.cfg.Dir, 0)
+	if err != nil {
+		return err
+	}
+	g.pkgName = bpkg.Name
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bpkg.GoFiles {
+		if name == filepath.Base(g.cfg.Output) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(g.cfg.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	// The package may reference methods from the file being regenerated,
+	// so type errors are only reported if they affect the selected types.
+	var typeErrs []string
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			typeErrs = append(typeErrs, err.Error())
+		},
+	}
+	pkg, _ := conf.Check(bpkg.ImportPath, fset, files, nil)
+	g.pkg = pkg
+
+	var names []string
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if hasDirective(ts.Doc) || (len(gd.Specs) == 1 && hasDirective(gd.Doc)) {
+					names = append(names, ts.Name.Name)
+				}
+			}
+		}
+	}
+	for _, name := range g.cfg.Types {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return fmt.Errorf("type %s is not found in %s", name, g.cfg.Dir)
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok || obj.IsAlias() {
+			return fmt.Errorf("%s is not a named type", name)
+		}
+		if _, ok := named.Underlying().(*types.Struct); !ok {
+			return fmt.Errorf("%s is not a struct", name)
+		}
+		if named.TypeParams().Len() > 0 {
+			return fmt.Errorf("%s: generic types are not supported", name)
+		}
+		if g.isTgt[named] {
+			continue
+		}
+		if path, ok := hasInvalidType(named, make(map[*types.Named]bool)); ok {
+			return fmt.Errorf("%s%s has an invalid type: %s", name, path, strings.Join(typeErrs, "; "))
+		}
+		g.isTgt[named] = true
+		g.targets = append(g.targets, named)
+	}
+
+	return nil
+}
+
+// hasInvalidType reports whether typ could not be type-checked. path is
+// the path of the struct field with the invalid type, e.g. ".A.B".
+func hasInvalidType(typ types.Type, seen map[*types.Named]bool) (path string, ok bool) {
+	switch t := typ.(type) {
+	case *types.Basic:
+		return "", t.Kind() == types.Invalid
+	case *types.Named:
+		if seen[t] {
+			return "", false
+		}
+		seen[t] = true
+		return hasInvalidType(t.Underlying(), seen)
+	case *types.Pointer:
+		return hasInvalidType(t.Elem(), seen)
+	case *types.Slice:
+		return hasInvalidType(t.Elem(), seen)
+	case *types.Array:
+		return hasInvalidType(t.Elem(), seen)
+	case *types.Map:
+		if path, ok := hasInvalidType(t.Key(), seen); ok {
+			return path, true
+		}
+		return hasInvalidType(t.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			if path, ok := hasInvalidType(f.Type(), seen); ok {
+				return "." + f.Name() + path, true
+			}
+		}
+	}
+	return "", false
+}
+
+func hasDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == genDirective {
+			return true
+		}
+	}
+	return false
+}
+
+//------------------------------------------------------------------------------
+
+// field mirrors the msgpack field type: path is the chain of struct fields
+// that is equivalent to field.index.
+type field struct {
+	name      string
+	path      []*types.Var
+	omitEmpty bool
+	omitZero  bool
+	intern    bool
+	required  bool
+
+	// timeFormat is the name of the msgpack.TimeFormat constant
+	// set with the time= tag option.
+	timeFormat string
+	// asString is set by the string tag option.
+	asString bool
+	// union is the discriminator key set with the union= tag option.
+	union string
+	// defaultValue is the value of the default tag option parsed
+	// by parseDefault.
+	defaultValue interface{}
+}
+
+func (f *field) Type() types.Type {
+	return f.path[len(f.path)-1].Type()
+}
+
+func (f *field) withPrefix(v *types.Var) *field {
+	cp := *f
+	cp.path = append([]*types.Var{v}, f.path...)
+	return &cp
+}
+
+type key struct {
+	name  string
+	field *field
+}
+
+// fields mirrors getFields in types.go. Keys holds the decoding map in
+// insertion order so the generated switch can resolve duplicated names
+// the same way the map does.
+type fields struct {
+	List    []*field
+	Keys    []key
+	AsArray bool
+
+	hasOmitEmpty bool
+}
+
+func (fs *fields) lookup(name string) *field {
+	var found *field
+	for _, k := range fs.Keys {
+		if k.name == name {
+			found = k.field
+		}
+	}
+	return found
+}
+
+func (fs *fields) set(name string, f *field) {
+	fs.Keys = append(fs.Keys, key{name: name, field: f})
+}
+
+func (fs *fields) Add(f *field) {
+	fs.set(f.name, f)
+	fs.List = append(fs.List, f)
+	if f.omitEmpty || f.omitZero {
+		fs.hasOmitEmpty = true
+	}
+}
+
+func (g *generator) getFields(typ types.Type) (*fields, error) {
+	st := typ.Underlying().(*types.Struct)
+	fs := new(fields)
+
+	var omitEmpty bool
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+
+		structTag := reflect.StructTag(st.Tag(i))
+		tagStr := structTag.Get(defaultStructTag)
+		if tagStr == "" && g.cfg.Tag != "" {
+			tagStr = structTag.Get(g.cfg.Tag)
+		}
+
+		tag := tagparser.Parse(tagStr)
+		if tag.Name == "-" {
+			continue
+		}
+
+		if f.Name() == "_msgpack" {
+			fs.AsArray = tag.HasOption("as_array") || tag.HasOption("asArray")
+			if tag.HasOption("omitempty") {
+				omitEmpty = true
+			}
+		}
+
+		if !f.Exported() && !f.Anonymous() {
+			continue
+		}
+
+		fld := &field{
+			name:      tag.Name,
+			path:      []*types.Var{f},
+			omitEmpty: omitEmpty || tag.HasOption("omitempty"),
+			omitZero:  tag.HasOption("omitzero"),
+			required:  tag.HasOption("required"),
+		}
+
+		if tag.HasOption("remain") {
+			// Use reflection for structs that keep unknown fields.
+			return nil, fmt.Errorf("%s: remain option is not supported", typ)
+		}
+
+		if tag.HasOption("intern") {
+			switch u := f.Type().Underlying().(type) {
+			case *types.Interface:
+				if !types.Implements(types.Typ[types.String], u) {
+					return nil, fmt.Errorf("%s: intern strings are not supported on %s", typ, f.Type())
+				}
+			case *types.Basic:
+				if u.Info()&types.IsString == 0 {
+					return nil, fmt.Errorf("%s: intern strings are not supported on %s", typ, f.Type())
+				}
+			default:
+				return nil, fmt.Errorf("%s: intern strings are not supported on %s", typ, f.Type())
+			}
+			fld.intern = true
+		} else if name, ok := tagOption(tag, "time"); ok {
+			format, ok := timeFormats[name]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown time format %q", typ, name)
+			}
+			if !isTime(f.Type()) {
+				return nil, fmt.Errorf("%s: time format is not supported on %s", typ, f.Type())
+			}
+			fld.timeFormat = format
+		} else if key, ok := tagOption(tag, "union"); ok {
+			if _, ok := f.Type().Underlying().(*types.Interface); !ok {
+				return nil, fmt.Errorf("%s: union option is not supported on %s", typ, f.Type())
+			}
+			fld.union = key
+		} else if tag.HasOption("string") {
+			elem := f.Type()
+			if ptr, ok := elem.Underlying().(*types.Pointer); ok {
+				elem = ptr.Elem()
+			}
+			if !isNumberOrBool(elem) {
+				return nil, fmt.Errorf("%s: string option is not supported on %s", typ, f.Type())
+			}
+			fld.asString = true
+		}
+
+		if fld.name == "" {
+			fld.name = f.Name()
+		}
+
+		if s, ok := tagOption(tag, "default"); ok {
+			if fld.required {
+				return nil, fmt.Errorf("%s: required field %s can't have a default value", typ, f.Name())
+			}
+			v, err := parseDefault(f.Type(), s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", typ, err)
+			}
+			fld.defaultValue = v
+		}
+
+		if f.Anonymous() && !tag.HasOption("noinline") {
+			inline := tag.HasOption("inline")
+			if inline {
+				if err := g.inlineFields(fs, f); err != nil {
+					return nil, err
+				}
+			} else {
+				var err error
+				inline, err = g.shouldInline(fs, f)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if inline {
+				fs.set(fld.name, fld)
+				continue
+			}
+		}
+
+		fs.Add(fld)
+
+		if alias, ok := tag.Options["alias"]; ok {
+			fs.set(alias, fld)
+		}
+	}
+	return fs, nil
+}
+
+func (g *generator) inlineFields(fs *fields, v *types.Var) error {
+	if _, ok := v.Type().Underlying().(*types.Struct); !ok {
+		return fmt.Errorf("field %s: inline is only supported for struct types", v.Name())
+	}
+	inlined, err := g.getFields(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range inlined.List {
+		if fs.lookup(field.name) != nil {
+			// Don't inline shadowed fields.
+			continue
+		}
+		fs.Add(field.withPrefix(v))
+	}
+	return nil
+}
+
+func (g *generator) shouldInline(fs *fields, v *types.Var) (bool, error) {
+	typ := v.Type()
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	if _, ok := typ.Underlying().(*types.Struct); !ok {
+		return false, nil
+	}
+	if g.hasCustomCodec(typ) {
+		return false, nil
+	}
+
+	inlined, err := g.getFields(typ)
+	if err != nil {
+		return false, err
+	}
+	for _, field := range inlined.List {
+		if fs.lookup(field.name) != nil {
+			// Don't auto inline if there are shadowed fields.
+			return false, nil
+		}
+	}
+
+	for _, field := range inlined.List {
+		fs.Add(field.withPrefix(v))
+	}
+	return true, nil
+}
+
+var (
+	encodeMethods = []string{"EncodeMsgpack", "MarshalMsgpack", "MarshalBinary", "MarshalText"}
+	decodeMethods = []string{"DecodeMsgpack", "UnmarshalMsgpack", "UnmarshalBinary", "UnmarshalText"}
+)
+
+// hasCustomCodec reports whether msgpack uses something other than
+// the struct encoder for typ.
+func (g *generator) hasCustomCodec(typ types.Type) bool {
+	if named, ok := typ.(*types.Named); ok {
+		if g.isTgt[named] {
+			return true
+		}
+	}
+	if isTime(typ) {
+		return true
+	}
+	mset := types.NewMethodSet(types.NewPointer(typ))
+	for _, names := range [][]string{encodeMethods, decodeMethods} {
+		for _, name := range names {
+			if mset.Lookup(nil, name) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tagOption mirrors tagOption in types.go.
+func tagOption(tag *tagparser.Tag, name string) (string, bool) {
+	if value, ok := tag.Options[name]; ok {
+		return value, true
+	}
+	for opt, value := range tag.Options {
+		if strings.HasPrefix(opt, name+"=") {
+			// The parser splits name=a:b into the option name=a and the value b.
+			if value != "" {
+				return opt[len(name)+1:] + ":" + value, true
+			}
+			return opt[len(name)+1:], true
+		}
+	}
+	return "", false
+}
+
+// timeFormats maps the values of the time= tag option
+// to msgpack.TimeFormat constants.
+var timeFormats = map[string]string{
+	"ext":       "TimeFormatExt",
+	"unix":      "TimeFormatUnix",
+	"unixms":    "TimeFormatUnixMilli",
+	"unixus":    "TimeFormatUnixMicro",
+	"unixns":    "TimeFormatUnixNano",
+	"unixfloat": "TimeFormatUnixFloat",
+	"rfc3339":   "TimeFormatRFC3339",
+	"extzone":   "TimeFormatExtZone",
+}
+
+// isNumberOrBool reports whether the string tag option supports typ.
+func isNumberOrBool(typ types.Type) bool {
+	b, ok := typ.Underlying().(*types.Basic)
+	if !ok {
+		return false
+	}
+	switch b.Kind() {
+	case types.Bool,
+		types.Int, types.Int8, types.Int16, types.Int32, types.Int64,
+		types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64,
+		types.Float32, types.Float64:
+		return true
+	}
+	return false
+}
+
+func isTime(typ types.Type) bool {
+	return isTimeType(typ, "Time")
+}
+
+func isDuration(typ types.Type) bool {
+	return isTimeType(typ, "Duration")
+}
+
+func isTimeType(typ types.Type, name string) bool {
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == name
+}
+
+// parseDefault mirrors parseDefault in types.go. It returns a bool, int64,
+// uint64, float64, string, time.Duration or time.Time.
+func parseDefault(typ types.Type, s string) (interface{}, error) {
+	var v interface{}
+	var err error
+	switch {
+	case isTime(typ):
+		v, err = parseDefaultTime(s)
+	case isDuration(typ):
+		v, err = time.ParseDuration(s)
+	default:
+		b, ok := typ.Underlying().(*types.Basic)
+		if !ok {
+			return nil, fmt.Errorf("default option is not supported on %s", typ)
+		}
+		switch b.Kind() {
+		case types.Bool:
+			v, err = strconv.ParseBool(s)
+		case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+			v, err = strconv.ParseInt(s, 10, bitSize(b.Kind()))
+		case types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64:
+			v, err = strconv.ParseUint(s, 10, bitSize(b.Kind()))
+		case types.Float32, types.Float64:
+			v, err = strconv.ParseFloat(s, bitSize(b.Kind()))
+		case types.String:
+			v = s
+		default:
+			return nil, fmt.Errorf("default option is not supported on %s", typ)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid default value %q for %s", s, typ)
+	}
+	return v, nil
+}
+
+func parseDefaultTime(s string) (time.Time, error) {
+	tm, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if _, offset := tm.Zone(); offset != 0 {
+		return tm.In(time.FixedZone("", offset)), nil
+	}
+	return tm.UTC(), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateGolden(t *testing.T) {
+	dir := filepath.Join("internal", "gentest")
+
+	got, err := generate(&config{
+		Dir:    dir,
+		Output: "msgpack_gen.go",
+		Tag:    "json",
+	})
+	require.Nil(t, err)
+
+	want, err := os.ReadFile(filepath.Join(dir, "msgpack_gen.go"))
+	require.Nil(t, err)
+	require.Equal(t, string(want), string(got), "run go generate ./cmd/msgpackgen/...")
+}
+
+func TestGenerateErrors(t *testing.T) {
+	_, err := generate(&config{
+		Dir:    filepath.Join("internal", "gentest"),
+		Output: "msgpack_gen.go",
+		Types:  []string{"Level"},
+	})
+	require.EqualError(t, err, "Level is not a struct")
+
+	_, err = generate(&config{
+		Dir:    filepath.Join("internal", "gentest"),
+		Output: "msgpack_gen.go",
+		Types:  []string{"Missing"},
+	})
+	require.EqualError(t, err,
+		"type Missing is not found in "+filepath.Join("internal", "gentest"))
+
+	_, err = generate(&config{
+		Dir:    filepath.Join("internal", "gentest"),
+		Output: "msgpack_gen.go",
+		Types:  []string{"Remain"},
+	})
+	require.NotNil(t, err)
+	require.True(t, strings.HasSuffix(err.Error(), "Remain: remain option is not supported"), err)
+
+	_, err = generate(&config{
+		Dir:    filepath.Join("internal", "gentest"),
+		Output: "msgpack_gen.go",
+		Types:  []string{"InvalidDefault"},
+	})
+	require.NotNil(t, err)
+	require.True(t, strings.HasSuffix(err.Error(),
+		`InvalidDefault: invalid default value "many" for int`), err)
+}
+
+func TestGenerateTypeErrors(t *testing.T) {
+	dir := t.TempDir()
+	src := `package bad
+
+//msgpack:gen
+type Bad struct {
+	Items []Item
+}
+
+type Item struct {
+	ID Missing
+}
+`
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "bad.go"), []byte(src), 0o644))
+
+	_, err := generate(&config{Dir: dir, Output: "msgpack_gen.go"})
+	require.NotNil(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "Bad.Items.ID has an invalid type: "), err)
+	require.Contains(t, err.Error(), "undefined: Missing")
+}
@@ -0,0 +1,545 @@
+package gentest
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	_ msgpack.CustomEncoder = Basic{}
+	_ msgpack.CustomDecoder = (*Basic)(nil)
+)
+
+// Plain types have the same fields as the generated ones but no methods,
+// so they are encoded using reflection.
+type (
+	plainBasic             Basic
+	plainOmitEmpty         OmitEmpty
+	plainAllOmitEmpty      AllOmitEmpty
+	plainAsArray           AsArray
+	plainEmbedded          Embedded
+	plainEmbeddedOmitEmpty EmbeddedOmitEmpty
+	plainInterned          Interned
+	plainNested            Nested
+	plainTimes             Times
+	plainStrings           Strings
+	plainRequired          Required
+	plainRequiredAsArray   RequiredAsArray
+	plainDefaults          Defaults
+	plainDefaultsAsArray   DefaultsAsArray
+	plainOmitZero          OmitZero
+	plainUnions            Unions
+)
+
+func init() {
+	msgpack.RegisterName("circle", Circle{})
+	msgpack.RegisterName("rect", &Rect{})
+}
+
+func marshal(t *testing.T, v interface{}, arrayEncoded bool) []byte {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseArrayEncodedStructs(arrayEncoded)
+	require.Nil(t, enc.Encode(v))
+	return buf.Bytes()
+}
+
+func unmarshal(t *testing.T, b []byte, v interface{}) {
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.SetCustomStructTag("json")
+	require.Nil(t, dec.Decode(v))
+}
+
+// check verifies that generated methods produce the same bytes as reflection
+// and decode them into the same value.
+func check[G, P any](t *testing.T, v G, conv func(G) P) {
+	t.Helper()
+
+	b := marshal(t, &v, false)
+	plain := conv(v)
+	require.Equal(t, marshal(t, &plain, false), b)
+	require.Equal(t, marshal(t, v, false), b, "non-addressable value")
+
+	var got G
+	unmarshal(t, b, &got)
+	var want P
+	unmarshal(t, b, &want)
+	require.Equal(t, want, conv(got))
+
+	// Array-encoded structs are decoded too.
+	b = marshal(t, &plain, true)
+	got, want = *new(G), *new(P)
+	unmarshal(t, b, &got)
+	unmarshal(t, b, &want)
+	require.Equal(t, want, conv(got))
+}
+
+func newBasic() Basic {
+	n := 42
+	return Basic{
+		String:   "hello",
+		Bool:     true,
+		Int:      -1 << 30,
+		Int8:     -8,
+		Int16:    -300,
+		Int32:    -70000,
+		Int64:    -1 << 40,
+		Uint:     1 << 31,
+		Uint8:    200,
+		Uint16:   60000,
+		Uint32:   1 << 31,
+		Uint64:   1 << 40,
+		Float32:  1.5,
+		Float64:  2.25,
+		Bytes:    []byte("bytes"),
+		Strings:  []string{"a", "b"},
+		Map:      map[string]int{"one": 1},
+		Ptr:      &n,
+		Time:     time.Unix(1700000000, 123),
+		Duration: time.Second,
+		Iface:    "iface",
+		Level:    3,
+		Name:     "name",
+		Upper:    "upper",
+		Counter:  Counter{N: 7},
+		Array:    [2]int{1, 2},
+		Raw:      []byte(`{"raw":true}`),
+		Renamed:  "renamed",
+		Aliased:  "aliased",
+		JSON:     "json",
+		Both:     "both",
+		Skipped:  "skipped",
+		private:  "private",
+	}
+}
+
+func TestBasic(t *testing.T) {
+	conv := func(v Basic) plainBasic {
+		v.Skipped, v.private = "", ""
+		return plainBasic(v)
+	}
+	check(t, Basic{}, conv)
+	check(t, newBasic(), conv)
+}
+
+func TestCompactInts(t *testing.T) {
+	v := newBasic()
+	v.Skipped, v.private = "", ""
+	plain := plainBasic(v)
+
+	encode := func(v interface{}) []byte {
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json")
+		enc.UseCompactInts(true)
+		require.Nil(t, enc.Encode(v))
+		return buf.Bytes()
+	}
+	require.Equal(t, encode(&plain), encode(&v))
+}
+
+func TestAliasAndFallbackTag(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]string{
+		"other":     "alias",
+		"json_name": "json",
+		"Skipped":   "skipped",
+		"unknown":   "unknown",
+	})
+	require.Nil(t, err)
+
+	var v Basic
+	require.Nil(t, msgpack.Unmarshal(b, &v))
+	require.Equal(t, "alias", v.Aliased)
+	require.Equal(t, "json", v.JSON)
+	require.Equal(t, "", v.Skipped)
+}
+
+func TestOmitEmpty(t *testing.T) {
+	conv := func(v OmitEmpty) plainOmitEmpty { return plainOmitEmpty(v) }
+	check(t, OmitEmpty{}, conv)
+	check(t, OmitEmpty{Zeroer: Zeroer{S: "zero"}, Struct: Counter{}}, conv)
+
+	s := "s"
+	check(t, OmitEmpty{
+		String:  "s",
+		Bool:    true,
+		Int:     1,
+		Float:   1,
+		Slice:   []int{1},
+		Map:     map[string]string{"k": "v"},
+		Ptr:     &s,
+		Iface:   0,
+		Time:    time.Unix(1, 0),
+		Zeroer:  Zeroer{S: "not zero"},
+		Struct:  Counter{N: 1},
+		Nested:  Basic{String: "nested"},
+		Always:  "always",
+		Aliased: "aliased",
+	}, conv)
+
+	conv2 := func(v AllOmitEmpty) plainAllOmitEmpty { return plainAllOmitEmpty(v) }
+	check(t, AllOmitEmpty{}, conv2)
+	check(t, AllOmitEmpty{Foo: "foo"}, conv2)
+
+	b, err := msgpack.Marshal(AllOmitEmpty{Foo: "x"})
+	require.Nil(t, err)
+	m, err := msgpack.NewDecoder(bytes.NewReader(b)).DecodeMap()
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{"Foo": "x"}, m)
+}
+
+func TestOmitZero(t *testing.T) {
+	conv := func(v OmitZero) plainOmitZero { return plainOmitZero(v) }
+	check(t, OmitZero{}, conv)
+	check(t, OmitZero{Zeroer: Zeroer{S: "zero"}}, conv)
+
+	s := ""
+	v := OmitZero{
+		Float:  1,
+		Slice:  []int{},
+		Map:    map[string]string{},
+		Ptr:    &s,
+		Iface:  0,
+		Time:   time.Unix(1, 0),
+		Struct: Counter{N: 1},
+		Array:  [2]int{0, 1},
+		Both:   []int{},
+	}
+	check(t, v, conv)
+
+	var m map[string]interface{}
+	require.Nil(t, msgpack.Unmarshal(marshal(t, v, false), &m))
+	require.Len(t, m, 10)
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]interface{}{"Foo": "a", "unknown": 1})
+	require.Nil(t, err)
+
+	for _, v := range []interface{}{new(AsArray), new(plainAsArray)} {
+		dec := msgpack.NewDecoder(bytes.NewReader(b))
+		require.Nil(t, dec.Decode(v))
+
+		dec = msgpack.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields(true)
+		err := dec.Decode(v)
+		require.NotNil(t, err, "%T", v)
+		require.EqualError(t, err, `msgpack: unknown field "unknown"`)
+	}
+}
+
+func TestAsArray(t *testing.T) {
+	conv := func(v AsArray) plainAsArray { return plainAsArray(v) }
+	check(t, AsArray{}, conv)
+	check(t, AsArray{Foo: "foo", Bar: []int{1, 2}, Baz: &AsArray{Foo: "baz"}}, conv)
+
+	b, err := msgpack.Marshal([]interface{}{"foo", nil, nil, 1})
+	require.Nil(t, err)
+	var v AsArray
+	err = msgpack.Unmarshal(b, &v)
+	require.EqualError(t, err, "msgpack: number of fields in array-encoded struct has changed")
+
+	// Shorter arrays may only miss optional fields.
+	b, err = msgpack.Marshal([]interface{}{"foo"})
+	require.Nil(t, err)
+	for _, v := range []interface{}{new(AsArray), new(plainAsArray)} {
+		err = msgpack.Unmarshal(b, v)
+		require.EqualError(t, err, "msgpack: number of fields in array-encoded struct has changed", "%T", v)
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	conv := func(v Embedded) plainEmbedded { return plainEmbedded(v) }
+	check(t, Embedded{}, conv)
+
+	v := Embedded{
+		Inner:     Inner{InnerField: "inner", Shared: "shared"},
+		PtrInner:  &PtrInner{PtrField: 1},
+		inner:     inner{Hidden: "hidden"},
+		Shadowing: Shadowing{Field: "field", Shared: "shadowed"},
+		Extra:     Extra{Field: "extra", ExtraField: "extra field"},
+		NoInline:  Inner{InnerField: "noinline"},
+		Level:     1,
+		Own:       "own",
+	}
+	check(t, v, conv)
+
+	conv2 := func(v EmbeddedOmitEmpty) plainEmbeddedOmitEmpty { return plainEmbeddedOmitEmpty(v) }
+	check(t, EmbeddedOmitEmpty{}, conv2)
+	check(t, EmbeddedOmitEmpty{PtrInner: &PtrInner{}, Name: "name"}, conv2)
+}
+
+func TestEmbeddedByName(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]interface{}{
+		"Inner":    map[string]string{"InnerField": "a"},
+		"PtrInner": map[string]int{"PtrField": 2},
+	})
+	require.Nil(t, err)
+
+	var got Embedded
+	require.Nil(t, msgpack.Unmarshal(b, &got))
+	var want plainEmbedded
+	require.Nil(t, msgpack.Unmarshal(b, &want))
+	require.Equal(t, want, plainEmbedded(got))
+	require.Equal(t, "a", got.InnerField)
+	require.Equal(t, 2, got.PtrField)
+}
+
+func TestInterned(t *testing.T) {
+	conv := func(v Interned) plainInterned { return plainInterned(v) }
+	check(t, Interned{}, conv)
+	check(t, Interned{Str: "hello", Name: "hello", Iface: "hello", Plain: "hello"}, conv)
+	check(t, Interned{Str: "hello", Iface: 123}, conv)
+
+	items := []Interned{
+		{Str: "hello", Name: "world", Iface: "hello"},
+		{Str: "world", Name: "hello", Iface: "world"},
+	}
+	plain := []plainInterned{plainInterned(items[0]), plainInterned(items[1])}
+	b := marshal(t, items, false)
+	require.Equal(t, marshal(t, plain, false), b)
+
+	var got []Interned
+	unmarshal(t, b, &got)
+	require.Equal(t, items, got)
+}
+
+func TestTimes(t *testing.T) {
+	conv := func(v Times) plainTimes { return plainTimes(v) }
+	check(t, Times{}, conv)
+
+	tm := time.Unix(1700000000, 0)
+	v := Times{Ext: tm, Unix: tm, Milli: tm, Float: tm, RFC: tm.UTC()}
+	v.Ptr = &Times{Milli: tm}
+	check(t, v, conv)
+}
+
+func TestStrings(t *testing.T) {
+	conv := func(v Strings) plainStrings { return plainStrings(v) }
+	check(t, Strings{}, conv)
+	check(t, Strings{ID: 1 << 63, Int: -1, Level: -3, Ratio: 0.1, Float: 1e100, OK: true}, conv)
+	count, flag := int64(-5), false
+	check(t, Strings{Count: &count, Flag: &flag}, conv)
+
+	// Numbers are accepted too.
+	b, err := msgpack.Marshal(map[string]interface{}{"id": 1, "Level": 2, "Ratio": float32(0.5), "OK": true})
+	require.Nil(t, err)
+	var got Strings
+	unmarshal(t, b, &got)
+	require.Equal(t, Strings{ID: 1, Level: 2, Ratio: 0.5, OK: true}, got)
+
+	// Nil is decoded as a nil pointer.
+	b, err = msgpack.Marshal(map[string]interface{}{"Count": nil, "Flag": "true"})
+	require.Nil(t, err)
+	got = Strings{Count: &count}
+	unmarshal(t, b, &got)
+	require.Nil(t, got.Count)
+	require.True(t, *got.Flag)
+
+	b, err = msgpack.Marshal(map[string]interface{}{"Level": "200"})
+	require.Nil(t, err)
+	err = msgpack.Unmarshal(b, &got)
+	require.EqualError(t, err, `msgpack: strconv.ParseInt: parsing "200": value out of range`)
+}
+
+func TestUnions(t *testing.T) {
+	conv := func(v Unions) plainUnions { return plainUnions(v) }
+	check(t, Unions{}, conv)
+	check(t, Unions{Shape: Circle{R: 1}, Other: &Rect{W: 2, H: 3}}, conv)
+
+	var got Unions
+	unmarshal(t, marshal(t, Unions{Shape: &Rect{W: 1}}, false), &got)
+	require.Equal(t, Unions{Shape: &Rect{W: 1}}, got)
+}
+
+func TestRequired(t *testing.T) {
+	conv := func(v Required) plainRequired { return plainRequired(v) }
+	check(t, Required{ID: 1, Name: "a"}, conv)
+
+	tests := []struct {
+		in      interface{}
+		out     interface{}
+		missing []string
+	}{
+		{map[string]interface{}{"notes": "x"}, new(Required), []string{"id", "name"}},
+		{map[string]interface{}{"notes": "x"}, new(plainRequired), []string{"id", "name"}},
+		{map[string]interface{}{"name": "x"}, new(Required), []string{"id"}},
+		{map[string]interface{}{"name": "x"}, new(plainRequired), []string{"id"}},
+		{[]interface{}{}, new(RequiredAsArray), []string{"ID"}},
+		{[]interface{}{}, new(plainRequiredAsArray), []string{"ID"}},
+	}
+	for _, test := range tests {
+		b, err := msgpack.Marshal(test.in)
+		require.Nil(t, err)
+
+		err = msgpack.Unmarshal(b, test.out)
+		var missing *msgpack.MissingFieldsError
+		require.True(t, errors.As(err, &missing), "%T: %v", test.out, err)
+		require.Equal(t, reflect.TypeOf(test.out).Elem(), missing.Type)
+		require.Equal(t, test.missing, missing.Fields)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	conv := func(v Defaults) plainDefaults { return plainDefaults(v) }
+	check(t, Defaults{DefaultsPort: &DefaultsPort{}}, conv)
+
+	since := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))
+	want := Defaults{
+		Retries:      5,
+		Level:        -1,
+		Ratio:        0.1,
+		Enabled:      true,
+		Name:         "a, b",
+		Timeout:      90 * time.Second,
+		Since:        since,
+		DefaultsPort: &DefaultsPort{Port: 8080},
+	}
+	b, err := msgpack.Marshal(map[string]interface{}{"retries": 5})
+	require.Nil(t, err)
+
+	var got Defaults
+	require.Nil(t, msgpack.Unmarshal(b, &got))
+	require.Equal(t, want, got)
+	var plain plainDefaults
+	require.Nil(t, msgpack.Unmarshal(b, &plain))
+	require.Equal(t, plainDefaults(want), plain)
+
+	conv2 := func(v DefaultsAsArray) plainDefaultsAsArray { return plainDefaultsAsArray(v) }
+	check(t, DefaultsAsArray{ID: 1}, conv2)
+
+	wantArray := DefaultsAsArray{ID: 1, Retries: 3, Since: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	b, err = msgpack.Marshal([]interface{}{1})
+	require.Nil(t, err)
+
+	var gotArray DefaultsAsArray
+	require.Nil(t, msgpack.Unmarshal(b, &gotArray))
+	require.Equal(t, wantArray, gotArray)
+	var plainArray plainDefaultsAsArray
+	require.Nil(t, msgpack.Unmarshal(b, &plainArray))
+	require.Equal(t, plainDefaultsAsArray(wantArray), plainArray)
+
+	b, err = msgpack.Marshal([]interface{}{})
+	require.Nil(t, err)
+	err = msgpack.Unmarshal(b, &gotArray)
+	require.EqualError(t, err, `msgpack: gentest.DefaultsAsArray is missing required field "ID"`)
+	err = msgpack.Unmarshal(b, &plainArray)
+	require.EqualError(t, err, `msgpack: gentest.plainDefaultsAsArray is missing required field "ID"`)
+
+	// Absent fields of a populated struct keep their values.
+	b, err = msgpack.Marshal(map[string]interface{}{"name": "c"})
+	require.Nil(t, err)
+	got = Defaults{Retries: 5, Since: since.Add(time.Hour), DefaultsPort: &DefaultsPort{Port: 1}}
+	plain = plainDefaults(got)
+	require.Nil(t, msgpack.Unmarshal(b, &got))
+	require.Nil(t, msgpack.Unmarshal(b, &plain))
+	want = Defaults{
+		Retries:      5,
+		Level:        -1,
+		Ratio:        0.1,
+		Enabled:      true,
+		Name:         "c",
+		Timeout:      90 * time.Second,
+		Since:        since.Add(time.Hour),
+		DefaultsPort: &DefaultsPort{Port: 1},
+	}
+	require.Equal(t, want, got)
+	require.Equal(t, plainDefaults(want), plain)
+
+	b, err = msgpack.Marshal([]interface{}{1})
+	require.Nil(t, err)
+	gotArray = DefaultsAsArray{Retries: 5}
+	plainArray = plainDefaultsAsArray(gotArray)
+	require.Nil(t, msgpack.Unmarshal(b, &gotArray))
+	require.Nil(t, msgpack.Unmarshal(b, &plainArray))
+	wantArray.Retries = 5
+	require.Equal(t, wantArray, gotArray)
+	require.Equal(t, plainDefaultsAsArray(wantArray), plainArray)
+}
+
+func TestNested(t *testing.T) {
+	conv := func(v Nested) plainNested { return plainNested(v) }
+	check(t, Nested{}, conv)
+
+	basic := newBasic()
+	basic.Skipped, basic.private = "", ""
+	check(t, Nested{
+		Basic:    basic,
+		BasicPtr: &basic,
+		Items:    []Interned{{Str: "hello"}},
+		ByName:   map[string]AsArray{"a": {Foo: "a"}},
+	}, conv)
+}
+
+func BenchmarkGenerated(b *testing.B) {
+	v := newBasic()
+	v.Skipped, v.private = "", ""
+	data, err := msgpack.Marshal(&v)
+	require.Nil(b, err)
+
+	b.Run("generated", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var out Basic
+			if _, err := msgpack.Marshal(&v); err != nil {
+				b.Fatal(err)
+			}
+			if err := msgpack.Unmarshal(data, &out); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("reflect", func(b *testing.B) {
+		b.ReportAllocs()
+		plain := plainBasic(v)
+		for i := 0; i < b.N; i++ {
+			var out plainBasic
+			if _, err := msgpack.Marshal(&plain); err != nil {
+				b.Fatal(err)
+			}
+			if err := msgpack.Unmarshal(data, &out); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestTypeNames(t *testing.T) {
+	r := msgpack.NewRegistry()
+	r.RegisterName("level", Level(0))
+	r.RegisterName("as_array", &AsArray{})
+
+	encode := func(v interface{}) []byte {
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json")
+		enc.SetRegistry(r)
+		enc.UseTypeNames(true)
+		require.Nil(t, enc.Encode(v))
+		return buf.Bytes()
+	}
+
+	v := newBasic()
+	for _, iface := range []interface{}{Level(5), &AsArray{Foo: "foo"}} {
+		v.Iface = iface
+		plain := plainBasic(v)
+		b := encode(&v)
+		require.Equal(t, encode(&plain), b)
+
+		var got Basic
+		dec := msgpack.NewDecoder(bytes.NewReader(b))
+		dec.SetCustomStructTag("json")
+		dec.SetRegistry(r)
+		require.Nil(t, dec.Decode(&got))
+		require.Equal(t, iface, got.Iface)
+	}
+}
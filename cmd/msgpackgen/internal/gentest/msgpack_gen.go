@@ -0,0 +1,3286 @@
+// Code generated by msgpackgen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v Basic) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeMapLen(31); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("String"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.String); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Bool"); err != nil {
+		return err
+	}
+	if err := enc.EncodeBool(v.Bool); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Int"); err != nil {
+		return err
+	}
+	if err := enc.EncodeInt(int64(v.Int)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Int8"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.Int8)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt8(v.Int8); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Int16"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.Int16)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt16(v.Int16); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Int32"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.Int32)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt32(v.Int32); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Int64"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.Int64)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt64(v.Int64); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Uint"); err != nil {
+		return err
+	}
+	if err := enc.EncodeUint(uint64(v.Uint)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Uint8"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeUint(uint64(v.Uint8)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeUint8(v.Uint8); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Uint16"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeUint(uint64(v.Uint16)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeUint16(v.Uint16); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Uint32"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeUint(uint64(v.Uint32)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeUint32(v.Uint32); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Uint64"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeUint(uint64(v.Uint64)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeUint64(v.Uint64); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Float32"); err != nil {
+		return err
+	}
+	if err := enc.EncodeFloat32(v.Float32); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Float64"); err != nil {
+		return err
+	}
+	if err := enc.EncodeFloat64(v.Float64); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Bytes"); err != nil {
+		return err
+	}
+	if err := enc.EncodeBytes(v.Bytes); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Strings"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.Strings)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Map"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.Map)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Ptr"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.Ptr)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Time"); err != nil {
+		return err
+	}
+	if err := enc.EncodeTime(v.Time); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Duration"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.Duration)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt64(int64(v.Duration)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Iface"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(&v.Iface).Elem()); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Level"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.Level)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt8(int8(v.Level)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Name"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(string(v.Name)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Upper"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.Upper)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Counter"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(&v.Counter).Elem()); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Array"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.Array)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Raw"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.Raw)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("renamed"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Renamed); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("aliased"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Aliased); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("json_name"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.JSON); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("both"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Both); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *Basic) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = Basic{}
+			return nil
+		}
+		if n != 31 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = Basic{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "String":
+			idx = 0
+		case "Bool":
+			idx = 1
+		case "Int":
+			idx = 2
+		case "Int8":
+			idx = 3
+		case "Int16":
+			idx = 4
+		case "Int32":
+			idx = 5
+		case "Int64":
+			idx = 6
+		case "Uint":
+			idx = 7
+		case "Uint8":
+			idx = 8
+		case "Uint16":
+			idx = 9
+		case "Uint32":
+			idx = 10
+		case "Uint64":
+			idx = 11
+		case "Float32":
+			idx = 12
+		case "Float64":
+			idx = 13
+		case "Bytes":
+			idx = 14
+		case "Strings":
+			idx = 15
+		case "Map":
+			idx = 16
+		case "Ptr":
+			idx = 17
+		case "Time":
+			idx = 18
+		case "Duration":
+			idx = 19
+		case "Iface":
+			idx = 20
+		case "Level":
+			idx = 21
+		case "Name":
+			idx = 22
+		case "Upper":
+			idx = 23
+		case "Counter":
+			idx = 24
+		case "Array":
+			idx = 25
+		case "Raw":
+			idx = 26
+		case "renamed":
+			idx = 27
+		case "aliased", "other":
+			idx = 28
+		case "json_name":
+			idx = 29
+		case "both":
+			idx = 30
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Basic) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.String = val
+		return nil
+	case 1:
+		val, err := dec.DecodeBool()
+		if err != nil {
+			return err
+		}
+		v.Bool = val
+		return nil
+	case 2:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Int = int(val)
+		return nil
+	case 3:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Int8 = int8(val)
+		return nil
+	case 4:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Int16 = int16(val)
+		return nil
+	case 5:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Int32 = int32(val)
+		return nil
+	case 6:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Int64 = val
+		return nil
+	case 7:
+		val, err := dec.DecodeUint64()
+		if err != nil {
+			return err
+		}
+		v.Uint = uint(val)
+		return nil
+	case 8:
+		val, err := dec.DecodeUint64()
+		if err != nil {
+			return err
+		}
+		v.Uint8 = uint8(val)
+		return nil
+	case 9:
+		val, err := dec.DecodeUint64()
+		if err != nil {
+			return err
+		}
+		v.Uint16 = uint16(val)
+		return nil
+	case 10:
+		val, err := dec.DecodeUint64()
+		if err != nil {
+			return err
+		}
+		v.Uint32 = uint32(val)
+		return nil
+	case 11:
+		val, err := dec.DecodeUint64()
+		if err != nil {
+			return err
+		}
+		v.Uint64 = val
+		return nil
+	case 12:
+		val, err := dec.DecodeFloat32()
+		if err != nil {
+			return err
+		}
+		v.Float32 = val
+		return nil
+	case 13:
+		val, err := dec.DecodeFloat64()
+		if err != nil {
+			return err
+		}
+		v.Float64 = val
+		return nil
+	case 14:
+		val, err := dec.DecodeBytes()
+		if err != nil {
+			return err
+		}
+		v.Bytes = val
+		return nil
+	case 15:
+		return dec.DecodeValue(reflect.ValueOf(&v.Strings).Elem())
+	case 16:
+		return dec.DecodeValue(reflect.ValueOf(&v.Map).Elem())
+	case 17:
+		return dec.DecodeValue(reflect.ValueOf(&v.Ptr).Elem())
+	case 18:
+		return dec.DecodeValue(reflect.ValueOf(&v.Time).Elem())
+	case 19:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Duration = time.Duration(val)
+		return nil
+	case 20:
+		return dec.DecodeValue(reflect.ValueOf(&v.Iface).Elem())
+	case 21:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Level = Level(val)
+		return nil
+	case 22:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Name = Name(val)
+		return nil
+	case 23:
+		return dec.DecodeValue(reflect.ValueOf(&v.Upper).Elem())
+	case 24:
+		return dec.DecodeValue(reflect.ValueOf(&v.Counter).Elem())
+	case 25:
+		return dec.DecodeValue(reflect.ValueOf(&v.Array).Elem())
+	case 26:
+		return dec.DecodeValue(reflect.ValueOf(&v.Raw).Elem())
+	case 27:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Renamed = val
+		return nil
+	case 28:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Aliased = val
+		return nil
+	case 29:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.JSON = val
+		return nil
+	case 30:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Both = val
+		return nil
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v OmitEmpty) EncodeMsgpack(enc *msgpack.Encoder) error {
+	n := 15
+	omit0 := len(v.String) == 0
+	if omit0 {
+		n--
+	}
+	omit1 := !v.Bool
+	if omit1 {
+		n--
+	}
+	omit2 := v.Int == 0
+	if omit2 {
+		n--
+	}
+	omit3 := v.Float == 0
+	if omit3 {
+		n--
+	}
+	omit4 := len(v.Slice) == 0
+	if omit4 {
+		n--
+	}
+	omit5 := len(v.Map) == 0
+	if omit5 {
+		n--
+	}
+	omit6 := v.Ptr == nil
+	if omit6 {
+		n--
+	}
+	omit7 := enc.IsEmpty(v.Iface)
+	if omit7 {
+		n--
+	}
+	omit8 := v.Time.IsZero()
+	if omit8 {
+		n--
+	}
+	omit9 := v.Zeroer.IsZero()
+	if omit9 {
+		n--
+	}
+	omit10 := enc.IsEmpty(v.Struct)
+	if omit10 {
+		n--
+	}
+	omit11 := enc.IsEmpty(v.Nested)
+	if omit11 {
+		n--
+	}
+	omit12 := len(v.Array) == 0
+	if omit12 {
+		n--
+	}
+	omit14 := len(v.Aliased) == 0
+	if omit14 {
+		n--
+	}
+	if err := enc.EncodeMapLen(n); err != nil {
+		return err
+	}
+	if !omit0 {
+		if err := enc.EncodeString("String"); err != nil {
+			return err
+		}
+		if err := enc.EncodeString(v.String); err != nil {
+			return err
+		}
+	}
+	if !omit1 {
+		if err := enc.EncodeString("Bool"); err != nil {
+			return err
+		}
+		if err := enc.EncodeBool(v.Bool); err != nil {
+			return err
+		}
+	}
+	if !omit2 {
+		if err := enc.EncodeString("Int"); err != nil {
+			return err
+		}
+		if err := enc.EncodeInt(int64(v.Int)); err != nil {
+			return err
+		}
+	}
+	if !omit3 {
+		if err := enc.EncodeString("Float"); err != nil {
+			return err
+		}
+		if err := enc.EncodeFloat64(v.Float); err != nil {
+			return err
+		}
+	}
+	if !omit4 {
+		if err := enc.EncodeString("Slice"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(v.Slice)); err != nil {
+			return err
+		}
+	}
+	if !omit5 {
+		if err := enc.EncodeString("Map"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(v.Map)); err != nil {
+			return err
+		}
+	}
+	if !omit6 {
+		if err := enc.EncodeString("Ptr"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(v.Ptr)); err != nil {
+			return err
+		}
+	}
+	if !omit7 {
+		if err := enc.EncodeString("Iface"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(&v.Iface).Elem()); err != nil {
+			return err
+		}
+	}
+	if !omit8 {
+		if err := enc.EncodeString("Time"); err != nil {
+			return err
+		}
+		if err := enc.EncodeTime(v.Time); err != nil {
+			return err
+		}
+	}
+	if !omit9 {
+		if err := enc.EncodeString("Zeroer"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(v.Zeroer)); err != nil {
+			return err
+		}
+	}
+	if !omit10 {
+		if err := enc.EncodeString("Struct"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(&v.Struct).Elem()); err != nil {
+			return err
+		}
+	}
+	if !omit11 {
+		if err := enc.EncodeString("Nested"); err != nil {
+			return err
+		}
+		if err := v.Nested.EncodeMsgpack(enc); err != nil {
+			return err
+		}
+	}
+	if !omit12 {
+		if err := enc.EncodeString("Array"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(v.Array)); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeString("Always"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Always); err != nil {
+		return err
+	}
+	if !omit14 {
+		if err := enc.EncodeString("a"); err != nil {
+			return err
+		}
+		if err := enc.EncodeString(v.Aliased); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *OmitEmpty) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = OmitEmpty{}
+			return nil
+		}
+		if n > 15 || n < 14 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = OmitEmpty{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "String":
+			idx = 0
+		case "Bool":
+			idx = 1
+		case "Int":
+			idx = 2
+		case "Float":
+			idx = 3
+		case "Slice":
+			idx = 4
+		case "Map":
+			idx = 5
+		case "Ptr":
+			idx = 6
+		case "Iface":
+			idx = 7
+		case "Time":
+			idx = 8
+		case "Zeroer":
+			idx = 9
+		case "Struct":
+			idx = 10
+		case "Nested":
+			idx = 11
+		case "Array":
+			idx = 12
+		case "Always":
+			idx = 13
+		case "a", "b":
+			idx = 14
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *OmitEmpty) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.String = val
+		return nil
+	case 1:
+		val, err := dec.DecodeBool()
+		if err != nil {
+			return err
+		}
+		v.Bool = val
+		return nil
+	case 2:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Int = int(val)
+		return nil
+	case 3:
+		val, err := dec.DecodeFloat64()
+		if err != nil {
+			return err
+		}
+		v.Float = val
+		return nil
+	case 4:
+		return dec.DecodeValue(reflect.ValueOf(&v.Slice).Elem())
+	case 5:
+		return dec.DecodeValue(reflect.ValueOf(&v.Map).Elem())
+	case 6:
+		return dec.DecodeValue(reflect.ValueOf(&v.Ptr).Elem())
+	case 7:
+		return dec.DecodeValue(reflect.ValueOf(&v.Iface).Elem())
+	case 8:
+		return dec.DecodeValue(reflect.ValueOf(&v.Time).Elem())
+	case 9:
+		return dec.DecodeValue(reflect.ValueOf(&v.Zeroer).Elem())
+	case 10:
+		return dec.DecodeValue(reflect.ValueOf(&v.Struct).Elem())
+	case 11:
+		return v.Nested.DecodeMsgpack(dec)
+	case 12:
+		return dec.DecodeValue(reflect.ValueOf(&v.Array).Elem())
+	case 13:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Always = val
+		return nil
+	case 14:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Aliased = val
+		return nil
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v OmitZero) EncodeMsgpack(enc *msgpack.Encoder) error {
+	n := 12
+	omit0 := len(v.String) == 0
+	if omit0 {
+		n--
+	}
+	omit1 := v.Float == 0
+	if omit1 {
+		n--
+	}
+	omit2 := v.Slice == nil
+	if omit2 {
+		n--
+	}
+	omit3 := v.Map == nil
+	if omit3 {
+		n--
+	}
+	omit4 := v.Ptr == nil
+	if omit4 {
+		n--
+	}
+	omit5 := v.Iface == nil
+	if omit5 {
+		n--
+	}
+	omit6 := v.Time.IsZero()
+	if omit6 {
+		n--
+	}
+	omit7 := v.Zeroer.IsZero()
+	if omit7 {
+		n--
+	}
+	omit8 := reflect.ValueOf(v.Struct).IsZero()
+	if omit8 {
+		n--
+	}
+	omit9 := reflect.ValueOf(v.Array).IsZero()
+	if omit9 {
+		n--
+	}
+	omit10 := v.Both == nil || len(v.Both) == 0
+	if omit10 {
+		n--
+	}
+	if err := enc.EncodeMapLen(n); err != nil {
+		return err
+	}
+	if !omit0 {
+		if err := enc.EncodeString("String"); err != nil {
+			return err
+		}
+		if err := enc.EncodeString(v.String); err != nil {
+			return err
+		}
+	}
+	if !omit1 {
+		if err := enc.EncodeString("Float"); err != nil {
+			return err
+		}
+		if err := enc.EncodeFloat32(v.Float); err != nil {
+			return err
+		}
+	}
+	if !omit2 {
+		if err := enc.EncodeString("Slice"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(v.Slice)); err != nil {
+			return err
+		}
+	}
+	if !omit3 {
+		if err := enc.EncodeString("Map"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(v.Map)); err != nil {
+			return err
+		}
+	}
+	if !omit4 {
+		if err := enc.EncodeString("Ptr"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(v.Ptr)); err != nil {
+			return err
+		}
+	}
+	if !omit5 {
+		if err := enc.EncodeString("Iface"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(&v.Iface).Elem()); err != nil {
+			return err
+		}
+	}
+	if !omit6 {
+		if err := enc.EncodeString("Time"); err != nil {
+			return err
+		}
+		if err := enc.EncodeTime(v.Time); err != nil {
+			return err
+		}
+	}
+	if !omit7 {
+		if err := enc.EncodeString("Zeroer"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(v.Zeroer)); err != nil {
+			return err
+		}
+	}
+	if !omit8 {
+		if err := enc.EncodeString("Struct"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(&v.Struct).Elem()); err != nil {
+			return err
+		}
+	}
+	if !omit9 {
+		if err := enc.EncodeString("Array"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(v.Array)); err != nil {
+			return err
+		}
+	}
+	if !omit10 {
+		if err := enc.EncodeString("Both"); err != nil {
+			return err
+		}
+		if err := enc.EncodeValue(reflect.ValueOf(v.Both)); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeString("Always"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Always); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *OmitZero) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = OmitZero{}
+			return nil
+		}
+		if n != 12 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = OmitZero{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "String":
+			idx = 0
+		case "Float":
+			idx = 1
+		case "Slice":
+			idx = 2
+		case "Map":
+			idx = 3
+		case "Ptr":
+			idx = 4
+		case "Iface":
+			idx = 5
+		case "Time":
+			idx = 6
+		case "Zeroer":
+			idx = 7
+		case "Struct":
+			idx = 8
+		case "Array":
+			idx = 9
+		case "Both":
+			idx = 10
+		case "Always":
+			idx = 11
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *OmitZero) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.String = val
+		return nil
+	case 1:
+		val, err := dec.DecodeFloat32()
+		if err != nil {
+			return err
+		}
+		v.Float = val
+		return nil
+	case 2:
+		return dec.DecodeValue(reflect.ValueOf(&v.Slice).Elem())
+	case 3:
+		return dec.DecodeValue(reflect.ValueOf(&v.Map).Elem())
+	case 4:
+		return dec.DecodeValue(reflect.ValueOf(&v.Ptr).Elem())
+	case 5:
+		return dec.DecodeValue(reflect.ValueOf(&v.Iface).Elem())
+	case 6:
+		return dec.DecodeValue(reflect.ValueOf(&v.Time).Elem())
+	case 7:
+		return dec.DecodeValue(reflect.ValueOf(&v.Zeroer).Elem())
+	case 8:
+		return dec.DecodeValue(reflect.ValueOf(&v.Struct).Elem())
+	case 9:
+		return dec.DecodeValue(reflect.ValueOf(&v.Array).Elem())
+	case 10:
+		return dec.DecodeValue(reflect.ValueOf(&v.Both).Elem())
+	case 11:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Always = val
+		return nil
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v AllOmitEmpty) EncodeMsgpack(enc *msgpack.Encoder) error {
+	n := 2
+	omit0 := len(v.Foo) == 0
+	if omit0 {
+		n--
+	}
+	omit1 := v.Bar == 0
+	if omit1 {
+		n--
+	}
+	if err := enc.EncodeMapLen(n); err != nil {
+		return err
+	}
+	if !omit0 {
+		if err := enc.EncodeString("Foo"); err != nil {
+			return err
+		}
+		if err := enc.EncodeString(v.Foo); err != nil {
+			return err
+		}
+	}
+	if !omit1 {
+		if err := enc.EncodeString("Bar"); err != nil {
+			return err
+		}
+		if err := enc.EncodeInt(int64(v.Bar)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *AllOmitEmpty) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = AllOmitEmpty{}
+			return nil
+		}
+		if n > 2 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = AllOmitEmpty{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "Foo":
+			idx = 0
+		case "Bar":
+			idx = 1
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *AllOmitEmpty) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Foo = val
+		return nil
+	case 1:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Bar = int(val)
+		return nil
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v AsArray) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeArrayLen(3); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Foo); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.Bar)); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.Baz)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *AsArray) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = AsArray{}
+			return nil
+		}
+		if n != 3 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = AsArray{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "Foo":
+			idx = 0
+		case "Bar":
+			idx = 1
+		case "Baz":
+			idx = 2
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *AsArray) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Foo = val
+		return nil
+	case 1:
+		return dec.DecodeValue(reflect.ValueOf(&v.Bar).Elem())
+	case 2:
+		return dec.DecodeValue(reflect.ValueOf(&v.Baz).Elem())
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v Embedded) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeMapLen(10); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("InnerField"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Inner.InnerField); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Shared"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Inner.Shared); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("PtrField"); err != nil {
+		return err
+	}
+	if v.PtrInner == nil {
+		if err := enc.EncodeNil(); err != nil {
+			return err
+		}
+	} else {
+		if err := enc.EncodeInt(int64(v.PtrInner.PtrField)); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeString("Hidden"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.inner.Hidden); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Shadowing"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.Shadowing)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Field"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Extra.Field); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("ExtraField"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Extra.ExtraField); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("NoInline"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.NoInline)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Level"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.Level)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt8(int8(v.Level)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Own"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Own); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *Embedded) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = Embedded{}
+			return nil
+		}
+		if n != 10 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = Embedded{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "InnerField":
+			idx = 0
+		case "Shared":
+			idx = 1
+		case "PtrField":
+			idx = 2
+		case "Hidden":
+			idx = 3
+		case "Shadowing":
+			idx = 4
+		case "Field":
+			idx = 5
+		case "ExtraField":
+			idx = 6
+		case "NoInline":
+			idx = 7
+		case "Level":
+			idx = 8
+		case "Own":
+			idx = 9
+		case "Inner":
+			idx = 10
+		case "PtrInner":
+			idx = 11
+		case "inner":
+			idx = 12
+		case "Extra":
+			idx = 13
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Embedded) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Inner.InnerField = val
+		return nil
+	case 1:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Inner.Shared = val
+		return nil
+	case 2:
+		if v.PtrInner == nil {
+			v.PtrInner = new(PtrInner)
+		}
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.PtrInner.PtrField = int(val)
+		return nil
+	case 3:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.inner.Hidden = val
+		return nil
+	case 4:
+		return dec.DecodeValue(reflect.ValueOf(&v.Shadowing).Elem())
+	case 5:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Extra.Field = val
+		return nil
+	case 6:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Extra.ExtraField = val
+		return nil
+	case 7:
+		return dec.DecodeValue(reflect.ValueOf(&v.NoInline).Elem())
+	case 8:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Level = Level(val)
+		return nil
+	case 9:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Own = val
+		return nil
+	case 10:
+		return dec.DecodeValue(reflect.ValueOf(&v.Inner).Elem())
+	case 11:
+		return dec.DecodeValue(reflect.ValueOf(&v.PtrInner).Elem())
+	case 12:
+		return dec.DecodeValue(reflect.ValueOf(&v.inner).Elem())
+	case 13:
+		return dec.DecodeValue(reflect.ValueOf(&v.Extra).Elem())
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v EmbeddedOmitEmpty) EncodeMsgpack(enc *msgpack.Encoder) error {
+	n := 2
+	omit0 := v.PtrInner == nil
+	if omit0 {
+		n--
+	}
+	omit1 := len(v.Name) == 0
+	if omit1 {
+		n--
+	}
+	if err := enc.EncodeMapLen(n); err != nil {
+		return err
+	}
+	if !omit0 {
+		if err := enc.EncodeString("PtrField"); err != nil {
+			return err
+		}
+		if err := enc.EncodeInt(int64(v.PtrInner.PtrField)); err != nil {
+			return err
+		}
+	}
+	if !omit1 {
+		if err := enc.EncodeString("Name"); err != nil {
+			return err
+		}
+		if err := enc.EncodeString(v.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *EmbeddedOmitEmpty) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = EmbeddedOmitEmpty{}
+			return nil
+		}
+		if n > 2 || n < 1 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = EmbeddedOmitEmpty{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "PtrField":
+			idx = 0
+		case "Name":
+			idx = 1
+		case "PtrInner":
+			idx = 2
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *EmbeddedOmitEmpty) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		if v.PtrInner == nil {
+			v.PtrInner = new(PtrInner)
+		}
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.PtrInner.PtrField = int(val)
+		return nil
+	case 1:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Name = val
+		return nil
+	case 2:
+		return dec.DecodeValue(reflect.ValueOf(&v.PtrInner).Elem())
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v Interned) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeMapLen(4); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Str"); err != nil {
+		return err
+	}
+	if err := enc.EncodeInternedString(v.Str); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Name"); err != nil {
+		return err
+	}
+	if err := enc.EncodeInternedString(string(v.Name)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Iface"); err != nil {
+		return err
+	}
+	if v.Iface == nil {
+		if err := enc.EncodeNil(); err != nil {
+			return err
+		}
+	} else if rv := reflect.ValueOf(v.Iface); rv.Kind() == reflect.String {
+		if err := enc.EncodeInternedString(rv.String()); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeValue(rv); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Plain"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Plain); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *Interned) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = Interned{}
+			return nil
+		}
+		if n != 4 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = Interned{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "Str":
+			idx = 0
+		case "Name":
+			idx = 1
+		case "Iface":
+			idx = 2
+		case "Plain":
+			idx = 3
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Interned) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeInternedString()
+		if err != nil {
+			return err
+		}
+		v.Str = val
+		return nil
+	case 1:
+		val, err := dec.DecodeInternedString()
+		if err != nil {
+			return err
+		}
+		v.Name = Name(val)
+		return nil
+	case 2:
+		c, err := dec.PeekCode()
+		if err != nil {
+			return err
+		}
+		if msgpcode.IsString(c) || msgpcode.IsBin(c) || c == msgpcode.Nil ||
+			c == msgpcode.FixExt1 || c == msgpcode.FixExt2 || c == msgpcode.FixExt4 {
+			s, err := dec.DecodeInternedString()
+			if err != nil {
+				return err
+			}
+			v.Iface = s
+			return nil
+		}
+		return dec.DecodeValue(reflect.ValueOf(&v.Iface).Elem())
+	case 3:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Plain = val
+		return nil
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v Times) EncodeMsgpack(enc *msgpack.Encoder) error {
+	n := 6
+	omit2 := v.Milli.IsZero()
+	if omit2 {
+		n--
+	}
+	if err := enc.EncodeMapLen(n); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Ext"); err != nil {
+		return err
+	}
+	if err := enc.EncodeTimeFormat(v.Ext, msgpack.TimeFormatExt); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Unix"); err != nil {
+		return err
+	}
+	if err := enc.EncodeTimeFormat(v.Unix, msgpack.TimeFormatUnix); err != nil {
+		return err
+	}
+	if !omit2 {
+		if err := enc.EncodeString("Milli"); err != nil {
+			return err
+		}
+		if err := enc.EncodeTimeFormat(v.Milli, msgpack.TimeFormatUnixMilli); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeString("Float"); err != nil {
+		return err
+	}
+	if err := enc.EncodeTimeFormat(v.Float, msgpack.TimeFormatUnixFloat); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("RFC"); err != nil {
+		return err
+	}
+	if err := enc.EncodeTimeFormat(v.RFC, msgpack.TimeFormatRFC3339); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Ptr"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.Ptr)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *Times) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = Times{}
+			return nil
+		}
+		if n != 6 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = Times{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "Ext":
+			idx = 0
+		case "Unix":
+			idx = 1
+		case "Milli":
+			idx = 2
+		case "Float":
+			idx = 3
+		case "RFC":
+			idx = 4
+		case "Ptr":
+			idx = 5
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Times) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeTimeFormat(msgpack.TimeFormatExt)
+		if err != nil {
+			return err
+		}
+		v.Ext = val
+		return nil
+	case 1:
+		val, err := dec.DecodeTimeFormat(msgpack.TimeFormatUnix)
+		if err != nil {
+			return err
+		}
+		v.Unix = val
+		return nil
+	case 2:
+		val, err := dec.DecodeTimeFormat(msgpack.TimeFormatUnixMilli)
+		if err != nil {
+			return err
+		}
+		v.Milli = val
+		return nil
+	case 3:
+		val, err := dec.DecodeTimeFormat(msgpack.TimeFormatUnixFloat)
+		if err != nil {
+			return err
+		}
+		v.Float = val
+		return nil
+	case 4:
+		val, err := dec.DecodeTimeFormat(msgpack.TimeFormatRFC3339)
+		if err != nil {
+			return err
+		}
+		v.RFC = val
+		return nil
+	case 5:
+		return dec.DecodeValue(reflect.ValueOf(&v.Ptr).Elem())
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v Strings) EncodeMsgpack(enc *msgpack.Encoder) error {
+	n := 8
+	omit2 := v.Level == 0
+	if omit2 {
+		n--
+	}
+	omit7 := v.Flag == nil
+	if omit7 {
+		n--
+	}
+	if err := enc.EncodeMapLen(n); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("id"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(strconv.FormatUint(uint64(v.ID), 10)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Int"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(strconv.FormatInt(int64(v.Int), 10)); err != nil {
+		return err
+	}
+	if !omit2 {
+		if err := enc.EncodeString("Level"); err != nil {
+			return err
+		}
+		if err := enc.EncodeString(strconv.FormatInt(int64(v.Level), 10)); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeString("Ratio"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(strconv.FormatFloat(float64(v.Ratio), 'g', -1, 32)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Float"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(strconv.FormatFloat(v.Float, 'g', -1, 64)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("OK"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(strconv.FormatBool(v.OK)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Count"); err != nil {
+		return err
+	}
+	if v.Count == nil {
+		if err := enc.EncodeNil(); err != nil {
+			return err
+		}
+	} else {
+		if err := enc.EncodeString(strconv.FormatInt(int64(*v.Count), 10)); err != nil {
+			return err
+		}
+	}
+	if !omit7 {
+		if err := enc.EncodeString("Flag"); err != nil {
+			return err
+		}
+		if v.Flag == nil {
+			if err := enc.EncodeNil(); err != nil {
+				return err
+			}
+		} else {
+			if err := enc.EncodeString(strconv.FormatBool(*v.Flag)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *Strings) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = Strings{}
+			return nil
+		}
+		if n > 8 || n < 7 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = Strings{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "id":
+			idx = 0
+		case "Int":
+			idx = 1
+		case "Level":
+			idx = 2
+		case "Ratio":
+			idx = 3
+		case "Float":
+			idx = 4
+		case "OK":
+			idx = 5
+		case "Count":
+			idx = 6
+		case "Flag":
+			idx = 7
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Strings) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		c, err := dec.PeekCode()
+		if err != nil {
+			return err
+		}
+		if msgpcode.IsString(c) || msgpcode.IsExt(c) {
+			s, err := dec.DecodeString()
+			if err != nil {
+				return err
+			}
+			val, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("msgpack: %w", err)
+			}
+			v.ID = val
+			return nil
+		}
+		val, err := dec.DecodeUint64()
+		if err != nil {
+			return err
+		}
+		v.ID = val
+		return nil
+	case 1:
+		c, err := dec.PeekCode()
+		if err != nil {
+			return err
+		}
+		if msgpcode.IsString(c) || msgpcode.IsExt(c) {
+			s, err := dec.DecodeString()
+			if err != nil {
+				return err
+			}
+			val, err := strconv.ParseInt(s, 10, 0)
+			if err != nil {
+				return fmt.Errorf("msgpack: %w", err)
+			}
+			v.Int = int(val)
+			return nil
+		}
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Int = int(val)
+		return nil
+	case 2:
+		c, err := dec.PeekCode()
+		if err != nil {
+			return err
+		}
+		if msgpcode.IsString(c) || msgpcode.IsExt(c) {
+			s, err := dec.DecodeString()
+			if err != nil {
+				return err
+			}
+			val, err := strconv.ParseInt(s, 10, 8)
+			if err != nil {
+				return fmt.Errorf("msgpack: %w", err)
+			}
+			v.Level = Level(val)
+			return nil
+		}
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Level = Level(val)
+		return nil
+	case 3:
+		c, err := dec.PeekCode()
+		if err != nil {
+			return err
+		}
+		if msgpcode.IsString(c) || msgpcode.IsExt(c) {
+			s, err := dec.DecodeString()
+			if err != nil {
+				return err
+			}
+			val, err := strconv.ParseFloat(s, 32)
+			if err != nil {
+				return fmt.Errorf("msgpack: %w", err)
+			}
+			v.Ratio = float32(val)
+			return nil
+		}
+		val, err := dec.DecodeFloat32()
+		if err != nil {
+			return err
+		}
+		v.Ratio = val
+		return nil
+	case 4:
+		c, err := dec.PeekCode()
+		if err != nil {
+			return err
+		}
+		if msgpcode.IsString(c) || msgpcode.IsExt(c) {
+			s, err := dec.DecodeString()
+			if err != nil {
+				return err
+			}
+			val, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("msgpack: %w", err)
+			}
+			v.Float = val
+			return nil
+		}
+		val, err := dec.DecodeFloat64()
+		if err != nil {
+			return err
+		}
+		v.Float = val
+		return nil
+	case 5:
+		c, err := dec.PeekCode()
+		if err != nil {
+			return err
+		}
+		if msgpcode.IsString(c) || msgpcode.IsExt(c) {
+			s, err := dec.DecodeString()
+			if err != nil {
+				return err
+			}
+			val, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("msgpack: %w", err)
+			}
+			v.OK = val
+			return nil
+		}
+		val, err := dec.DecodeBool()
+		if err != nil {
+			return err
+		}
+		v.OK = val
+		return nil
+	case 6:
+		if c, err := dec.PeekCode(); err != nil {
+			return err
+		} else if c == msgpcode.Nil {
+			v.Count = nil
+			return dec.DecodeNil()
+		}
+		if v.Count == nil {
+			v.Count = new(int64)
+		}
+		c, err := dec.PeekCode()
+		if err != nil {
+			return err
+		}
+		if msgpcode.IsString(c) || msgpcode.IsExt(c) {
+			s, err := dec.DecodeString()
+			if err != nil {
+				return err
+			}
+			val, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("msgpack: %w", err)
+			}
+			*v.Count = val
+			return nil
+		}
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		*v.Count = val
+		return nil
+	case 7:
+		if c, err := dec.PeekCode(); err != nil {
+			return err
+		} else if c == msgpcode.Nil {
+			v.Flag = nil
+			return dec.DecodeNil()
+		}
+		if v.Flag == nil {
+			v.Flag = new(bool)
+		}
+		c, err := dec.PeekCode()
+		if err != nil {
+			return err
+		}
+		if msgpcode.IsString(c) || msgpcode.IsExt(c) {
+			s, err := dec.DecodeString()
+			if err != nil {
+				return err
+			}
+			val, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("msgpack: %w", err)
+			}
+			*v.Flag = val
+			return nil
+		}
+		val, err := dec.DecodeBool()
+		if err != nil {
+			return err
+		}
+		*v.Flag = val
+		return nil
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v Unions) EncodeMsgpack(enc *msgpack.Encoder) error {
+	n := 2
+	omit1 := enc.IsEmpty(v.Other)
+	if omit1 {
+		n--
+	}
+	if err := enc.EncodeMapLen(n); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("shape"); err != nil {
+		return err
+	}
+	if err := enc.EncodeUnion("type", v.Shape); err != nil {
+		return err
+	}
+	if !omit1 {
+		if err := enc.EncodeString("Other"); err != nil {
+			return err
+		}
+		if err := enc.EncodeUnion("kind", v.Other); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *Unions) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = Unions{}
+			return nil
+		}
+		if n > 2 || n < 1 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = Unions{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "shape":
+			idx = 0
+		case "Other":
+			idx = 1
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Unions) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		return dec.DecodeUnion("type", &v.Shape)
+	case 1:
+		return dec.DecodeUnion("kind", &v.Other)
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v Required) EncodeMsgpack(enc *msgpack.Encoder) error {
+	n := 3
+	omit2 := len(v.Notes) == 0
+	if omit2 {
+		n--
+	}
+	if err := enc.EncodeMapLen(n); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("id"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.ID)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt64(v.ID); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("name"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(v.Name); err != nil {
+		return err
+	}
+	if !omit2 {
+		if err := enc.EncodeString("notes"); err != nil {
+			return err
+		}
+		if err := enc.EncodeString(v.Notes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *Required) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = Required{}
+			if n == -1 {
+				return nil
+			}
+		}
+		if n > 3 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		var missing []string
+		if n <= 0 {
+			missing = append(missing, "id")
+		}
+		if n <= 1 {
+			missing = append(missing, "name")
+		}
+		if len(missing) > 0 {
+			return &msgpack.MissingFieldsError{Type: reflect.TypeOf(*v), Fields: missing}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = Required{}
+		return nil
+	}
+	var seen [2]bool
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "id":
+			idx = 0
+			seen[0] = true
+		case "name":
+			idx = 1
+			seen[1] = true
+		case "notes":
+			idx = 2
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	var missing []string
+	if !seen[0] {
+		missing = append(missing, "id")
+	}
+	if !seen[1] {
+		missing = append(missing, "name")
+	}
+	if len(missing) > 0 {
+		return &msgpack.MissingFieldsError{Type: reflect.TypeOf(*v), Fields: missing}
+	}
+	return nil
+}
+
+func (v *Required) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.ID = val
+		return nil
+	case 1:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Name = val
+		return nil
+	case 2:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Notes = val
+		return nil
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v RequiredAsArray) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeArrayLen(1); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.ID)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt64(v.ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *RequiredAsArray) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = RequiredAsArray{}
+			if n == -1 {
+				return nil
+			}
+		}
+		if n > 1 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		var missing []string
+		if n <= 0 {
+			missing = append(missing, "ID")
+		}
+		if len(missing) > 0 {
+			return &msgpack.MissingFieldsError{Type: reflect.TypeOf(*v), Fields: missing}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = RequiredAsArray{}
+		return nil
+	}
+	var seen [1]bool
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "ID":
+			idx = 0
+			seen[0] = true
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	var missing []string
+	if !seen[0] {
+		missing = append(missing, "ID")
+	}
+	if len(missing) > 0 {
+		return &msgpack.MissingFieldsError{Type: reflect.TypeOf(*v), Fields: missing}
+	}
+	return nil
+}
+
+func (v *RequiredAsArray) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.ID = val
+		return nil
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v Defaults) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeMapLen(8); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("retries"); err != nil {
+		return err
+	}
+	if err := enc.EncodeInt(int64(v.Retries)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("level"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.Level)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt8(int8(v.Level)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("ratio"); err != nil {
+		return err
+	}
+	if err := enc.EncodeFloat32(v.Ratio); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("enabled"); err != nil {
+		return err
+	}
+	if err := enc.EncodeBool(v.Enabled); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("name"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(string(v.Name)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("timeout"); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.Timeout)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt64(int64(v.Timeout)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("since"); err != nil {
+		return err
+	}
+	if err := enc.EncodeTime(v.Since); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("port"); err != nil {
+		return err
+	}
+	if v.DefaultsPort == nil {
+		if err := enc.EncodeNil(); err != nil {
+			return err
+		}
+	} else {
+		if enc.CompactInts() {
+			if err := enc.EncodeUint(uint64(v.DefaultsPort.Port)); err != nil {
+				return err
+			}
+		} else if err := enc.EncodeUint16(v.DefaultsPort.Port); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *Defaults) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = Defaults{}
+			if n == -1 {
+				return nil
+			}
+		}
+		if n > 8 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		if n <= 0 {
+			if v.Retries == 0 {
+				v.Retries = 3
+			}
+		}
+		if n <= 1 {
+			if v.Level == 0 {
+				v.Level = -1
+			}
+		}
+		if n <= 2 {
+			if v.Ratio == 0 {
+				v.Ratio = 0.1
+			}
+		}
+		if n <= 3 {
+			if !v.Enabled {
+				v.Enabled = true
+			}
+		}
+		if n <= 4 {
+			if len(v.Name) == 0 {
+				v.Name = "a, b"
+			}
+		}
+		if n <= 5 {
+			if v.Timeout == 0 {
+				v.Timeout = 90000000000 // 1m30s
+			}
+		}
+		if n <= 6 {
+			if v.Since.IsZero() {
+				v.Since = time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))
+			}
+		}
+		if n <= 7 {
+			if v.DefaultsPort == nil {
+				v.DefaultsPort = new(DefaultsPort)
+			}
+			if v.DefaultsPort.Port == 0 {
+				v.DefaultsPort.Port = 8080
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = Defaults{}
+		return nil
+	}
+	var seen [8]bool
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "retries":
+			idx = 0
+			seen[0] = true
+		case "level":
+			idx = 1
+			seen[1] = true
+		case "ratio":
+			idx = 2
+			seen[2] = true
+		case "enabled":
+			idx = 3
+			seen[3] = true
+		case "name":
+			idx = 4
+			seen[4] = true
+		case "timeout":
+			idx = 5
+			seen[5] = true
+		case "since":
+			idx = 6
+			seen[6] = true
+		case "port":
+			idx = 7
+			seen[7] = true
+		case "DefaultsPort":
+			idx = 8
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	if !seen[0] {
+		if v.Retries == 0 {
+			v.Retries = 3
+		}
+	}
+	if !seen[1] {
+		if v.Level == 0 {
+			v.Level = -1
+		}
+	}
+	if !seen[2] {
+		if v.Ratio == 0 {
+			v.Ratio = 0.1
+		}
+	}
+	if !seen[3] {
+		if !v.Enabled {
+			v.Enabled = true
+		}
+	}
+	if !seen[4] {
+		if len(v.Name) == 0 {
+			v.Name = "a, b"
+		}
+	}
+	if !seen[5] {
+		if v.Timeout == 0 {
+			v.Timeout = 90000000000 // 1m30s
+		}
+	}
+	if !seen[6] {
+		if v.Since.IsZero() {
+			v.Since = time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))
+		}
+	}
+	if !seen[7] {
+		if v.DefaultsPort == nil {
+			v.DefaultsPort = new(DefaultsPort)
+		}
+		if v.DefaultsPort.Port == 0 {
+			v.DefaultsPort.Port = 8080
+		}
+	}
+	return nil
+}
+
+func (v *Defaults) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Retries = int(val)
+		return nil
+	case 1:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Level = Level(val)
+		return nil
+	case 2:
+		val, err := dec.DecodeFloat32()
+		if err != nil {
+			return err
+		}
+		v.Ratio = val
+		return nil
+	case 3:
+		val, err := dec.DecodeBool()
+		if err != nil {
+			return err
+		}
+		v.Enabled = val
+		return nil
+	case 4:
+		val, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		v.Name = Name(val)
+		return nil
+	case 5:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Timeout = time.Duration(val)
+		return nil
+	case 6:
+		return dec.DecodeValue(reflect.ValueOf(&v.Since).Elem())
+	case 7:
+		if v.DefaultsPort == nil {
+			v.DefaultsPort = new(DefaultsPort)
+		}
+		val, err := dec.DecodeUint64()
+		if err != nil {
+			return err
+		}
+		v.DefaultsPort.Port = uint16(val)
+		return nil
+	case 8:
+		return dec.DecodeValue(reflect.ValueOf(&v.DefaultsPort).Elem())
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v DefaultsAsArray) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeArrayLen(3); err != nil {
+		return err
+	}
+	if enc.CompactInts() {
+		if err := enc.EncodeInt(int64(v.ID)); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeInt64(v.ID); err != nil {
+		return err
+	}
+	if err := enc.EncodeInt(int64(v.Retries)); err != nil {
+		return err
+	}
+	if err := enc.EncodeTime(v.Since); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *DefaultsAsArray) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = DefaultsAsArray{}
+			if n == -1 {
+				return nil
+			}
+		}
+		if n > 3 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		var missing []string
+		if n <= 0 {
+			missing = append(missing, "ID")
+		}
+		if n <= 1 {
+			if v.Retries == 0 {
+				v.Retries = 3
+			}
+		}
+		if n <= 2 {
+			if v.Since.IsZero() {
+				v.Since = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+			}
+		}
+		if len(missing) > 0 {
+			return &msgpack.MissingFieldsError{Type: reflect.TypeOf(*v), Fields: missing}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = DefaultsAsArray{}
+		return nil
+	}
+	var seen [3]bool
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "ID":
+			idx = 0
+			seen[0] = true
+		case "Retries":
+			idx = 1
+			seen[1] = true
+		case "Since":
+			idx = 2
+			seen[2] = true
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	var missing []string
+	if !seen[0] {
+		missing = append(missing, "ID")
+	}
+	if !seen[1] {
+		if v.Retries == 0 {
+			v.Retries = 3
+		}
+	}
+	if !seen[2] {
+		if v.Since.IsZero() {
+			v.Since = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		}
+	}
+	if len(missing) > 0 {
+		return &msgpack.MissingFieldsError{Type: reflect.TypeOf(*v), Fields: missing}
+	}
+	return nil
+}
+
+func (v *DefaultsAsArray) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.ID = val
+		return nil
+	case 1:
+		val, err := dec.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		v.Retries = int(val)
+		return nil
+	case 2:
+		return dec.DecodeValue(reflect.ValueOf(&v.Since).Elem())
+	}
+	return nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder.
+func (v Nested) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeMapLen(4); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Basic"); err != nil {
+		return err
+	}
+	if err := v.Basic.EncodeMsgpack(enc); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("BasicPtr"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.BasicPtr)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("Items"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.Items)); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("ByName"); err != nil {
+		return err
+	}
+	if err := enc.EncodeValue(reflect.ValueOf(v.ByName)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder.
+func (v *Nested) DecodeMsgpack(dec *msgpack.Decoder) error {
+	c, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32 {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			*v = Nested{}
+			return nil
+		}
+		if n != 4 {
+			return errors.New("msgpack: number of fields in array-encoded struct has changed")
+		}
+		for i := 0; i < n; i++ {
+			if err := v.decodeMsgpackField(dec, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		*v = Nested{}
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		name, err := dec.DecodeStringTemp()
+		if err != nil {
+			return err
+		}
+		var idx int
+		switch name {
+		case "Basic":
+			idx = 0
+		case "BasicPtr":
+			idx = 1
+		case "Items":
+			idx = 2
+		case "ByName":
+			idx = 3
+		default:
+			if err := dec.SkipUnknownField(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.decodeMsgpackField(dec, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Nested) decodeMsgpackField(dec *msgpack.Decoder, i int) error {
+	switch i {
+	case 0:
+		return v.Basic.DecodeMsgpack(dec)
+	case 1:
+		return dec.DecodeValue(reflect.ValueOf(&v.BasicPtr).Elem())
+	case 2:
+		return dec.DecodeValue(reflect.ValueOf(&v.Items).Elem())
+	case 3:
+		return dec.DecodeValue(reflect.ValueOf(&v.ByName).Elem())
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+// Package gentest contains types used to test code generated by msgpackgen.
+package gentest
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+//go:generate go run github.com/vmihailenco/msgpack/v5/cmd/msgpackgen -tag json
+
+type (
+	Level int8
+	Name  string
+)
+
+// Upper is encoded with MarshalText.
+type Upper string
+
+func (u Upper) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(string(u))), nil
+}
+
+func (u *Upper) UnmarshalText(b []byte) error {
+	*u = Upper(strings.ToLower(string(b)))
+	return nil
+}
+
+// Counter has CustomEncoder methods with pointer receivers.
+type Counter struct {
+	N int
+}
+
+func (c *Counter) MarshalBinary() ([]byte, error) {
+	return []byte{byte(c.N)}, nil
+}
+
+func (c *Counter) UnmarshalBinary(b []byte) error {
+	c.N = int(b[0])
+	return nil
+}
+
+type Zeroer struct {
+	S string
+}
+
+func (z Zeroer) IsZero() bool {
+	return z.S == "zero"
+}
+
+//msgpack:gen
+type Basic struct {
+	String   string
+	Bool     bool
+	Int      int
+	Int8     int8
+	Int16    int16
+	Int32    int32
+	Int64    int64
+	Uint     uint
+	Uint8    uint8
+	Uint16   uint16
+	Uint32   uint32
+	Uint64   uint64
+	Float32  float32
+	Float64  float64
+	Bytes    []byte
+	Strings  []string
+	Map      map[string]int
+	Ptr      *int
+	Time     time.Time
+	Duration time.Duration
+	Iface    interface{}
+	Level    Level
+	Name     Name
+	Upper    Upper
+	Counter  Counter
+	Array    [2]int
+	Raw      json.RawMessage
+
+	Renamed string `msgpack:"renamed"`
+	Aliased string `msgpack:"aliased,alias:other"`
+	JSON    string `json:"json_name"`
+	Both    string `msgpack:"both" json:"ignored"`
+	Skipped string `msgpack:"-"`
+	private string
+}
+
+//msgpack:gen
+type OmitEmpty struct {
+	String  string            `msgpack:",omitempty"`
+	Bool    bool              `msgpack:",omitempty"`
+	Int     int               `msgpack:",omitempty"`
+	Float   float64           `msgpack:",omitempty"`
+	Slice   []int             `msgpack:",omitempty"`
+	Map     map[string]string `msgpack:",omitempty"`
+	Ptr     *string           `msgpack:",omitempty"`
+	Iface   interface{}       `msgpack:",omitempty"`
+	Time    time.Time         `msgpack:",omitempty"`
+	Zeroer  Zeroer            `msgpack:",omitempty"`
+	Struct  Counter           `msgpack:",omitempty"`
+	Nested  Basic             `msgpack:",omitempty"`
+	Array   [0]int            `msgpack:",omitempty"`
+	Always  string
+	Aliased string `msgpack:"a,omitempty,alias:b"`
+}
+
+//msgpack:gen
+type OmitZero struct {
+	String string            `msgpack:",omitzero"`
+	Float  float32           `msgpack:",omitzero"`
+	Slice  []int             `msgpack:",omitzero"`
+	Map    map[string]string `msgpack:",omitzero"`
+	Ptr    *string           `msgpack:",omitzero"`
+	Iface  interface{}       `msgpack:",omitzero"`
+	Time   time.Time         `msgpack:",omitzero"`
+	Zeroer Zeroer            `msgpack:",omitzero"`
+	Struct Counter           `msgpack:",omitzero"`
+	Array  [2]int            `msgpack:",omitzero"`
+	Both   []int             `msgpack:",omitzero,omitempty"`
+	Always string
+}
+
+//msgpack:gen
+type AllOmitEmpty struct {
+	_msgpack struct{} `msgpack:",omitempty"`
+
+	Foo string
+	Bar int
+}
+
+//msgpack:gen
+type AsArray struct {
+	_msgpack struct{} `msgpack:",as_array"`
+
+	Foo string
+	Bar []int
+	Baz *AsArray
+}
+
+type Inner struct {
+	InnerField string
+	Shared     string
+}
+
+type PtrInner struct {
+	PtrField int
+}
+
+type Shadowing struct {
+	Field  string
+	Shared string
+}
+
+type Extra struct {
+	Field      string
+	ExtraField string
+}
+
+type inner struct {
+	Hidden string
+}
+
+//msgpack:gen
+type Embedded struct {
+	Inner
+	*PtrInner
+	inner
+	Shadowing
+	Extra    `msgpack:",inline"`
+	NoInline Inner `msgpack:",noinline"`
+	Level
+
+	Own string
+}
+
+//msgpack:gen
+type EmbeddedOmitEmpty struct {
+	*PtrInner
+	Name string `msgpack:",omitempty"`
+}
+
+//msgpack:gen
+type Interned struct {
+	Str   string      `msgpack:",intern"`
+	Name  Name        `msgpack:",intern"`
+	Iface interface{} `msgpack:",intern"`
+	Plain string
+}
+
+//msgpack:gen
+type Times struct {
+	Ext   time.Time `msgpack:",time=ext"`
+	Unix  time.Time `msgpack:",time=unix"`
+	Milli time.Time `msgpack:",time=unixms,omitempty"`
+	Float time.Time `msgpack:",time=unixfloat"`
+	RFC   time.Time `msgpack:",time=rfc3339"`
+	Ptr   *Times
+}
+
+//msgpack:gen
+type Strings struct {
+	ID    uint64  `msgpack:"id,string"`
+	Int   int     `msgpack:",string"`
+	Level Level   `msgpack:",string,omitempty"`
+	Ratio float32 `msgpack:",string"`
+	Float float64 `msgpack:",string"`
+	OK    bool    `msgpack:",string"`
+	Count *int64  `msgpack:",string"`
+	Flag  *bool   `msgpack:",string,omitempty"`
+}
+
+// Shape is encoded as a union of Circle and Rect.
+type Shape interface {
+	Area() float64
+}
+
+type Circle struct {
+	R float64 `json:"r"`
+}
+
+func (c Circle) Area() float64 {
+	return 3 * c.R * c.R
+}
+
+type Rect struct {
+	W, H float64
+}
+
+func (r *Rect) Area() float64 {
+	return r.W * r.H
+}
+
+//msgpack:gen
+type Unions struct {
+	Shape Shape `msgpack:"shape,union=type"`
+	Other Shape `msgpack:",union:kind,omitempty"`
+}
+
+//msgpack:gen
+type Required struct {
+	ID    int64  `msgpack:"id,required"`
+	Name  string `msgpack:"name,required"`
+	Notes string `msgpack:"notes,omitempty"`
+}
+
+//msgpack:gen
+type RequiredAsArray struct {
+	_msgpack struct{} `msgpack:",as_array"`
+	ID       int64    `msgpack:",required"`
+}
+
+//msgpack:gen
+type Defaults struct {
+	Retries int           `msgpack:"retries,default=3"`
+	Level   Level         `msgpack:"level,default=-1"`
+	Ratio   float32       `msgpack:"ratio,default=0.1"`
+	Enabled bool          `msgpack:"enabled,default=true"`
+	Name    Name          `msgpack:"name,default:'a, b'"`
+	Timeout time.Duration `msgpack:"timeout,default=1m30s"`
+	Since   time.Time     `msgpack:"since,default=2020-01-02T03:04:05+01:00"`
+	*DefaultsPort
+}
+
+type DefaultsPort struct {
+	Port uint16 `msgpack:"port,default=8080"`
+}
+
+//msgpack:gen
+type DefaultsAsArray struct {
+	_msgpack struct{}  `msgpack:",as_array"`
+	ID       int64     `msgpack:",required"`
+	Retries  int       `msgpack:",default=3"`
+	Since    time.Time `msgpack:",default:2020-01-02T03:04:05Z"`
+}
+
+//msgpack:gen
+type Nested struct {
+	Basic    Basic
+	BasicPtr *Basic
+	Items    []Interned
+	ByName   map[string]AsArray
+}
+
+// InvalidDefault has a default value that can't be parsed.
+type InvalidDefault struct {
+	Retries int `msgpack:",default=many"`
+}
+
+// Remain keeps unknown fields, which is not supported by msgpackgen.
+type Remain struct {
+	Known string
+	Extra map[string]interface{} `msgpack:",remain"`
+}
@@ -0,0 +1,127 @@
+package msgpack
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// UnmarshalAs decodes the MessagePack-encoded data into a new value of type T.
+func UnmarshalAs[T any](data []byte) (T, error) {
+	var v T
+	err := Unmarshal(data, &v)
+	return v, err
+}
+
+// Codec encodes and decodes values of type T.
+//
+// Encoder and decoder functions for T are resolved once by NewCodec, so
+// the Codec skips the type switch in Encode/Decode and the type cache lookups
+// done on every call. Codec.Marshal(v) produces the same bytes as Marshal(&v)
+// and Codec.Unmarshal(data, &v) behaves like Unmarshal(data, &v).
+//
+// Codec resolves the functions with the default registry. Encoders and decoders
+// using another registry set with SetRegistry fall back to Encoder.Encode and
+// Decoder.Decode. Encoder and decoder functions registered with Register after
+// NewCodec are not picked up by the Codec. Codec is safe for concurrent use.
+type Codec[T any] struct {
+	typ    reflect.Type
+	enc    encoderFunc
+	dec    decoderFunc
+	fields *fields
+}
+
+// NewCodec returns a new Codec for type T.
+func NewCodec[T any]() *Codec[T] {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	c := &Codec[T]{
+		typ: typ,
+		enc: defaultRegistry.getEncoder(typ),
+		dec: defaultRegistry.getDecoder(typ),
+	}
+
+	if typ.Kind() == reflect.Struct &&
+		reflect.ValueOf(c.enc).Pointer() == encodeStructValuePtr &&
+		reflect.ValueOf(c.dec).Pointer() == decodeStructValuePtr {
+		c.fields = defaultRegistry.structFields(typ, "")
+	}
+
+	return c
+}
+
+// Marshal returns the MessagePack encoding of v.
+func (c *Codec[T]) Marshal(v T) ([]byte, error) {
+	enc := GetEncoder()
+
+	enc.Reset(nil)
+	enc.ResetBytes(nil)
+
+	err := c.Encode(enc, v)
+	b := enc.Bytes()
+
+	PutEncoder(enc)
+
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Unmarshal decodes the MessagePack-encoded data and stores the result
+// in the value pointed to by v.
+func (c *Codec[T]) Unmarshal(data []byte, v *T) error {
+	dec := GetDecoder()
+	dec.UsePreallocateValues(true)
+	dec.Reset(bytes.NewReader(data))
+	err := c.Decode(dec, v)
+
+	PutDecoder(dec)
+
+	return err
+}
+
+// Encode writes the MessagePack encoding of v to the encoder.
+func (c *Codec[T]) Encode(e *Encoder, v T) error {
+	if e.registry() != defaultRegistry {
+		return e.Encode(&v)
+	}
+	rv := reflect.ValueOf(&v).Elem()
+	if c.fields != nil && e.structTag == "" {
+		return e.encodeStruct(rv, c.fields)
+	}
+	return c.enc(e, rv)
+}
+
+// Decode reads the next MessagePack-encoded value from the decoder
+// and stores it in the value pointed to by v.
+func (c *Codec[T]) Decode(d *Decoder, v *T) error {
+	if v == nil {
+		return fmt.Errorf("msgpack: Decode(non-settable %T)", v)
+	}
+	if d.registry() != defaultRegistry {
+		return d.Decode(v)
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() == reflect.Interface && !rv.IsNil() {
+		if rv.Elem().Kind() != reflect.Ptr {
+			return fmt.Errorf("msgpack: Decode(non-pointer %s)", rv.Elem().Type().String())
+		}
+	}
+
+	offset := d.InputOffset()
+	var err error
+	if c.fields != nil && d.structTag == "" {
+		err = d.decodeStructFields(rv, c.fields)
+	} else {
+		err = c.dec(d, rv)
+	}
+	if err != nil {
+		err = d.decodeError(err, offset, c.typ)
+		if de, ok := err.(*DecodeError); ok {
+			de.setPath()
+		}
+		return err
+	}
+	return nil
+}
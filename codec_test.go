@@ -0,0 +1,120 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type codecItem struct {
+	ID    int64
+	Name  string `msgpack:"name,omitempty"`
+	Tags  []string
+	Attrs map[string]interface{}
+}
+
+func TestCodecStruct(t *testing.T) {
+	codec := msgpack.NewCodec[codecItem]()
+
+	in := codecItem{
+		ID:    42,
+		Tags:  []string{"a", "b"},
+		Attrs: map[string]interface{}{"foo": "bar"},
+	}
+
+	b, err := codec.Marshal(in)
+	require.Nil(t, err)
+
+	wanted, err := msgpack.Marshal(&in)
+	require.Nil(t, err)
+	require.Equal(t, wanted, b)
+
+	var out codecItem
+	require.Nil(t, codec.Unmarshal(b, &out))
+	require.Equal(t, in, out)
+}
+
+func TestCodecEncoderOptions(t *testing.T) {
+	codec := msgpack.NewCodec[codecItem]()
+	in := codecItem{ID: 1, Name: "one"}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseArrayEncodedStructs(true)
+	require.Nil(t, codec.Encode(enc, in))
+
+	var wanted bytes.Buffer
+	enc = msgpack.NewEncoder(&wanted)
+	enc.UseArrayEncodedStructs(true)
+	require.Nil(t, enc.Encode(&in))
+	require.Equal(t, wanted.Bytes(), buf.Bytes())
+
+	var out codecItem
+	require.Nil(t, codec.Decode(msgpack.NewDecoder(&buf), &out))
+	require.Equal(t, in, out)
+}
+
+func TestCodecRegistry(t *testing.T) {
+	codec := msgpack.NewCodec[registryWeather]()
+	in := registryWeather{Temp: 21.5}
+
+	r := msgpack.NewRegistry()
+	r.Register(registryCelsius(0),
+		func(e *msgpack.Encoder, v reflect.Value) error {
+			return e.EncodeString("warm")
+		},
+		func(d *msgpack.Decoder, v reflect.Value) error {
+			if _, err := d.DecodeString(); err != nil {
+				return err
+			}
+			v.SetFloat(20)
+			return nil
+		})
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetRegistry(r)
+	require.Nil(t, codec.Encode(enc, in))
+
+	var wanted bytes.Buffer
+	enc = msgpack.NewEncoder(&wanted)
+	enc.SetRegistry(r)
+	require.Nil(t, enc.Encode(&in))
+	require.Equal(t, wanted.Bytes(), buf.Bytes())
+
+	var out registryWeather
+	dec := msgpack.NewDecoder(&buf)
+	dec.SetRegistry(r)
+	require.Nil(t, codec.Decode(dec, &out))
+	require.Equal(t, registryWeather{Temp: 20}, out)
+}
+
+func TestCodecTime(t *testing.T) {
+	codec := msgpack.NewCodec[time.Time]()
+
+	b, err := msgpack.Marshal(time.Unix(0, 0).UTC().Format(time.RFC3339Nano))
+	require.Nil(t, err)
+
+	out, err := msgpack.UnmarshalAs[time.Time](b)
+	require.Nil(t, err)
+
+	var out2 time.Time
+	require.Nil(t, codec.Unmarshal(b, &out2))
+	require.True(t, out.Equal(out2))
+}
+
+func TestUnmarshalAs(t *testing.T) {
+	b, err := msgpack.Marshal([]int{1, 2, 3})
+	require.Nil(t, err)
+
+	out, err := msgpack.UnmarshalAs[[]int](b)
+	require.Nil(t, err)
+	require.Equal(t, []int{1, 2, 3}, out)
+
+	_, err = msgpack.UnmarshalAs[string](b)
+	require.NotNil(t, err)
+}
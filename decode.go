@@ -2,7 +2,6 @@ package msgpack
 
 import (
 	"bufio"
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -24,6 +23,8 @@ const (
 	disallowUnknownFieldsFlag
 	usePreallocateValues
 	disableAllocLimitFlag
+	_ // useInternedStringsFlag is shared with Encoder.
+	noCopyStringsFlag
 )
 
 type bufReader interface {
@@ -31,6 +32,35 @@ type bufReader interface {
 	io.ByteScanner
 }
 
+// bytesReader reads from the decoder's own byte slice.
+type bytesReader Decoder
+
+func (br *bytesReader) Read(b []byte) (int, error) {
+	if br.pos >= len(br.data) {
+		return 0, io.EOF
+	}
+	n := copy(b, br.data[br.pos:])
+	br.pos += n
+	return n, nil
+}
+
+func (br *bytesReader) ReadByte() (byte, error) {
+	if br.pos >= len(br.data) {
+		return 0, io.EOF
+	}
+	c := br.data[br.pos]
+	br.pos++
+	return c, nil
+}
+
+func (br *bytesReader) UnreadByte() error {
+	if br.pos <= 0 {
+		return errors.New("msgpack: UnreadByte at beginning of slice")
+	}
+	br.pos--
+	return nil
+}
+
 //------------------------------------------------------------------------------
 
 var decPool = sync.Pool{
@@ -46,6 +76,7 @@ func GetDecoder() *Decoder {
 func PutDecoder(dec *Decoder) {
 	dec.r = nil
 	dec.s = nil
+	dec.data = nil
 	decPool.Put(dec)
 }
 
@@ -56,7 +87,22 @@ func PutDecoder(dec *Decoder) {
 func Unmarshal(data []byte, v interface{}) error {
 	dec := GetDecoder()
 	dec.UsePreallocateValues(true)
-	dec.Reset(bytes.NewReader(data))
+	dec.Reset(nil)
+	dec.ResetBytes(data, false)
+	err := dec.Decode(v)
+
+	PutDecoder(dec)
+
+	return err
+}
+
+// UnmarshalNoCopy is like Unmarshal, but decoded []byte and RawMessage values
+// alias data instead of being copied. data must not be modified while
+// the decoded values are in use.
+func UnmarshalNoCopy(data []byte, v interface{}) error {
+	dec := GetDecoder()
+	dec.Reset(nil)
+	dec.ResetBytes(data, true)
 	err := dec.Decode(v)
 
 	PutDecoder(dec)
@@ -73,7 +119,15 @@ type Decoder struct {
 	buf        []byte
 	rec        []byte
 	dict       []string
+	data       []byte
+	pos        int
+	off        int64
+	limits     DecoderLimits
+	depth      int
 	flags      uint32
+	noCopy     bool
+	timeFormat TimeFormat
+	reg        *Registry
 }
 
 // NewDecoder returns a new decoder that reads from r.
@@ -98,6 +152,9 @@ func (d *Decoder) ResetDict(r io.Reader, dict []string) {
 	d.ResetReader(r)
 	d.flags = 0
 	d.structTag = ""
+	d.timeFormat = TimeFormatExt
+	d.reg = nil
+	d.limits = DecoderLimits{}
 	d.dict = dict
 }
 
@@ -112,6 +169,11 @@ func (d *Decoder) WithDict(dict []string, fn func(*Decoder) error) error {
 func (d *Decoder) ResetReader(r io.Reader) {
 	d.mapDecoder = nil
 	d.dict = nil
+	d.data = nil
+	d.pos = 0
+	d.off = 0
+	d.depth = 0
+	d.noCopy = false
 
 	if br, ok := r.(bufReader); ok {
 		d.r = br
@@ -126,6 +188,23 @@ func (d *Decoder) ResetReader(r io.Reader) {
 	}
 }
 
+// ResetBytes is like ResetReader, but switches the decoder to read from b.
+// Decoding from a byte slice does not need buffering and reads b directly
+// instead of going through io.ByteScanner.
+//
+// If noCopy is true, decoded []byte and RawMessage values alias b instead
+// of being copied, so b must not be modified while they are in use.
+func (d *Decoder) ResetBytes(b []byte, noCopy bool) {
+	d.ResetReader(nil)
+	if b == nil {
+		b = []byte{}
+	}
+	d.data = b
+	d.noCopy = noCopy
+	d.r = (*bytesReader)(d)
+	d.s = (*bytesReader)(d)
+}
+
 func (d *Decoder) SetMapDecoder(fn func(*Decoder) (interface{}, error)) {
 	d.mapDecoder = fn
 }
@@ -166,6 +245,17 @@ func (d *Decoder) UseInternedStrings(on bool) {
 	}
 }
 
+// UseNoCopyStrings causes the decoder to return strings that alias the input
+// when it decodes a byte slice with noCopy enabled (see ResetBytes).
+// The input must not be modified while such strings are in use.
+func (d *Decoder) UseNoCopyStrings(on bool) {
+	if on {
+		d.flags |= noCopyStringsFlag
+	} else {
+		d.flags &= ^noCopyStringsFlag
+	}
+}
+
 // UsePreallocateValues enables preallocating values in chunks
 func (d *Decoder) UsePreallocateValues(on bool) {
 	if on {
@@ -184,14 +274,42 @@ func (d *Decoder) DisableAllocLimit(on bool) {
 	}
 }
 
+// InputOffset returns the number of bytes of the input consumed by the decoder
+// since the last reset.
+func (d *Decoder) InputOffset() int64 {
+	if d.data != nil {
+		return int64(d.pos)
+	}
+	return d.off
+}
+
 // Buffered returns a reader of the data remaining in the Decoder's buffer.
 // The reader is valid until the next call to Decode.
 func (d *Decoder) Buffered() io.Reader {
 	return d.r
 }
 
-//nolint:gocyclo
+// Decode decodes the next MessagePack value and stores it in the value
+// pointed to by v. Errors other than io.EOF and io.ErrUnexpectedEOF are
+// reported as *DecodeError.
 func (d *Decoder) Decode(v interface{}) error {
+	offset := d.InputOffset()
+	if err := d.decode(v); err != nil {
+		typ := reflect.TypeOf(v)
+		if typ != nil && typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		err = d.decodeError(err, offset, typ)
+		if de, ok := err.(*DecodeError); ok {
+			de.setPath()
+		}
+		return err
+	}
+	return nil
+}
+
+//nolint:gocyclo
+func (d *Decoder) decode(v interface{}) error {
 	var err error
 	switch v := v.(type) {
 	case *string:
@@ -328,8 +446,53 @@ func (d *Decoder) decodeInterfaceCond() (interface{}, error) {
 }
 
 func (d *Decoder) DecodeValue(v reflect.Value) error {
-	decode := getDecoder(v.Type())
-	return decode(d, v)
+	offset := d.InputOffset()
+	decode := d.registry().getDecoder(v.Type())
+	if err := decode(d, v); err != nil {
+		err = d.decodeError(err, offset, v.Type())
+		// Nested values are decoded with DecodeValue too,
+		// so the path is only set at the top level.
+		if de, ok := err.(*DecodeError); ok && d.depth == 0 {
+			de.setPath()
+		}
+		return err
+	}
+	return nil
+}
+
+// decodeError annotates err with the offset and type of the value that
+// failed to decode. io.EOF and io.ErrUnexpectedEOF are returned as is,
+// so callers can still compare them to detect the end of the input.
+func (d *Decoder) decodeError(err error, offset int64, typ reflect.Type) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return err
+	}
+	if _, ok := err.(*DecodeError); ok {
+		return err
+	}
+	return &DecodeError{
+		Offset: offset,
+		Type:   typ,
+		Code:   errorCode(err),
+		Err:    err,
+	}
+}
+
+func (d *Decoder) fieldError(err error, offset int64, strct reflect.Type, f *field) error {
+	sf := strct.FieldByIndex(f.index)
+	err = d.decodeError(err, offset, sf.Type)
+	if de, ok := err.(*DecodeError); ok {
+		de.addField(strct, sf.Name)
+	}
+	return err
+}
+
+func (d *Decoder) indexError(err error, offset int64, typ reflect.Type, index string) error {
+	err = d.decodeError(err, offset, typ)
+	if de, ok := err.(*DecodeError); ok {
+		de.addIndex(index)
+	}
+	return err
 }
 
 func (d *Decoder) DecodeNil() error {
@@ -338,7 +501,7 @@ func (d *Decoder) DecodeNil() error {
 		return err
 	}
 	if c != msgpcode.Nil {
-		return fmt.Errorf("msgpack: invalid code=%x decoding nil", c)
+		return invalidCodeError{code: c, hint: "nil"}
 	}
 	return nil
 }
@@ -373,7 +536,7 @@ func (d *Decoder) bool(c byte) (bool, error) {
 	if c == msgpcode.True {
 		return true, nil
 	}
-	return false, fmt.Errorf("msgpack: invalid code=%x decoding bool", c)
+	return false, invalidCodeError{code: c, hint: "bool"}
 }
 
 func (d *Decoder) DecodeDuration() (time.Duration, error) {
@@ -408,7 +571,7 @@ func (d *Decoder) DecodeInterface() (interface{}, error) {
 		return int8(c), nil
 	}
 	if msgpcode.IsFixedMap(c) {
-		err = d.s.UnreadByte()
+		err = d.unreadByte()
 		if err != nil {
 			return nil, err
 		}
@@ -453,7 +616,7 @@ func (d *Decoder) DecodeInterface() (interface{}, error) {
 	case msgpcode.Array16, msgpcode.Array32:
 		return d.decodeSlice(c)
 	case msgpcode.Map16, msgpcode.Map32:
-		err = d.s.UnreadByte()
+		err = d.unreadByte()
 		if err != nil {
 			return nil, err
 		}
@@ -481,7 +644,7 @@ func (d *Decoder) DecodeInterfaceLoose() (interface{}, error) {
 		return int64(int8(c)), nil
 	}
 	if msgpcode.IsFixedMap(c) {
-		err = d.s.UnreadByte()
+		err = d.unreadByte()
 		if err != nil {
 			return nil, err
 		}
@@ -511,7 +674,7 @@ func (d *Decoder) DecodeInterfaceLoose() (interface{}, error) {
 	case msgpcode.Array16, msgpcode.Array32:
 		return d.decodeSlice(c)
 	case msgpcode.Map16, msgpcode.Map32:
-		err = d.s.UnreadByte()
+		err = d.unreadByte()
 		if err != nil {
 			return nil, err
 		}
@@ -572,18 +735,46 @@ func (d *Decoder) Skip() error {
 }
 
 func (d *Decoder) DecodeRaw() (RawMessage, error) {
-	d.rec = make([]byte, 0)
+	b, err := d.readRaw(0)
+	if err != nil {
+		return nil, err
+	}
+	return RawMessage(b), nil
+}
+
+// readRaw skips the next value and returns its encoding.
+func (d *Decoder) readRaw(sizeHint int) ([]byte, error) {
+	if d.data != nil {
+		start := d.pos
+		if err := d.Skip(); err != nil {
+			return nil, err
+		}
+		b := d.data[start:d.pos:d.pos]
+		if d.noCopy {
+			return b, nil
+		}
+		return append(make([]byte, 0, len(b)), b...), nil
+	}
+
+	d.rec = make([]byte, 0, sizeHint)
 	if err := d.Skip(); err != nil {
 		return nil, err
 	}
-	msg := RawMessage(d.rec)
+	b := d.rec
 	d.rec = nil
-	return msg, nil
+	return b, nil
 }
 
 // PeekCode returns the next MessagePack code without advancing the reader.
 // Subpackage msgpack/codes defines the list of available msgpcode.
 func (d *Decoder) PeekCode() (byte, error) {
+	if d.data != nil {
+		if d.pos >= len(d.data) {
+			return 0, io.EOF
+		}
+		return d.data[d.pos], nil
+	}
+
 	c, err := d.s.ReadByte()
 	if err != nil {
 		return 0, err
@@ -593,7 +784,13 @@ func (d *Decoder) PeekCode() (byte, error) {
 
 // ReadFull reads exactly len(buf) bytes into the buf.
 func (d *Decoder) ReadFull(buf []byte) error {
-	_, err := readN(d.r, buf, len(buf))
+	if err := d.checkBytes(len(buf)); err != nil {
+		return err
+	}
+	b, err := readN(d.r, buf, len(buf))
+	if d.data == nil {
+		d.off += int64(len(b))
+	}
 	return err
 }
 
@@ -603,9 +800,26 @@ func (d *Decoder) hasNilCode() bool {
 }
 
 func (d *Decoder) readCode() (byte, error) {
-	c, err := d.s.ReadByte()
-	if err != nil {
-		return 0, err
+	if d.limits.MaxBytes > 0 {
+		if err := d.checkBytes(1); err != nil {
+			return 0, err
+		}
+	}
+
+	var c byte
+	if d.data != nil {
+		if d.pos >= len(d.data) {
+			return 0, io.EOF
+		}
+		c = d.data[d.pos]
+		d.pos++
+	} else {
+		var err error
+		c, err = d.s.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		d.off++
 	}
 	if d.rec != nil {
 		d.rec = append(d.rec, c)
@@ -613,24 +827,103 @@ func (d *Decoder) readCode() (byte, error) {
 	return c, nil
 }
 
+func (d *Decoder) unreadByte() error {
+	if err := d.s.UnreadByte(); err != nil {
+		return err
+	}
+	if d.data == nil {
+		d.off--
+	}
+	return nil
+}
+
 func (d *Decoder) readFull(b []byte) error {
-	_, err := io.ReadFull(d.r, b)
-	if err != nil {
+	if err := d.checkBytes(len(b)); err != nil {
 		return err
 	}
+	if d.data != nil {
+		src, err := d.next(len(b))
+		copy(b, src)
+		if err != nil {
+			return err
+		}
+	} else {
+		n, err := io.ReadFull(d.r, b)
+		d.off += int64(n)
+		if err != nil {
+			return err
+		}
+	}
 	if d.rec != nil {
 		d.rec = append(d.rec, b...)
 	}
 	return nil
 }
 
+// next returns the next n bytes of the input slice. Like io.ReadFull,
+// it returns io.EOF if no bytes are left and io.ErrUnexpectedEOF if
+// fewer than n bytes are left.
+func (d *Decoder) next(n int) ([]byte, error) {
+	if n > len(d.data)-d.pos {
+		b := d.data[d.pos:]
+		d.pos = len(d.data)
+		if len(b) == 0 && n > 0 {
+			return b, io.EOF
+		}
+		return b, io.ErrUnexpectedEOF
+	}
+	b := d.data[d.pos : d.pos+n : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// readBytes reads the next n bytes into b, reusing its capacity.
+// If noCopy is enabled, it returns a slice of the input instead.
+func (d *Decoder) readBytes(b []byte, n int) ([]byte, error) {
+	if err := d.checkBytes(n); err != nil {
+		return nil, err
+	}
+	if d.data == nil {
+		b, err := readN(d.r, b, n)
+		d.off += int64(len(b))
+		return b, err
+	}
+
+	src, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if d.noCopy {
+		return src, nil
+	}
+	if b == nil {
+		b = make([]byte, 0, n)
+	}
+	return append(b[:0], src...), nil
+}
+
 func (d *Decoder) readN(n int) ([]byte, error) {
+	if err := d.checkBytes(n); err != nil {
+		return nil, err
+	}
+	if d.data != nil {
+		b, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		if d.rec != nil {
+			d.rec = append(d.rec, b...)
+		}
+		return b, nil
+	}
+
 	var err error
 	if d.flags&disableAllocLimitFlag != 0 {
 		d.buf, err = readN(d.r, d.buf, n)
 	} else {
 		d.buf, err = readNGrow(d.r, d.buf, n)
 	}
+	d.off += int64(len(d.buf))
 	if err != nil {
 		return nil, err
 	}
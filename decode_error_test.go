@@ -0,0 +1,211 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type errorItem struct {
+	Name  string
+	Price float64
+}
+
+type errorOrder struct {
+	ID    int
+	Items []errorItem
+	ByKey map[string]errorItem
+}
+
+func TestDecodeErrorPath(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]interface{}{
+		"ID": 1,
+		"Items": []interface{}{
+			map[string]interface{}{"Name": "a", "Price": 1.5},
+			map[string]interface{}{"Name": "b", "Price": "xyz"},
+		},
+	})
+	require.Nil(t, err)
+	wantOffset := int64(bytes.Index(b, []byte("\xa3xyz")))
+
+	for _, dec := range []*msgpack.Decoder{
+		msgpack.NewDecoder(bytes.NewReader(b)),
+		msgpack.NewDecoder(io.MultiReader(bytes.NewReader(b))),
+	} {
+		var order errorOrder
+		err = dec.Decode(&order)
+
+		var de *msgpack.DecodeError
+		require.True(t, errors.As(err, &de))
+		require.Equal(t, "errorOrder.Items[1].Price", de.Path)
+		require.Equal(t, wantOffset, de.Offset)
+		require.Equal(t, reflect.TypeOf(float64(0)), de.Type)
+		require.Equal(t, 0xa3, de.Code)
+		require.EqualError(t, err, fmt.Sprintf(
+			"msgpack: decoding errorOrder.Items[1].Price at offset %d: invalid code=a3 decoding float64",
+			wantOffset))
+	}
+}
+
+func TestDecodeErrorMapPath(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]interface{}{
+		"ByKey": map[string]interface{}{
+			"foo": map[string]interface{}{"Name": 123},
+		},
+	})
+	require.Nil(t, err)
+
+	var order errorOrder
+	err = msgpack.Unmarshal(b, &order)
+
+	var de *msgpack.DecodeError
+	require.True(t, errors.As(err, &de))
+	require.Equal(t, "errorOrder.ByKey[foo].Name", de.Path)
+	require.Equal(t, reflect.TypeOf(""), de.Type)
+
+	var items []errorItem
+	b, err = msgpack.Marshal([]interface{}{nil, map[string]interface{}{"Price": true}})
+	require.Nil(t, err)
+	err = msgpack.Unmarshal(b, &items)
+	require.True(t, errors.As(err, &de))
+	require.Equal(t, "[1].Price", de.Path)
+	require.Equal(t, int(0xc3), de.Code)
+
+	var m map[string][]int
+	b, err = msgpack.Marshal(map[string]interface{}{"foo": []interface{}{1, "x"}})
+	require.Nil(t, err)
+	err = msgpack.Unmarshal(b, &m)
+	require.True(t, errors.As(err, &de))
+	require.Equal(t, "[foo][1]", de.Path)
+}
+
+func TestDecodeErrorUnknownField(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]interface{}{
+		"Items": []interface{}{map[string]interface{}{"Color": "red"}},
+	})
+	require.Nil(t, err)
+
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields(true)
+
+	var order errorOrder
+	err = dec.Decode(&order)
+	require.EqualError(t, err,
+		`msgpack: decoding errorOrder.Items[0] at offset 8: unknown field "Color"`)
+
+	var de *msgpack.DecodeError
+	require.True(t, errors.As(err, &de))
+	require.Equal(t, reflect.TypeOf(errorItem{}), de.Type)
+	require.Equal(t, -1, de.Code)
+}
+
+func TestDecodeErrorTopLevel(t *testing.T) {
+	b, err := msgpack.Marshal("foo")
+	require.Nil(t, err)
+
+	var n int
+	err = msgpack.Unmarshal(b, &n)
+	require.EqualError(t, err, "msgpack: invalid code=a3 decoding int64")
+
+	var de *msgpack.DecodeError
+	require.True(t, errors.As(err, &de))
+	require.Equal(t, "", de.Path)
+	require.Equal(t, int64(0), de.Offset)
+	require.Equal(t, 0xa3, de.Code)
+
+	dec := msgpack.NewDecoder(bytes.NewReader(nil))
+	require.Equal(t, io.EOF, dec.Decode(&n))
+}
+
+func TestDecodeErrorSentinels(t *testing.T) {
+	b, err := msgpack.Marshal(errorOrder{Items: []errorItem{{Name: "foo"}}})
+	require.Nil(t, err)
+
+	// Truncated input is reported as io.ErrUnexpectedEOF without a path,
+	// so callers can compare it with ==.
+	for _, dec := range []*msgpack.Decoder{
+		msgpack.NewDecoder(bytes.NewReader(b[:len(b)-3])),
+		msgpack.NewDecoder(io.MultiReader(bytes.NewReader(b[:len(b)-3]))),
+	} {
+		var order errorOrder
+		require.Equal(t, io.ErrUnexpectedEOF, dec.Decode(&order))
+	}
+
+	var order errorOrder
+	require.Equal(t, io.ErrUnexpectedEOF, msgpack.Unmarshal(b[:len(b)-3], &order))
+}
+
+func TestDecodeErrorDeepPath(t *testing.T) {
+	b := append(bytes.Repeat([]byte{0x91}, 100000), 0xc1)
+
+	var v interface{}
+	err := msgpack.Unmarshal(b, &v)
+
+	var de *msgpack.DecodeError
+	require.True(t, errors.As(err, &de))
+	require.Equal(t, "..."+strings.Repeat("[0]", 32), de.Path)
+	require.Equal(t, int64(100000), de.Offset)
+	require.EqualError(t, err, "msgpack: decoding "+de.Path+
+		" at offset 100000: unknown code c1 decoding interface{}")
+
+	type node struct {
+		Child *node
+		Value int
+	}
+	b, err = msgpack.Marshal(map[string]interface{}{"Value": "x"})
+	require.Nil(t, err)
+	for i := 0; i < 40; i++ {
+		b, err = msgpack.Marshal(map[string]msgpack.RawMessage{"Child": b})
+		require.Nil(t, err)
+	}
+	var root node
+	err = msgpack.Unmarshal(b, &root)
+	require.True(t, errors.As(err, &de))
+	require.Equal(t, "node..."+strings.Repeat("Child.", 31)+"Value", de.Path)
+}
+
+var errCustomDecode = errors.New("custom decode error")
+
+type failingDecoder struct{}
+
+func (failingDecoder) DecodeMsgpack(*msgpack.Decoder) error {
+	return errCustomDecode
+}
+
+func TestDecodeErrorUnwrap(t *testing.T) {
+	b, err := msgpack.Marshal([]int{1, 2})
+	require.Nil(t, err)
+
+	var dst []failingDecoder
+	err = msgpack.Unmarshal(b, &dst)
+	require.True(t, errors.Is(err, errCustomDecode))
+	require.EqualError(t, err, "msgpack: decoding [0] at offset 1: custom decode error")
+}
+
+func TestDecoderInputOffset(t *testing.T) {
+	b, err := msgpack.Marshal("hello")
+	require.Nil(t, err)
+	b = append(b, b...)
+
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	_, err = dec.DecodeString()
+	require.Nil(t, err)
+	require.Equal(t, int64(6), dec.InputOffset())
+
+	c, err := dec.PeekCode()
+	require.Nil(t, err)
+	require.Equal(t, byte(0xa5), c)
+	require.Equal(t, int64(6), dec.InputOffset())
+
+	_, err = dec.DecodeInterface()
+	require.Nil(t, err)
+	require.Equal(t, int64(12), dec.InputOffset())
+}
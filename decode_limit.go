@@ -0,0 +1,73 @@
+package msgpack
+
+import "fmt"
+
+// DecoderLimits caps the resources a Decoder may spend on its input.
+// It is meant for decoding untrusted data. Zero fields mean no limit.
+type DecoderLimits struct {
+	// MaxDepth is the maximum nesting depth of arrays and maps.
+	MaxDepth int
+	// MaxStringLen is the maximum length of a string, bin or ext payload.
+	MaxStringLen int
+	// MaxArrayLen is the maximum number of array elements.
+	MaxArrayLen int
+	// MaxMapLen is the maximum number of map entries.
+	MaxMapLen int
+	// MaxBytes is the maximum number of input bytes consumed
+	// since the last reset, see Decoder.InputOffset.
+	MaxBytes int64
+}
+
+// LimitError is returned when the input exceeds one of DecoderLimits
+// or the encoded value is nested deeper than Encoder.SetMaxDepth allows.
+type LimitError struct {
+	// Limit is the name of the exceeded DecoderLimits field, e.g. "MaxDepth".
+	Limit string
+	// Max is the configured limit.
+	Max int64
+	// Value is the depth, length or byte count that exceeds Max.
+	Value int64
+}
+
+func (err *LimitError) Error() string {
+	return fmt.Sprintf("msgpack: %s limit exceeded: %d > %d", err.Limit, err.Value, err.Max)
+}
+
+// SetLimits sets the limits enforced while decoding, including
+// by Skip, Query and DecodeRaw. The limits are cleared by Reset.
+func (d *Decoder) SetLimits(limits DecoderLimits) {
+	d.limits = limits
+}
+
+// enter must be called before decoding the elements of an array or a map
+// and paired with leave.
+func (d *Decoder) enter() error {
+	d.depth++
+	if max := d.limits.MaxDepth; max > 0 && d.depth > max {
+		d.depth--
+		return &LimitError{Limit: "MaxDepth", Max: int64(max), Value: int64(max + 1)}
+	}
+	return nil
+}
+
+func (d *Decoder) leave() {
+	d.depth--
+}
+
+func (d *Decoder) checkLen(limit string, max, n int) error {
+	if max > 0 && n > max {
+		return &LimitError{Limit: limit, Max: int64(max), Value: int64(n)}
+	}
+	return nil
+}
+
+// checkBytes reports whether n more bytes can be read without exceeding
+// DecoderLimits.MaxBytes.
+func (d *Decoder) checkBytes(n int) error {
+	if max := d.limits.MaxBytes; max > 0 {
+		if total := d.InputOffset() + int64(n); total > max {
+			return &LimitError{Limit: "MaxBytes", Max: max, Value: total}
+		}
+	}
+	return nil
+}
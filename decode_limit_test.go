@@ -0,0 +1,144 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func nestedArrays(depth int) []byte {
+	b := bytes.Repeat([]byte{0x91}, depth)
+	return append(b, 0xc0)
+}
+
+func requireLimitError(t *testing.T, err error, limit string) *msgpack.LimitError {
+	var lerr *msgpack.LimitError
+	require.True(t, errors.As(err, &lerr), "got %v", err)
+	require.Equal(t, limit, lerr.Limit)
+	return lerr
+}
+
+type limitNode struct {
+	Next *limitNode
+}
+
+func TestDecoderLimitsDepth(t *testing.T) {
+	limits := msgpack.DecoderLimits{MaxDepth: 10}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(nestedArrays(10)))
+	dec.SetLimits(limits)
+	_, err := dec.DecodeInterface()
+	require.Nil(t, err)
+
+	in := nestedArrays(1e6)
+	for _, fn := range []func(*msgpack.Decoder) error{
+		func(d *msgpack.Decoder) error { _, err := d.DecodeInterface(); return err },
+		func(d *msgpack.Decoder) error { return d.Skip() },
+		func(d *msgpack.Decoder) error { _, err := d.DecodeRaw(); return err },
+		func(d *msgpack.Decoder) error { _, err := d.Query("0.0.0"); return err },
+		func(d *msgpack.Decoder) error { var v []interface{}; return d.Decode(&v) },
+	} {
+		dec := msgpack.NewDecoder(bytes.NewReader(in))
+		dec.SetLimits(limits)
+		lerr := requireLimitError(t, fn(dec), "MaxDepth")
+		require.Equal(t, int64(10), lerr.Max)
+		require.Equal(t, int64(11), lerr.Value)
+	}
+
+	// Recursive struct types nest through pointers.
+	in = bytes.Repeat([]byte{0x81, 0xa4, 'N', 'e', 'x', 't'}, 100)
+	in = append(in, 0xc0)
+
+	dec = msgpack.NewDecoder(bytes.NewReader(in))
+	dec.SetLimits(limits)
+	var node limitNode
+	err = dec.Decode(&node)
+	requireLimitError(t, err, "MaxDepth")
+	require.EqualError(t, err, "msgpack: decoding limitNode.Next.Next.Next.Next.Next.Next.Next.Next.Next.Next"+
+		" at offset 60: MaxDepth limit exceeded: 11 > 10")
+}
+
+func TestDecoderLimitsLen(t *testing.T) {
+	tests := []struct {
+		limits msgpack.DecoderLimits
+		in     interface{}
+		limit  string
+	}{
+		{msgpack.DecoderLimits{MaxArrayLen: 3}, []int{1, 2, 3, 4}, "MaxArrayLen"},
+		{msgpack.DecoderLimits{MaxArrayLen: 300}, make([]int, 301), "MaxArrayLen"},
+		{msgpack.DecoderLimits{MaxMapLen: 1}, map[string]int{"a": 1, "b": 2}, "MaxMapLen"},
+		{msgpack.DecoderLimits{MaxStringLen: 3}, "hello", "MaxStringLen"},
+		{msgpack.DecoderLimits{MaxStringLen: 3}, []byte("hello"), "MaxStringLen"},
+		{msgpack.DecoderLimits{MaxStringLen: 3}, []interface{}{"a", strings.Repeat("x", 1e5)}, "MaxStringLen"},
+		{msgpack.DecoderLimits{MaxStringLen: 7}, map[string]interface{}{"t": time.Unix(1, 1)}, "MaxStringLen"},
+	}
+	for _, test := range tests {
+		b, err := msgpack.Marshal(test.in)
+		require.Nil(t, err)
+
+		for _, fn := range []func(*msgpack.Decoder) error{
+			func(d *msgpack.Decoder) error { _, err := d.DecodeInterface(); return err },
+			func(d *msgpack.Decoder) error { return d.Skip() },
+			func(d *msgpack.Decoder) error { _, err := d.DecodeRaw(); return err },
+		} {
+			dec := msgpack.NewDecoder(bytes.NewReader(b))
+			dec.SetLimits(test.limits)
+			requireLimitError(t, fn(dec), test.limit)
+
+			dec.ResetBytes(b, false)
+			requireLimitError(t, fn(dec), test.limit)
+
+			dec.Reset(bytes.NewReader(b))
+			require.Nil(t, fn(dec))
+		}
+	}
+}
+
+func TestDecoderLimitsInternedString(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseInternedStrings(true)
+	require.Nil(t, enc.Encode("hello world"))
+
+	dec := msgpack.NewDecoder(&buf)
+	dec.UseInternedStrings(true)
+	dec.SetLimits(msgpack.DecoderLimits{MaxStringLen: 5})
+	_, err := dec.DecodeString()
+	requireLimitError(t, err, "MaxStringLen")
+}
+
+func TestDecoderLimitsBytes(t *testing.T) {
+	b, err := msgpack.Marshal([]string{"foo", "bar", "baz"})
+	require.Nil(t, err)
+	require.Equal(t, 13, len(b))
+
+	for _, reset := range []func(*msgpack.Decoder){
+		func(d *msgpack.Decoder) { d.ResetReader(bytes.NewReader(b)) },
+		func(d *msgpack.Decoder) { d.ResetBytes(b, false) },
+	} {
+		dec := msgpack.NewDecoder(nil)
+
+		dec.SetLimits(msgpack.DecoderLimits{MaxBytes: 12})
+		reset(dec)
+		var v []string
+		err = dec.Decode(&v)
+		lerr := requireLimitError(t, err, "MaxBytes")
+		require.Equal(t, int64(13), lerr.Value)
+
+		dec.SetLimits(msgpack.DecoderLimits{MaxBytes: 13})
+		reset(dec)
+		require.Nil(t, dec.Decode(&v))
+		require.Equal(t, []string{"foo", "bar", "baz"}, v)
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.SetLimits(msgpack.DecoderLimits{MaxBytes: 5})
+	_, err = dec.Query("2")
+	requireLimitError(t, err, "MaxBytes")
+}
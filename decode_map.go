@@ -23,6 +23,11 @@ var (
 )
 
 func decodeMapValue(d *Decoder, v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
 	n, err := d.DecodeMapLen()
 	if err != nil {
 		return err
@@ -76,6 +81,17 @@ func (d *Decoder) DecodeMapLen() (int, error) {
 }
 
 func (d *Decoder) mapLen(c byte) (int, error) {
+	n, err := d.parseMapLen(c)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.checkLen("MaxMapLen", d.limits.MaxMapLen, n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (d *Decoder) parseMapLen(c byte) (int, error) {
 	if c == msgpcode.Nil {
 		return -1, nil
 	}
@@ -99,6 +115,11 @@ func decodeMapStringStringValue(d *Decoder, v reflect.Value) error {
 }
 
 func (d *Decoder) decodeMapStringStringPtr(ptr *map[string]string) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
 	size, err := d.DecodeMapLen()
 	if err != nil {
 		return err
@@ -123,9 +144,10 @@ func (d *Decoder) decodeMapStringStringPtr(ptr *map[string]string) error {
 		if err != nil {
 			return err
 		}
+		offset := d.InputOffset()
 		mv, err := d.DecodeString()
 		if err != nil {
-			return err
+			return d.indexError(err, offset, stringType, mk)
 		}
 		m[mk] = mv
 	}
@@ -148,6 +170,11 @@ func (d *Decoder) decodeMapStringInterfacePtr(ptr *map[string]interface{}) error
 }
 
 func (d *Decoder) DecodeMap() (map[string]interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
 	n, err := d.DecodeMapLen()
 	if err != nil {
 		return nil, err
@@ -164,9 +191,10 @@ func (d *Decoder) DecodeMap() (map[string]interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
+		offset := d.InputOffset()
 		mv, err := d.decodeInterfaceCond()
 		if err != nil {
-			return nil, err
+			return nil, d.indexError(err, offset, interfaceType, mk)
 		}
 		m[mk] = mv
 	}
@@ -175,6 +203,11 @@ func (d *Decoder) DecodeMap() (map[string]interface{}, error) {
 }
 
 func (d *Decoder) DecodeUntypedMap() (map[interface{}]interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
 	n, err := d.DecodeMapLen()
 	if err != nil {
 		return nil, err
@@ -192,9 +225,10 @@ func (d *Decoder) DecodeUntypedMap() (map[interface{}]interface{}, error) {
 			return nil, err
 		}
 
+		offset := d.InputOffset()
 		mv, err := d.decodeInterfaceCond()
 		if err != nil {
-			return nil, err
+			return nil, d.indexError(err, offset, interfaceType, fmt.Sprint(mk))
 		}
 
 		m[mk] = mv
@@ -206,6 +240,11 @@ func (d *Decoder) DecodeUntypedMap() (map[interface{}]interface{}, error) {
 // DecodeTypedMap decodes a typed map. Typed map is a map that has a fixed type for keys and values.
 // Key and value types may be different.
 func (d *Decoder) DecodeTypedMap() (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
 	n, err := d.DecodeMapLen()
 	if err != nil {
 		return nil, err
@@ -261,9 +300,10 @@ func (d *Decoder) decodeTypedMapValue(v reflect.Value, n int) error {
 			return err
 		}
 
+		offset := d.InputOffset()
 		mv := d.newValue(valueType).Elem()
 		if err := d.DecodeValue(mv); err != nil {
-			return err
+			return d.indexError(err, offset, valueType, fmt.Sprint(mk.Interface()))
 		}
 
 		v.SetMapIndex(mk, mv)
@@ -273,6 +313,11 @@ func (d *Decoder) decodeTypedMapValue(v reflect.Value, n int) error {
 }
 
 func (d *Decoder) skipMap(c byte) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
 	n, err := d.mapLen(c)
 	if err != nil {
 		return err
@@ -289,6 +334,16 @@ func (d *Decoder) skipMap(c byte) error {
 }
 
 func decodeStructValue(d *Decoder, v reflect.Value) error {
+	fields := d.registry().structFields(v.Type(), d.structTag)
+	return d.decodeStructFields(v, fields)
+}
+
+func (d *Decoder) decodeStructFields(v reflect.Value, fields *fields) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
 	c, err := d.readCode()
 	if err != nil {
 		return err
@@ -296,61 +351,125 @@ func decodeStructValue(d *Decoder, v reflect.Value) error {
 
 	n, err := d.mapLen(c)
 	if err == nil {
-		return d.decodeStruct(v, n)
+		return d.decodeStruct(v, n, fields)
 	}
 
 	var err2 error
 	n, err2 = d.arrayLen(c)
 	if err2 != nil {
+		if _, ok := err2.(*LimitError); ok {
+			return err2
+		}
 		return err
 	}
 
 	if n <= 0 {
 		v.Set(reflect.Zero(v.Type()))
-		return nil
+		if n == -1 {
+			return nil
+		}
 	}
 
-	fields := structs.Fields(v.Type(), d.structTag)
-	if n != len(fields.List) {
+	// Arrays shorter than the struct are written by older versions
+	// of the struct: the trailing fields are absent if they are optional.
+	if n > len(fields.List) || n > 0 && n < len(fields.List) && !fields.canBeAbsent(n) {
 		return errArrayStruct
 	}
 
-	for _, f := range fields.List {
+	for _, f := range fields.List[:n] {
+		offset := d.InputOffset()
 		if err := f.DecodeValue(d, v); err != nil {
-			return err
+			return d.fieldError(err, offset, v.Type(), f)
 		}
 	}
 
+	if len(fields.tracked) > 0 {
+		seen := make([]bool, len(fields.tracked))
+		for _, f := range fields.List[:n] {
+			if f.isTracked() {
+				seen[f.trackedIndex] = true
+			}
+		}
+		return fields.setAbsent(v, seen)
+	}
 	return nil
 }
 
-func (d *Decoder) decodeStruct(v reflect.Value, n int) error {
+func (d *Decoder) decodeStruct(v reflect.Value, n int, fields *fields) error {
 	if n == -1 {
 		v.Set(reflect.Zero(v.Type()))
 		return nil
 	}
 
-	fields := structs.Fields(v.Type(), d.structTag)
+	var seen []bool
+	if len(fields.tracked) > 0 {
+		seen = make([]bool, len(fields.tracked))
+	}
+
 	for i := 0; i < n; i++ {
 		name, err := d.decodeStringTemp()
 		if err != nil {
 			return err
 		}
-
-		if f := fields.Map[name]; f != nil {
-			if err := f.DecodeValue(d, v); err != nil {
-				return err
-			}
-			continue
+		if err := d.decodeStructField(v, fields, name, seen); err != nil {
+			return err
 		}
+	}
+
+	if seen != nil {
+		return fields.setAbsent(v, seen)
+	}
+	return nil
+}
 
-		if d.flags&disallowUnknownFieldsFlag != 0 {
-			return fmt.Errorf("msgpack: unknown field %q", name)
+// decodeStructField decodes the value of the map entry with the name.
+// Tracked fields are marked in seen.
+func (d *Decoder) decodeStructField(v reflect.Value, fields *fields, name string, seen []bool) error {
+	if f := fields.Map[name]; f != nil {
+		if f.isTracked() {
+			seen[f.trackedIndex] = true
 		}
-		if err := d.Skip(); err != nil {
-			return err
+		offset := d.InputOffset()
+		if err := f.DecodeValue(d, v); err != nil {
+			return d.fieldError(err, offset, v.Type(), f)
 		}
+		return nil
+	}
+
+	if fields.remain != nil {
+		return d.decodeRemainEntry(v, fields.remain, name)
 	}
+	return d.SkipUnknownField(name)
+}
+
+// SkipUnknownField skips the value of the map entry with the name that doesn't
+// match any struct field or returns an error if DisallowUnknownFields is set.
+// It is meant for CustomDecoder implementations such as the ones generated
+// by msgpackgen.
+func (d *Decoder) SkipUnknownField(name string) error {
+	if d.flags&disallowUnknownFieldsFlag != 0 {
+		return fmt.Errorf("msgpack: unknown field %q", name)
+	}
+	return d.Skip()
+}
 
+// decodeRemainEntry decodes the value of the map entry with the name
+// that doesn't match a field into the remain field.
+func (d *Decoder) decodeRemainEntry(strct reflect.Value, f *field, name string) error {
+	m := fieldByIndexAlloc(strct, f.index)
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+
+	// The name may point to the decoder buffer, so it is copied
+	// before the value is decoded.
+	key := reflect.ValueOf(string([]byte(name))).Convert(m.Type().Key())
+
+	offset := d.InputOffset()
+	elem := d.newValue(m.Type().Elem()).Elem()
+	if err := d.DecodeValue(elem); err != nil {
+		return d.fieldError(err, offset, strct.Type(), f)
+	}
+	m.SetMapIndex(key, elem)
 	return nil
 }
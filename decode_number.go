@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"strconv"
 
 	"github.com/vmihailenco/msgpack/v5/msgpcode"
 )
@@ -116,7 +117,7 @@ func (d *Decoder) uint(c byte) (uint64, error) {
 	case msgpcode.Uint64, msgpcode.Int64:
 		return d.uint64()
 	}
-	return 0, fmt.Errorf("msgpack: invalid code=%x decoding uint64", c)
+	return 0, invalidCodeError{code: c, hint: "uint64"}
 }
 
 // DecodeInt64 decodes msgpack int8/16/32/64 and uint8/16/32/64
@@ -159,7 +160,7 @@ func (d *Decoder) int(c byte) (int64, error) {
 		n, err := d.uint64()
 		return int64(n), err
 	}
-	return 0, fmt.Errorf("msgpack: invalid code=%x decoding int64", c)
+	return 0, invalidCodeError{code: c, hint: "int64"}
 }
 
 func (d *Decoder) DecodeFloat32() (float32, error) {
@@ -181,7 +182,7 @@ func (d *Decoder) float32(c byte) (float32, error) {
 
 	n, err := d.int(c)
 	if err != nil {
-		return 0, fmt.Errorf("msgpack: invalid code=%x decoding float32", c)
+		return 0, invalidCodeError{code: c, hint: "float32"}
 	}
 	return float32(n), nil
 }
@@ -213,7 +214,7 @@ func (d *Decoder) float64(c byte) (float64, error) {
 
 	n, err := d.int(c)
 	if err != nil {
-		return 0, fmt.Errorf("msgpack: invalid code=%x decoding float32", c)
+		return 0, invalidCodeError{code: c, hint: "float64"}
 	}
 	return float64(n), nil
 }
@@ -293,3 +294,76 @@ func decodeUint64Value(d *Decoder, v reflect.Value) error {
 	v.SetUint(n)
 	return nil
 }
+
+// decodeNumberStringValue decodes bool and number values encoded as strings
+// by the string tag option. The regular encodings are accepted too.
+func decodeNumberStringValue(d *Decoder, v reflect.Value) error {
+	c, err := d.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if !msgpcode.IsString(c) && !msgpcode.IsExt(c) {
+		switch v.Kind() {
+		case reflect.Bool:
+			return decodeBoolValue(d, v)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return decodeInt64Value(d, v)
+		case reflect.Float32:
+			return decodeFloat32Value(d, v)
+		case reflect.Float64:
+			return decodeFloat64Value(d, v)
+		default:
+			return decodeUint64Value(d, v)
+		}
+	}
+
+	// Interned strings are encoded as ext.
+	s, err := d.DecodeString()
+	if err != nil {
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("msgpack: %w", err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("msgpack: %w", err)
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("msgpack: %w", err)
+		}
+		v.SetFloat(f)
+	default:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("msgpack: %w", err)
+		}
+		v.SetUint(n)
+	}
+	return nil
+}
+
+// decodeNumberStringPtrValue decodes pointers to bool and number values
+// for the string tag option. Nil is decoded as a nil pointer.
+func decodeNumberStringPtrValue(d *Decoder, v reflect.Value) error {
+	if d.hasNilCode() {
+		if !v.IsNil() {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return d.DecodeNil()
+	}
+	if v.IsNil() {
+		v.Set(d.newValue(v.Type().Elem()))
+	}
+	return decodeNumberStringValue(d, v.Elem())
+}
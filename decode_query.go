@@ -2,96 +2,309 @@ package msgpack
 
 import (
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 
 	"github.com/vmihailenco/msgpack/v5/msgpcode"
 )
 
-type queryResult struct {
-	query       string
-	key         string
-	values      []interface{}
-	hasAsterisk bool
+type queryOp uint8
+
+const (
+	queryKey queryOp = iota
+	queryWildcard
+	querySlice
+	queryFilter
+)
+
+type queryStep struct {
+	op        queryOp
+	recursive bool
+	// strict is set when all steps up to and including this one are plain
+	// keys, so a value that can't be indexed is an error rather than a miss.
+	strict bool
+
+	key     string
+	index   int
+	isIndex bool
+
+	start, end       int
+	hasStart, hasEnd bool
+
+	filter *queryPredicate
 }
 
-func (q *queryResult) nextKey() {
-	ind := strings.IndexByte(q.query, '.')
-	if ind == -1 {
-		q.key = q.query
-		q.query = ""
-		return
+// matchKey reports whether the step selects the map entry with the key.
+// String keys are passed in skey with key set to nil.
+func (st *queryStep) matchKey(skey string, key interface{}) bool {
+	switch st.op {
+	case queryWildcard:
+		return true
+	case queryKey:
+		switch key := key.(type) {
+		case nil:
+			return skey == st.key
+		case string:
+			return key == st.key
+		case int64:
+			return st.isIndex && key == int64(st.index)
+		case uint64:
+			return st.isIndex && st.index >= 0 && key == uint64(st.index)
+		}
 	}
-	q.key = q.query[:ind]
-	q.query = q.query[ind+1:]
+	return false
 }
 
-// Query extracts data specified by the query from the msgpack stream skipping
-// any other data. Query consists of map keys and array indexes separated with dot,
-// e.g. key1.0.key2.
-func (d *Decoder) Query(query string) ([]interface{}, error) {
-	res := queryResult{
-		query: query,
+// matchIndex reports whether the step selects the element i of an array of length n.
+func (st *queryStep) matchIndex(i, n int) bool {
+	switch st.op {
+	case queryWildcard:
+		return true
+	case queryKey:
+		if !st.isIndex {
+			return false
+		}
+		index := st.index
+		if index < 0 {
+			index += n
+		}
+		return i == index
+	case querySlice:
+		start, end := 0, n
+		if st.hasStart {
+			start = sliceBound(st.start, n)
+		}
+		if st.hasEnd {
+			end = sliceBound(st.end, n)
+		}
+		return i >= start && i < end
 	}
-	if err := d.query(&res); err != nil {
-		return nil, err
+	return false
+}
+
+// lastIndex returns the last array index that the step can select.
+func (st *queryStep) lastIndex(n int) int {
+	if st.recursive {
+		return n - 1
+	}
+	switch st.op {
+	case queryKey:
+		if !st.isIndex {
+			return -1
+		}
+		if st.index < 0 {
+			return st.index + n
+		}
+		return st.index
+	case querySlice:
+		if st.hasEnd {
+			return sliceBound(st.end, n) - 1
+		}
 	}
-	return res.values, nil
+	return n - 1
 }
 
-func (d *Decoder) query(q *queryResult) error {
-	q.nextKey()
-	if q.key == "" {
-		v, err := d.decodeInterfaceCond()
-		if err != nil {
+func sliceBound(i, n int) int {
+	if i < 0 {
+		i += n
+		if i < 0 {
+			return 0
+		}
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+type queryPath struct {
+	expr  string
+	steps []queryStep
+	// multi is set when the path can select more than one value.
+	multi bool
+}
+
+type queryState struct {
+	path int
+	step int
+}
+
+// queryExec matches a set of paths against a single pass over the input.
+// States are kept on a stack shared by all levels of the traversal.
+type queryExec struct {
+	paths  []*queryPath
+	states []queryState
+	emit   func(d *Decoder, path int) error
+}
+
+func (q *queryExec) run(d *Decoder) error {
+	if q.states == nil {
+		q.states = make([]queryState, 0, 16)
+	}
+	q.states = q.states[:0]
+	for i := range q.paths {
+		q.states = append(q.states, queryState{path: i})
+	}
+	return q.exec(d, q.states, true)
+}
+
+func (q *queryExec) step(s queryState) *queryStep {
+	return &q.paths[s.path].steps[s.step]
+}
+
+func (q *queryExec) done(s queryState) bool {
+	return s.step == len(q.paths[s.path].steps)
+}
+
+func (q *queryExec) push(mark int, s queryState) {
+	for _, s2 := range q.states[mark:] {
+		if s2 == s {
+			return
+		}
+	}
+	q.states = append(q.states, s)
+}
+
+// exec applies the states to the next value in d. If final is set,
+// nothing is read from d after the value, so the traversal may stop
+// as soon as no more matches are possible without consuming the rest.
+func (q *queryExec) exec(d *Decoder, states []queryState, final bool) error {
+	mark := len(q.states)
+	matched, path := 0, 0
+	for _, s := range states {
+		if q.done(s) {
+			matched++
+			path = s.path
+		} else {
+			q.states = append(q.states, s)
+		}
+	}
+	next := q.states[mark:]
+
+	var err error
+	switch {
+	case matched == 0:
+		err = q.descend(d, next, final)
+	case matched == 1 && len(next) == 0:
+		err = q.emit(d, path)
+	default:
+		err = q.execShared(d, states, next)
+	}
+	q.states = q.states[:mark]
+	return err
+}
+
+// execShared handles values that are needed by several states.
+func (q *queryExec) execShared(d *Decoder, states, next []queryState) error {
+	raw, err := d.readRaw(0)
+	if err != nil {
+		return err
+	}
+	for _, s := range states {
+		if !q.done(s) {
+			continue
+		}
+		if err := q.execRaw(d, raw, nil, s.path); err != nil {
 			return err
 		}
-		q.values = append(q.values, v)
-		return nil
 	}
+	if len(next) > 0 {
+		return q.execRaw(d, raw, next, -1)
+	}
+	return nil
+}
 
-	code, err := d.PeekCode()
+// execRaw emits raw for the path or applies the states to raw.
+func (q *queryExec) execRaw(d *Decoder, raw []byte, states []queryState, path int) error {
+	sub := d.subDecoder(raw)
+	var err error
+	if states == nil {
+		err = q.emit(sub, path)
+	} else {
+		err = q.exec(sub, states, true)
+	}
+	PutDecoder(sub)
+	return err
+}
+
+func (q *queryExec) descend(d *Decoder, states []queryState, final bool) error {
+	c, err := d.PeekCode()
 	if err != nil {
 		return err
 	}
 
 	switch {
-	case code == msgpcode.Map16 || code == msgpcode.Map32 || msgpcode.IsFixedMap(code):
-		err = d.queryMapKey(q)
-	case code == msgpcode.Array16 || code == msgpcode.Array32 || msgpcode.IsFixedArray(code):
-		err = d.queryArrayIndex(q)
-	default:
-		err = fmt.Errorf("msgpack: unsupported code=%x decoding key=%q", code, q.key)
+	case msgpcode.IsFixedMap(c) || c == msgpcode.Map16 || c == msgpcode.Map32:
+		return q.descendMap(d, states, final)
+	case msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32:
+		return q.descendArray(d, states, final)
 	}
-	return err
+
+	if c != msgpcode.Nil {
+		for _, s := range states {
+			if st := q.step(s); st.strict {
+				return fmt.Errorf("msgpack: unsupported code=%x decoding key=%q", c, st.key)
+			}
+		}
+	}
+	return d.Skip()
 }
 
-func (d *Decoder) queryMapKey(q *queryResult) error {
+func (q *queryExec) descendMap(d *Decoder, states []queryState, final bool) error {
 	n, err := d.DecodeMapLen()
 	if err != nil {
 		return err
 	}
-	if n == -1 {
-		return nil
+
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	// Stop reading the map once every key has been found.
+	live := 0
+	for _, s := range states {
+		if st := q.step(s); st.recursive || st.op != queryKey {
+			live = -1
+			break
+		}
+		live++
 	}
 
 	for i := 0; i < n; i++ {
-		key, err := d.decodeStringTemp()
+		if live == 0 {
+			if final {
+				return nil
+			}
+			return d.skipNext((n - i) * 2)
+		}
+
+		skey, key, err := d.queryKey()
 		if err != nil {
 			return err
 		}
 
-		if key == q.key {
-			if err := d.query(q); err != nil {
-				return err
+		mark := len(q.states)
+		var pending bool
+		for _, s := range states {
+			st := q.step(s)
+			if st.recursive {
+				q.push(mark, s)
 			}
-			if q.hasAsterisk {
-				return d.skipNext((n - i - 1) * 2)
+			if st.op == queryFilter {
+				pending = true
+			} else if st.matchKey(skey, key) {
+				q.push(mark, queryState{path: s.path, step: s.step + 1})
+				if live > 0 {
+					live--
+				}
 			}
-			return nil
 		}
 
-		if err := d.Skip(); err != nil {
+		err = q.visit(d, states, mark, pending, final && live == 0)
+		q.states = q.states[:mark]
+		if err != nil {
 			return err
 		}
 	}
@@ -99,59 +312,722 @@ func (d *Decoder) queryMapKey(q *queryResult) error {
 	return nil
 }
 
-func (d *Decoder) queryArrayIndex(q *queryResult) error {
+func (d *Decoder) skipNext(n int) error {
+	for i := 0; i < n; i++ {
+		if err := d.Skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) queryKey() (string, interface{}, error) {
+	c, err := d.PeekCode()
+	if err != nil {
+		return "", nil, err
+	}
+	if msgpcode.IsString(c) || msgpcode.IsBin(c) {
+		s, err := d.decodeStringTemp()
+		return s, nil, err
+	}
+	key, err := d.DecodeInterfaceLoose()
+	if key == nil && err == nil {
+		// Don't confuse nil keys with string keys.
+		key = struct{}{}
+	}
+	return "", key, err
+}
+
+func (q *queryExec) descendArray(d *Decoder, states []queryState, final bool) error {
 	n, err := d.DecodeArrayLen()
 	if err != nil {
 		return err
 	}
-	if n == -1 {
+
+	for _, s := range states {
+		if st := q.step(s); st.strict && !st.isIndex {
+			return fmt.Errorf("msgpack: invalid array index=%q", st.key)
+		}
+	}
+
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	// Stop reading the array after the last element that can match.
+	last := -1
+	for _, s := range states {
+		if i := q.step(s).lastIndex(n); i > last {
+			last = i
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if i > last {
+			if final {
+				return nil
+			}
+			return d.skipNext(n - i)
+		}
+
+		mark := len(q.states)
+		var pending bool
+		for _, s := range states {
+			st := q.step(s)
+			if st.recursive {
+				q.push(mark, s)
+			}
+			if st.op == queryFilter {
+				pending = true
+			} else if st.matchIndex(i, n) {
+				q.push(mark, queryState{path: s.path, step: s.step + 1})
+			}
+		}
+
+		err = q.visit(d, states, mark, pending, final && i == last)
+		q.states = q.states[:mark]
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// visit applies the states pushed after mark to the next value in d.
+// If some of the parent states are filters, the value is read once
+// and the filters are evaluated before descending into it.
+func (q *queryExec) visit(d *Decoder, parent []queryState, mark int, pending, final bool) error {
+	if !pending {
+		if len(q.states) == mark {
+			return d.Skip()
+		}
+		return q.exec(d, q.states[mark:], final)
+	}
+
+	raw, err := d.readRaw(0)
+	if err != nil {
+		return err
+	}
+	for _, s := range parent {
+		st := q.step(s)
+		if st.op != queryFilter {
+			continue
+		}
+		ok, err := st.filter.match(d, raw)
+		if err != nil {
+			return err
+		}
+		if ok {
+			q.push(mark, queryState{path: s.path, step: s.step + 1})
+		}
+	}
+	if len(q.states) == mark {
 		return nil
 	}
+	return q.execRaw(d, raw, q.states[mark:], -1)
+}
+
+// subDecoder returns a decoder that reads b with the same options as d.
+// The caller must release it with PutDecoder.
+func (d *Decoder) subDecoder(b []byte) *Decoder {
+	sub := GetDecoder()
+	sub.ResetBytes(b, true)
+	sub.flags = d.flags
+	sub.structTag = d.structTag
+	sub.timeFormat = d.timeFormat
+	sub.reg = d.reg
+	sub.mapDecoder = d.mapDecoder
+	sub.dict = d.dict
+	sub.limits = d.limits
+	sub.limits.MaxBytes = 0
+	sub.depth = d.depth
+	return sub
+}
+
+//------------------------------------------------------------------------------
 
-	if q.key == "*" {
-		q.hasAsterisk = true
+// queryPredicate is a filter such as ?(@.status=="ok").
+type queryPredicate struct {
+	path  *queryPath
+	op    string
+	value interface{}
+}
 
-		query := q.query
-		for i := 0; i < n; i++ {
-			q.query = query
-			if err := d.query(q); err != nil {
+func (p *queryPredicate) match(d *Decoder, raw []byte) (bool, error) {
+	var matched bool
+	q := &queryExec{
+		paths: []*queryPath{p.path},
+		emit: func(d *Decoder, _ int) error {
+			if matched {
+				return d.Skip()
+			}
+			v, err := d.DecodeInterfaceLoose()
+			if err != nil {
 				return err
 			}
+			matched = p.op == "" || compareQueryValue(v, p.op, p.value)
+			return nil
+		},
+	}
+
+	sub := d.subDecoder(raw)
+	err := q.run(sub)
+	PutDecoder(sub)
+	return matched, err
+}
+
+func compareQueryValue(v interface{}, op string, lit interface{}) bool {
+	var cmp int
+	switch lit := lit.(type) {
+	case nil:
+		if v != nil {
+			return op == "!="
+		}
+	case bool:
+		b, ok := v.(bool)
+		if !ok || b != lit {
+			return op == "!="
 		}
+	case string:
+		s, ok := v.(string)
+		if !ok {
+			return op == "!="
+		}
+		cmp = strings.Compare(s, lit)
+	case float64:
+		var f float64
+		switch v := v.(type) {
+		case int64:
+			f = float64(v)
+		case uint64:
+			f = float64(v)
+		case float64:
+			f = v
+		default:
+			return op == "!="
+		}
+		switch {
+		case f < lit:
+			cmp = -1
+		case f > lit:
+			cmp = 1
+		}
+	}
 
-		q.hasAsterisk = false
-		return nil
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
 	}
+	switch lit.(type) {
+	case string, float64:
+	default:
+		return false
+	}
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+//------------------------------------------------------------------------------
 
-	ind, err := strconv.Atoi(q.key)
+type queryParser struct {
+	s   string
+	pos int
+}
+
+func parseQuery(s string) (*queryPath, error) {
+	p := &queryParser{s: s}
+	steps, err := p.parseSteps(true, false)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
 	}
 
-	for i := 0; i < n; i++ {
-		if i == ind {
-			if err := d.query(q); err != nil {
+	strict := true
+	for i := range steps {
+		st := &steps[i]
+		strict = strict && st.op == queryKey && !st.recursive
+		st.strict = strict
+	}
+
+	return &queryPath{expr: s, steps: steps, multi: !strict}, nil
+}
+
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("msgpack: invalid query %q at offset %d: %s",
+		p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *queryParser) skipSpaces() {
+	for p.peek() == ' ' {
+		p.pos++
+	}
+}
+
+func (p *queryParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// parseSteps parses steps until the end of the query or a character that
+// can't start a step. A query may start with a key without a leading dot.
+func (p *queryParser) parseSteps(root, inFilter bool) ([]queryStep, error) {
+	var steps []queryStep
+	for p.pos < len(p.s) {
+		var recursive bool
+		switch c := p.s[p.pos]; {
+		case strings.HasPrefix(p.s[p.pos:], ".."):
+			p.pos += 2
+			recursive = true
+		case c == '.':
+			p.pos++
+		case c == '[':
+		default:
+			if !root || len(steps) > 0 {
+				return steps, nil
+			}
+		}
+
+		var st queryStep
+		var err error
+		if p.peek() == '[' {
+			st, err = p.parseBracket()
+		} else {
+			st, err = p.parseName(inFilter)
+		}
+		if err != nil {
+			return nil, err
+		}
+		st.recursive = recursive
+		steps = append(steps, st)
+	}
+	return steps, nil
+}
+
+func (p *queryParser) parseName(inFilter bool) (queryStep, error) {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == '.' || c == '[' || (inFilter && strings.IndexByte(" =!<>)", c) >= 0) {
+			break
+		}
+		p.pos++
+	}
+
+	name := p.s[start:p.pos]
+	if name == "" {
+		return queryStep{}, p.errorf("expected key")
+	}
+	if name == "*" {
+		return queryStep{op: queryWildcard}, nil
+	}
+	return newQueryKey(name), nil
+}
+
+func newQueryKey(key string) queryStep {
+	st := queryStep{op: queryKey, key: key}
+	if isQueryIndex(key) {
+		if n, err := strconv.Atoi(key); err == nil {
+			st.index = n
+			st.isIndex = true
+		}
+	}
+	return st
+}
+
+func isQueryIndex(s string) bool {
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	}
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *queryParser) parseBracket() (queryStep, error) {
+	p.pos++ // [
+
+	var st queryStep
+	switch p.peek() {
+	case '*':
+		p.pos++
+		st.op = queryWildcard
+	case '"', '\'':
+		s, err := p.parseString()
+		if err != nil {
+			return st, err
+		}
+		st = queryStep{op: queryKey, key: s}
+	case '?':
+		p.pos++
+		if err := p.expect('('); err != nil {
+			return st, err
+		}
+		filter, err := p.parseFilter()
+		if err != nil {
+			return st, err
+		}
+		if err := p.expect(')'); err != nil {
+			return st, err
+		}
+		st = queryStep{op: queryFilter, filter: filter}
+	default:
+		end := strings.IndexByte(p.s[p.pos:], ']')
+		if end == -1 {
+			return st, p.errorf("expected %q", ']')
+		}
+		s := p.s[p.pos : p.pos+end]
+		if i := strings.IndexByte(s, ':'); i >= 0 {
+			var err error
+			st.op = querySlice
+			st.start, st.hasStart, err = p.parseBound(s[:i])
+			if err != nil {
+				return st, err
+			}
+			st.end, st.hasEnd, err = p.parseBound(s[i+1:])
+			if err != nil {
+				return st, err
+			}
+		} else {
+			st = newQueryKey(s)
+			if !st.isIndex {
+				return st, p.errorf("invalid index %q", s)
+			}
+		}
+		p.pos += end
+	}
+
+	if err := p.expect(']'); err != nil {
+		return st, err
+	}
+	return st, nil
+}
+
+func (p *queryParser) parseBound(s string) (int, bool, error) {
+	if s == "" {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, false, p.errorf("invalid slice bound %q", s)
+	}
+	return n, true, nil
+}
+
+func (p *queryParser) parseString() (string, error) {
+	quote := p.s[p.pos]
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case '\\':
+			p.pos += 2
+			continue
+		case quote:
+			p.pos++
+			s := p.s[start:p.pos]
+			if quote == '\'' {
+				s = `"` + strings.ReplaceAll(strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`), `"`, `\"`) + `"`
+			}
+			unquoted, err := strconv.Unquote(s)
+			if err != nil {
+				return "", p.errorf("invalid string %s", p.s[start:p.pos])
+			}
+			return unquoted, nil
+		}
+		p.pos++
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *queryParser) parseFilter() (*queryPredicate, error) {
+	p.skipSpaces()
+	if err := p.expect('@'); err != nil {
+		return nil, err
+	}
+	start := p.pos
+	steps, err := p.parseSteps(false, true)
+	if err != nil {
+		return nil, err
+	}
+	pred := &queryPredicate{
+		path: &queryPath{expr: p.s[start:p.pos], steps: steps},
+	}
+
+	p.skipSpaces()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(p.s[p.pos:], op) {
+			pred.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if pred.op == "" {
+		return pred, nil
+	}
+
+	p.skipSpaces()
+	pred.value, err = p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	return pred, nil
+}
+
+func (p *queryParser) parseLiteral() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case strings.HasPrefix(p.s[p.pos:], "true"):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(p.s[p.pos:], "false"):
+		p.pos += 5
+		return false, nil
+	case strings.HasPrefix(p.s[p.pos:], "null"):
+		p.pos += 4
+		return nil, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte("+-.0123456789eE", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		return nil, p.errorf("invalid literal %q", p.s[start:p.pos])
+	}
+	return f, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Query extracts data specified by the query from the msgpack stream skipping
+// any other data. Query consists of map keys and array indexes separated with dot,
+// e.g. key1.0.key2. The following syntax is also supported:
+//
+//   - * or [*] selects all array elements or map values;
+//   - ..key selects key at any depth;
+//   - [-1] counts array indexes from the end;
+//   - [1:3] selects a range of array elements, bounds may be omitted or negative;
+//   - ["some.key"] or ['some.key'] selects a key that contains special
+//     characters, e.g. ["a[0].b"] selects the key "a[0].b";
+//   - [?(@.status=="ok")] selects array elements or map values that
+//     match the predicate. Supported operators are ==, !=, <, <=, > and >=
+//     with string, number, true, false and null literals. [?(@.key)] selects
+//     the values that have the key.
+//
+// Unquoted keys end at . and [, and .. starts a recursive step. Queries that
+// used to select keys containing [ or .., e.g. a[0] or a..b, select array
+// elements or recursive keys now, so such keys must be quoted.
+//
+// Numeric keys match both string and integer map keys. Query stops reading
+// the input as soon as no more values can match, so the decoder may be left
+// in the middle of the document.
+func (d *Decoder) Query(query string) ([]interface{}, error) {
+	path, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []interface{}
+	exec := &queryExec{
+		paths: []*queryPath{path},
+		emit: func(d *Decoder, _ int) error {
+			v, err := d.decodeInterfaceCond()
+			if err != nil {
 				return err
 			}
-			if q.hasAsterisk {
-				return d.skipNext(n - i - 1)
+			values = append(values, v)
+			return nil
+		},
+	}
+	if err := exec.run(d); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// QueryRaw is like Query, but returns the encoded values without decoding them,
+// so integer widths, str/bin and ext types are preserved.
+func (d *Decoder) QueryRaw(query string) ([]RawMessage, error) {
+	path, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []RawMessage
+	exec := &queryExec{
+		paths: []*queryPath{path},
+		emit: func(d *Decoder, _ int) error {
+			v, err := d.DecodeRaw()
+			if err != nil {
+				return err
 			}
+			values = append(values, v)
 			return nil
-		}
+		},
+	}
+	if err := exec.run(d); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
 
-		if err := d.Skip(); err != nil {
-			return err
+// QueryInto is like Query, but decodes the values into dst, which must be a pointer.
+// See CompiledQuery.ExecInto for details.
+func (d *Decoder) QueryInto(query string, dst interface{}) error {
+	q, err := CompileQuery(query)
+	if err != nil {
+		return err
+	}
+	return q.ExecInto(d, dst)
+}
+
+//------------------------------------------------------------------------------
+
+// CompiledQuery is a set of query paths that are extracted together in a single
+// pass over the input. See Decoder.Query for the path syntax.
+// A CompiledQuery is safe for concurrent use by multiple goroutines.
+type CompiledQuery struct {
+	paths []*queryPath
+}
+
+// CompileQuery parses the paths and returns a query that can be executed
+// on many decoders.
+func CompileQuery(paths ...string) (*CompiledQuery, error) {
+	q := &CompiledQuery{
+		paths: make([]*queryPath, 0, len(paths)),
+	}
+	for _, expr := range paths {
+		path, err := parseQuery(expr)
+		if err != nil {
+			return nil, err
 		}
+		q.paths = append(q.paths, path)
 	}
+	return q, nil
+}
 
-	return nil
+// MustCompileQuery is like CompileQuery but panics if a path can't be parsed.
+func MustCompileQuery(paths ...string) *CompiledQuery {
+	q, err := CompileQuery(paths...)
+	if err != nil {
+		panic(err)
+	}
+	return q
 }
 
-func (d *Decoder) skipNext(n int) error {
-	for i := 0; i < n; i++ {
-		if err := d.Skip(); err != nil {
-			return err
+// Paths returns the paths the query was compiled from.
+func (q *CompiledQuery) Paths() []string {
+	paths := make([]string, len(q.paths))
+	for i, path := range q.paths {
+		paths[i] = path.expr
+	}
+	return paths
+}
+
+// Exec reads the next value from d and returns the values matched by each path
+// keyed by the path. Paths that match nothing are omitted.
+func (q *CompiledQuery) Exec(d *Decoder) (map[string][]interface{}, error) {
+	values := make([][]interface{}, len(q.paths))
+	exec := &queryExec{
+		paths: q.paths,
+		emit: func(d *Decoder, path int) error {
+			v, err := d.decodeInterfaceCond()
+			if err != nil {
+				return err
+			}
+			values[path] = append(values[path], v)
+			return nil
+		},
+	}
+	if err := exec.run(d); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string][]interface{}, len(q.paths))
+	for i, path := range q.paths {
+		if values[i] != nil {
+			res[path.expr] = append(res[path.expr], values[i]...)
 		}
 	}
-	return nil
+	return res, nil
+}
+
+// ExecInto reads the next value from d and decodes the values matched by
+// the i-th path into dst[i], which must be a pointer or nil to ignore the path.
+// If the path can match several values (it uses wildcards, slices, filters or
+// recursive descent) and dst[i] points to a slice, every match is appended to
+// the slice. Otherwise the match is decoded into dst[i] as is.
+// Destinations of paths that match nothing are left unchanged.
+func (q *CompiledQuery) ExecInto(d *Decoder, dst ...interface{}) error {
+	if len(dst) != len(q.paths) {
+		return fmt.Errorf("msgpack: query has %d paths, got %d destinations", len(q.paths), len(dst))
+	}
+
+	slices := make([]reflect.Value, len(dst))
+	for i, v := range dst {
+		if v == nil {
+			continue
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return fmt.Errorf("msgpack: query destination must be a non-nil pointer, got %T", v)
+		}
+		if q.paths[i].multi && rv.Elem().Kind() == reflect.Slice {
+			slices[i] = rv.Elem()
+		}
+	}
+
+	exec := &queryExec{
+		paths: q.paths,
+		emit: func(d *Decoder, path int) error {
+			if dst[path] == nil {
+				return d.Skip()
+			}
+			slice := slices[path]
+			if !slice.IsValid() {
+				return d.Decode(dst[path])
+			}
+			elem := reflect.New(slice.Type().Elem()).Elem()
+			if err := d.DecodeValue(elem); err != nil {
+				return err
+			}
+			slice.Set(reflect.Append(slice, elem))
+			return nil
+		},
+	}
+	return exec.run(d)
 }
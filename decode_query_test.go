@@ -0,0 +1,291 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+func queryDoc(t *testing.T) []byte {
+	b, err := msgpack.Marshal(map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"id": 1, "status": "ok", "price": 10},
+			map[string]interface{}{"id": 2, "status": "failed", "price": 25},
+			map[string]interface{}{"id": 3, "status": "ok", "price": 30, "tags": []string{"a", "b"}},
+		},
+		"meta": map[string]interface{}{
+			"id":    "meta",
+			"a.b":   "dotted",
+			"count": 3,
+		},
+		"codes": map[int]string{
+			200: "OK",
+			404: "Not Found",
+		},
+	})
+	require.Nil(t, err)
+	return b
+}
+
+func TestQuerySyntax(t *testing.T) {
+	b := queryDoc(t)
+
+	tests := []struct {
+		query  string
+		wanted []interface{}
+	}{
+		{"meta.count", []interface{}{int8(3)}},
+		{"items.1.id", []interface{}{int8(2)}},
+		{"items[1].id", []interface{}{int8(2)}},
+		{"items[-1].id", []interface{}{int8(3)}},
+		{"items.-1.id", []interface{}{int8(3)}},
+		{"items[-5].id", nil},
+		{"items[1:].id", []interface{}{int8(2), int8(3)}},
+		{"items[:2].id", []interface{}{int8(1), int8(2)}},
+		{"items[-2:-1].id", []interface{}{int8(2)}},
+		{"items[*].id", []interface{}{int8(1), int8(2), int8(3)}},
+		{"items.*.tags.0", []interface{}{"a"}},
+		{`items[?(@.status=="ok")].id`, []interface{}{int8(1), int8(3)}},
+		{`items[?(@.status != 'ok')].id`, []interface{}{int8(2)}},
+		{`items[?(@.price >= 25)].id`, []interface{}{int8(2), int8(3)}},
+		{`items[?(@.price < 25.5)].id`, []interface{}{int8(1), int8(2)}},
+		{`items[?(@.tags)].id`, []interface{}{int8(3)}},
+		{`items[?(@.tags[*] == "b")].id`, []interface{}{int8(3)}},
+		{`items[?(@.missing == null)].id`, nil},
+		{`items[?(@.price == "10")].id`, nil},
+		{`meta[?(@ == 3)]`, []interface{}{int8(3)}},
+		{`meta["a.b"]`, []interface{}{"dotted"}},
+		{`meta['a.b']`, []interface{}{"dotted"}},
+		{"codes.404", []interface{}{"Not Found"}},
+		{"codes[200]", []interface{}{"OK"}},
+		{"nope", nil},
+		{"items.5", nil},
+	}
+	for _, test := range tests {
+		dec := msgpack.NewDecoder(bytes.NewReader(b))
+		dec.SetMapDecoder(func(dec *msgpack.Decoder) (interface{}, error) {
+			return dec.DecodeUntypedMap()
+		})
+		values, err := dec.Query(test.query)
+		require.Nil(t, err, test.query)
+		require.Equal(t, test.wanted, values, test.query)
+	}
+}
+
+func TestQueryQuotedKeys(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]interface{}{
+		"a[0].b": "literal",
+		"a":      []interface{}{map[string]interface{}{"b": "nested"}},
+		"x..y":   map[string]interface{}{`q"k`: 1},
+	})
+	require.Nil(t, err)
+
+	tests := []struct {
+		query  string
+		wanted []interface{}
+	}{
+		{"a[0].b", []interface{}{"nested"}},
+		{`["a[0].b"]`, []interface{}{"literal"}},
+		{`['a[0].b']`, []interface{}{"literal"}},
+		{`["x..y"]["q\"k"]`, []interface{}{int8(1)}},
+		{`['x..y'].['q"k']`, []interface{}{int8(1)}},
+		{`..["a[0].b"]`, []interface{}{"literal"}},
+	}
+	for _, test := range tests {
+		dec := msgpack.NewDecoder(bytes.NewReader(b))
+		values, err := dec.Query(test.query)
+		require.Nil(t, err, test.query)
+		require.Equal(t, test.wanted, values, test.query)
+	}
+}
+
+func TestQueryUnordered(t *testing.T) {
+	b := queryDoc(t)
+
+	tests := []struct {
+		query  string
+		wanted []interface{}
+	}{
+		{"meta.*", []interface{}{"meta", "dotted", int8(3)}},
+		{"codes[*]", []interface{}{"OK", "Not Found"}},
+		{"..id", []interface{}{int8(1), int8(2), int8(3), "meta"}},
+		{"..tags[0]", []interface{}{"a"}},
+		{"..tags[-1]", []interface{}{"b"}},
+	}
+	for _, test := range tests {
+		dec := msgpack.NewDecoder(bytes.NewReader(b))
+		values, err := dec.Query(test.query)
+		require.Nil(t, err, test.query)
+		require.ElementsMatch(t, test.wanted, values, test.query)
+	}
+}
+
+func TestQueryRecursiveNested(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]interface{}{
+		"a": map[string]interface{}{
+			"a": map[string]interface{}{"a": "x"},
+		},
+	})
+	require.Nil(t, err)
+
+	// Each value is returned once even if it matches several ways.
+	values, err := msgpack.NewDecoder(bytes.NewReader(b)).Query("..a..a")
+	require.Nil(t, err)
+	require.Equal(t, []interface{}{map[string]interface{}{"a": "x"}, "x"}, values)
+}
+
+func TestQueryErrors(t *testing.T) {
+	b := queryDoc(t)
+
+	for query, wanted := range map[string]string{
+		"meta.count.x":  `msgpack: unsupported code=3 decoding key="x"`,
+		"items.x":       `msgpack: invalid array index="x"`,
+		"items[x]":      `msgpack: invalid query "items[x]" at offset 6: invalid index "x"`,
+		"items[1":       `msgpack: invalid query "items[1" at offset 6: expected ']'`,
+		"items[?(@.a":   `msgpack: invalid query "items[?(@.a" at offset 11: expected ')'`,
+		"items[?(@.a=)": `msgpack: invalid query "items[?(@.a=)" at offset 11: expected ')'`,
+		"items..":       `msgpack: invalid query "items.." at offset 7: expected key`,
+		`meta["a`:       `msgpack: invalid query "meta[\"a" at offset 7: unterminated string`,
+	} {
+		_, err := msgpack.NewDecoder(bytes.NewReader(b)).Query(query)
+		require.EqualError(t, err, wanted, query)
+	}
+
+	// Lenient when the path fans out.
+	values, err := msgpack.NewDecoder(bytes.NewReader(b)).Query("*.id")
+	require.Nil(t, err)
+	require.Equal(t, []interface{}{"meta"}, values)
+}
+
+func TestCompiledQuery(t *testing.T) {
+	b := queryDoc(t)
+
+	q, err := msgpack.CompileQuery("meta.count", "items[*].id", `items[?(@.status=="ok")].price`, "nope")
+	require.Nil(t, err)
+	require.Equal(t, []string{"meta.count", "items[*].id", `items[?(@.status=="ok")].price`, "nope"}, q.Paths())
+
+	for i := 0; i < 2; i++ {
+		res, err := q.Exec(msgpack.NewDecoder(bytes.NewReader(b)))
+		require.Nil(t, err)
+		require.Equal(t, map[string][]interface{}{
+			"meta.count":                     {int8(3)},
+			"items[*].id":                    {int8(1), int8(2), int8(3)},
+			`items[?(@.status=="ok")].price`: {int8(10), int8(30)},
+		}, res)
+	}
+
+	// Overlapping paths share the traversal.
+	q = msgpack.MustCompileQuery("items[0]", "items[0].id", "..price")
+	res, err := q.Exec(msgpack.NewDecoder(bytes.NewReader(b)))
+	require.Nil(t, err)
+	require.Equal(t, []interface{}{int8(1)}, res["items[0].id"])
+	require.Equal(t, []interface{}{int8(10), int8(25), int8(30)}, res["..price"])
+	require.Len(t, res["items[0]"], 1)
+
+	_, err = msgpack.CompileQuery("ok", "items[")
+	require.EqualError(t, err, `msgpack: invalid query "items[" at offset 6: expected ']'`)
+	require.Panics(t, func() { msgpack.MustCompileQuery("[") })
+}
+
+func TestCompiledQueryExecInto(t *testing.T) {
+	b := queryDoc(t)
+
+	type item struct {
+		ID     int    `msgpack:"id"`
+		Status string `msgpack:"status"`
+	}
+
+	var (
+		count    int
+		ids      []int
+		first    item
+		okItems  []item
+		allItems []item
+	)
+	q := msgpack.MustCompileQuery(
+		"meta.count", "items[*].id", "items[0]", `items[?(@.status=="ok")]`, "items", "nope")
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	err := q.ExecInto(dec, &count, &ids, &first, &okItems, &allItems, nil)
+	require.Nil(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, []int{1, 2, 3}, ids)
+	require.Equal(t, item{ID: 1, Status: "ok"}, first)
+	require.Equal(t, []item{{ID: 1, Status: "ok"}, {ID: 3, Status: "ok"}}, okItems)
+	require.Len(t, allItems, 3)
+
+	var s string
+	err = msgpack.MustCompileQuery("meta.count").ExecInto(msgpack.NewDecoder(bytes.NewReader(b)), &s)
+	require.EqualError(t, err, "msgpack: invalid code=3 decoding string/bytes length")
+
+	err = q.ExecInto(dec, &count)
+	require.EqualError(t, err, "msgpack: query has 6 paths, got 1 destinations")
+}
+
+func TestQueryRaw(t *testing.T) {
+	in := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"n": uint64(1), "b": []byte("bin"), "t": time.Unix(1, 0)},
+			map[string]interface{}{"n": int16(-2), "b": "str"},
+		},
+	}
+	b, err := msgpack.Marshal(in)
+	require.Nil(t, err)
+
+	for _, dec := range []*msgpack.Decoder{
+		msgpack.NewDecoder(bytes.NewReader(b)),
+		msgpack.NewDecoder(bytes.NewBuffer(b)),
+	} {
+		raws, err := dec.QueryRaw("items[*].n")
+		require.Nil(t, err)
+		require.Equal(t, []msgpack.RawMessage{
+			{msgpcode.Uint64, 0, 0, 0, 0, 0, 0, 0, 1},
+			{msgpcode.Int16, 0xff, 0xfe},
+		}, raws)
+	}
+
+	raws, err := msgpack.NewDecoder(bytes.NewReader(b)).QueryRaw("items[*].b")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(raws))
+	require.Equal(t, byte(msgpcode.Bin8), raws[0][0])
+	require.True(t, msgpcode.IsFixedString(raws[1][0]))
+
+	raws, err = msgpack.NewDecoder(bytes.NewReader(b)).QueryRaw("items[0]")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(raws))
+
+	var m map[string]interface{}
+	require.Nil(t, msgpack.Unmarshal(raws[0], &m))
+	require.Equal(t, in["items"].([]interface{})[0].(map[string]interface{})["t"], m["t"])
+}
+
+func TestQueryInto(t *testing.T) {
+	b := queryDoc(t)
+
+	type item struct {
+		ID    int64 `msgpack:"id"`
+		Price uint  `msgpack:"price"`
+	}
+
+	var items []item
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	require.Nil(t, dec.QueryInto(`items[?(@.price > 20)]`, &items))
+	require.Equal(t, []item{{ID: 2, Price: 25}, {ID: 3, Price: 30}}, items)
+
+	var first item
+	dec = msgpack.NewDecoder(bytes.NewReader(b))
+	require.Nil(t, dec.QueryInto("items.0", &first))
+	require.Equal(t, item{ID: 1, Price: 10}, first)
+
+	var tags []string
+	dec = msgpack.NewDecoder(bytes.NewReader(b))
+	require.Nil(t, dec.QueryInto("items[2].tags", &tags))
+	require.Equal(t, []string{"a", "b"}, tags)
+
+	dec = msgpack.NewDecoder(bytes.NewReader(b))
+	require.EqualError(t, dec.QueryInto("items", items), "msgpack: query destination must be a non-nil pointer, got []msgpack_test.item")
+}
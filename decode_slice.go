@@ -3,6 +3,7 @@ package msgpack
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 
 	"github.com/vmihailenco/msgpack/v5/msgpcode"
 )
@@ -19,6 +20,17 @@ func (d *Decoder) DecodeArrayLen() (int, error) {
 }
 
 func (d *Decoder) arrayLen(c byte) (int, error) {
+	n, err := d.parseArrayLen(c)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.checkLen("MaxArrayLen", d.limits.MaxArrayLen, n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (d *Decoder) parseArrayLen(c byte) (int, error) {
 	if c == msgpcode.Nil {
 		return -1, nil
 	} else if c >= msgpcode.FixedArrayLow && c <= msgpcode.FixedArrayHigh {
@@ -32,7 +44,7 @@ func (d *Decoder) arrayLen(c byte) (int, error) {
 		n, err := d.uint32()
 		return int(n), err
 	}
-	return 0, fmt.Errorf("msgpack: invalid code=%x decoding array length", c)
+	return 0, invalidCodeError{code: c, hint: "array length"}
 }
 
 func decodeStringSliceValue(d *Decoder, v reflect.Value) error {
@@ -41,6 +53,11 @@ func decodeStringSliceValue(d *Decoder, v reflect.Value) error {
 }
 
 func (d *Decoder) decodeStringSlicePtr(ptr *[]string) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
 	n, err := d.DecodeArrayLen()
 	if err != nil {
 		return err
@@ -51,9 +68,10 @@ func (d *Decoder) decodeStringSlicePtr(ptr *[]string) error {
 
 	ss := makeStrings(*ptr, n, d.flags&disableAllocLimitFlag != 0)
 	for i := 0; i < n; i++ {
+		offset := d.InputOffset()
 		s, err := d.DecodeString()
 		if err != nil {
-			return err
+			return d.indexError(err, offset, stringType, strconv.Itoa(i))
 		}
 		ss = append(ss, s)
 	}
@@ -81,6 +99,11 @@ func makeStrings(s []string, n int, noLimit bool) []string {
 }
 
 func decodeSliceValue(d *Decoder, v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
 	n, err := d.DecodeArrayLen()
 	if err != nil {
 		return err
@@ -112,9 +135,10 @@ func decodeSliceValue(d *Decoder, v reflect.Value) error {
 			v.Set(growSliceValue(v, n, noLimit))
 		}
 
+		offset := d.InputOffset()
 		elem := v.Index(i)
 		if err := d.DecodeValue(elem); err != nil {
-			return err
+			return d.indexError(err, offset, elem.Type(), strconv.Itoa(i))
 		}
 	}
 
@@ -131,6 +155,11 @@ func growSliceValue(v reflect.Value, n int, noLimit bool) reflect.Value {
 }
 
 func decodeArrayValue(d *Decoder, v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
 	n, err := d.DecodeArrayLen()
 	if err != nil {
 		return err
@@ -144,9 +173,10 @@ func decodeArrayValue(d *Decoder, v reflect.Value) error {
 	}
 
 	for i := 0; i < n; i++ {
+		offset := d.InputOffset()
 		sv := v.Index(i)
 		if err := d.DecodeValue(sv); err != nil {
-			return err
+			return d.indexError(err, offset, sv.Type(), strconv.Itoa(i))
 		}
 	}
 
@@ -162,6 +192,11 @@ func (d *Decoder) DecodeSlice() ([]interface{}, error) {
 }
 
 func (d *Decoder) decodeSlice(c byte) ([]interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
 	n, err := d.arrayLen(c)
 	if err != nil {
 		return nil, err
@@ -172,9 +207,10 @@ func (d *Decoder) decodeSlice(c byte) ([]interface{}, error) {
 
 	s := make([]interface{}, 0, n)
 	for i := 0; i < n; i++ {
+		offset := d.InputOffset()
 		v, err := d.decodeInterfaceCond()
 		if err != nil {
-			return nil, err
+			return nil, d.indexError(err, offset, interfaceType, strconv.Itoa(i))
 		}
 		s = append(s, v)
 	}
@@ -183,6 +219,11 @@ func (d *Decoder) decodeSlice(c byte) ([]interface{}, error) {
 }
 
 func (d *Decoder) skipSlice(c byte) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
 	n, err := d.arrayLen(c)
 	if err != nil {
 		return err
@@ -8,6 +8,17 @@ import (
 )
 
 func (d *Decoder) bytesLen(c byte) (int, error) {
+	n, err := d.parseBytesLen(c)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.checkLen("MaxStringLen", d.limits.MaxStringLen, n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (d *Decoder) parseBytesLen(c byte) (int, error) {
 	if c == msgpcode.Nil {
 		return -1, nil
 	}
@@ -28,7 +39,7 @@ func (d *Decoder) bytesLen(c byte) (int, error) {
 		return int(n), err
 	}
 
-	return 0, fmt.Errorf("msgpack: invalid code=%x decoding string/bytes length", c)
+	return 0, invalidCodeError{code: c, hint: "string/bytes length"}
 }
 
 func (d *Decoder) DecodeString() (string, error) {
@@ -56,7 +67,13 @@ func (d *Decoder) stringWithLen(n int) (string, error) {
 		return "", nil
 	}
 	b, err := d.readN(n)
-	return string(b), err
+	if err != nil {
+		return "", err
+	}
+	if d.noCopy && d.flags&noCopyStringsFlag != 0 {
+		return bytesToString(b), nil
+	}
+	return string(b), nil
 }
 
 func decodeStringValue(d *Decoder, v reflect.Value) error {
@@ -92,7 +109,15 @@ func (d *Decoder) bytes(c byte, b []byte) ([]byte, error) {
 	if n == -1 {
 		return nil, nil
 	}
-	return readN(d.r, b, n)
+	return d.readBytes(b, n)
+}
+
+// DecodeStringTemp decodes a string without copying it when possible.
+// The returned string is only valid until the next call to the decoder,
+// so it must not be retained. It is intended for matching map keys
+// and struct field names, e.g. in code generated by cmd/msgpackgen.
+func (d *Decoder) DecodeStringTemp() (string, error) {
+	return d.decodeStringTemp()
 }
 
 func (d *Decoder) decodeStringTemp() (string, error) {
@@ -139,7 +164,7 @@ func (d *Decoder) bytesPtr(c byte, ptr *[]byte) error {
 		return nil
 	}
 
-	*ptr, err = readN(d.r, *ptr, n)
+	*ptr, err = d.readBytes(*ptr, n)
 	return err
 }
 
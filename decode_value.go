@@ -8,9 +8,10 @@ import (
 )
 
 var (
-	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
-	stringType    = reflect.TypeOf((*string)(nil)).Elem()
-	boolType      = reflect.TypeOf((*bool)(nil)).Elem()
+	interfaceType  = reflect.TypeOf((*interface{})(nil)).Elem()
+	stringType     = reflect.TypeOf((*string)(nil)).Elem()
+	boolType       = reflect.TypeOf((*bool)(nil)).Elem()
+	rawMessageType = reflect.TypeOf((*RawMessage)(nil)).Elem()
 )
 
 var valueDecoders []decoderFunc
@@ -46,21 +47,26 @@ func init() {
 	}
 }
 
-func getDecoder(typ reflect.Type) decoderFunc {
-	if v, ok := typeDecMap.Load(typ); ok {
+func (r *Registry) getDecoder(typ reflect.Type) decoderFunc {
+	cache := r.cache.Load()
+	if v, ok := cache.dec.Load(typ); ok {
 		return v.(decoderFunc)
 	}
-	fn := _getDecoder(typ)
-	typeDecMap.Store(typ, fn)
+	fn := r._getDecoder(typ)
+	cache.dec.Store(typ, fn)
 	return fn
 }
 
-func _getDecoder(typ reflect.Type) decoderFunc {
+func (r *Registry) _getDecoder(typ reflect.Type) decoderFunc {
+	if v, ok := r.decMap.Load(typ); ok {
+		return v.(decoderFunc)
+	}
+
 	kind := typ.Kind()
 
 	if kind == reflect.Ptr {
-		if _, ok := typeDecMap.Load(typ.Elem()); ok {
-			return ptrValueDecoder(typ)
+		if _, ok := r.decMap.Load(typ.Elem()); ok {
+			return r.ptrValueDecoder(typ)
 		}
 	}
 
@@ -96,7 +102,7 @@ func _getDecoder(typ reflect.Type) decoderFunc {
 
 	switch kind {
 	case reflect.Ptr:
-		return ptrValueDecoder(typ)
+		return r.ptrValueDecoder(typ)
 	case reflect.Slice:
 		elem := typ.Elem()
 		if elem.Kind() == reflect.Uint8 {
@@ -123,8 +129,8 @@ func _getDecoder(typ reflect.Type) decoderFunc {
 	return valueDecoders[kind]
 }
 
-func ptrValueDecoder(typ reflect.Type) decoderFunc {
-	decoder := getDecoder(typ.Elem())
+func (r *Registry) ptrValueDecoder(typ reflect.Type) decoderFunc {
+	decoder := r.getDecoder(typ.Elem())
 	return func(d *Decoder, v reflect.Value) error {
 		if d.hasNilCode() {
 			if !v.IsNil() {
@@ -199,7 +205,15 @@ func (d *Decoder) interfaceValue(v reflect.Value) error {
 			}
 		}
 
-		v.Set(reflect.ValueOf(vv))
+		rv := reflect.ValueOf(vv)
+		if !rv.Type().AssignableTo(v.Type()) {
+			var ok bool
+			rv, ok = pointerOrElem(rv, v.Type())
+			if !ok {
+				return fmt.Errorf("msgpack: cannot decode %s into %s", rv.Type(), v.Type())
+			}
+		}
+		v.Set(rv)
 	}
 
 	return nil
@@ -217,14 +231,10 @@ func decodeCustomValue(d *Decoder, v reflect.Value) error {
 }
 
 func unmarshalValue(d *Decoder, v reflect.Value) error {
-	var b []byte
-
-	d.rec = make([]byte, 0, 64)
-	if err := d.Skip(); err != nil {
+	b, err := d.readRaw(64)
+	if err != nil {
 		return err
 	}
-	b = d.rec
-	d.rec = nil
 
 	unmarshaler := v.Interface().(Unmarshaler)
 	return unmarshaler.UnmarshalMsgpack(b)
@@ -1,7 +1,6 @@
 package msgpack
 
 import (
-	"bytes"
 	"io"
 	"reflect"
 	"sync"
@@ -17,6 +16,8 @@ const (
 	useCompactFloatsFlag
 	useInternedStringsFlag
 	omitEmptyFlag
+	canonicalEncodingFlag
+	useTypeNamesFlag
 )
 
 type writer interface {
@@ -39,6 +40,19 @@ func (bw byteWriter) WriteByte(c byte) error {
 	return err
 }
 
+// appendWriter appends written data to the encoder's own byte slice.
+type appendWriter Encoder
+
+func (aw *appendWriter) Write(b []byte) (int, error) {
+	aw.wbuf = append(aw.wbuf, b...)
+	return len(b), nil
+}
+
+func (aw *appendWriter) WriteByte(c byte) error {
+	aw.wbuf = append(aw.wbuf, c)
+	return nil
+}
+
 //------------------------------------------------------------------------------
 
 var encPool = sync.Pool{
@@ -53,25 +67,33 @@ func GetEncoder() *Encoder {
 
 func PutEncoder(enc *Encoder) {
 	enc.w = nil
+	enc.wbuf = nil
+	enc.appending = false
 	encPool.Put(enc)
 }
 
 // Marshal returns the MessagePack encoding of v.
 func Marshal(v interface{}) ([]byte, error) {
+	return AppendMarshal(nil, v)
+}
+
+// AppendMarshal appends the MessagePack encoding of v to dst
+// and returns the extended buffer. On error dst is returned unchanged.
+func AppendMarshal(dst []byte, v interface{}) ([]byte, error) {
 	enc := GetEncoder()
 
-	var buf bytes.Buffer
-	enc.Reset(&buf)
+	enc.Reset(nil)
+	enc.ResetBytes(dst)
 
 	err := enc.Encode(v)
-	b := buf.Bytes()
+	b := enc.Bytes()
 
 	PutEncoder(enc)
 
 	if err != nil {
-		return nil, err
+		return dst, err
 	}
-	return b, err
+	return b, nil
 }
 
 type Encoder struct {
@@ -80,7 +102,18 @@ type Encoder struct {
 	structTag string
 	buf       []byte
 	timeBuf   []byte
+	wbuf      []byte
+	appending bool
+	ptrSeen   map[ptrKey]struct{}
+	ptrLevel  int
+	depth     int
 	flags     uint32
+
+	timeFormat TimeFormat
+	reg        *Registry
+
+	cycleDepth int
+	maxDepth   int
 }
 
 // NewEncoder returns a new encoder that writes to w.
@@ -107,6 +140,10 @@ func (e *Encoder) ResetDict(w io.Writer, dict map[string]int) {
 	e.ResetWriter(w)
 	e.flags = 0
 	e.structTag = ""
+	e.timeFormat = TimeFormatExt
+	e.reg = nil
+	e.cycleDepth = defaultCycleDetectionDepth
+	e.maxDepth = 0
 	e.dict = dict
 }
 
@@ -120,6 +157,11 @@ func (e *Encoder) WithDict(dict map[string]int, fn func(*Encoder) error) error {
 
 func (e *Encoder) ResetWriter(w io.Writer) {
 	e.dict = nil
+	e.wbuf = nil
+	e.appending = false
+	e.ptrSeen = nil
+	e.ptrLevel = 0
+	e.depth = 0
 	if bw, ok := w.(writer); ok {
 		e.w = bw
 	} else if w == nil {
@@ -129,11 +171,28 @@ func (e *Encoder) ResetWriter(w io.Writer) {
 	}
 }
 
+// ResetBytes is like ResetWriter, but switches the encoder to append encoded
+// data to b instead of writing it to an io.Writer. Use Bytes to get the result.
+func (e *Encoder) ResetBytes(b []byte) {
+	e.ResetWriter(nil)
+	e.wbuf = b
+	e.appending = true
+	e.w = (*appendWriter)(e)
+}
+
+// Bytes returns the buffer passed to ResetBytes extended with the data encoded so far.
+// The buffer is valid until the next call to ResetBytes or Reset.
+func (e *Encoder) Bytes() []byte {
+	return e.wbuf
+}
+
 // SetSortMapKeys causes the Encoder to encode map keys in increasing order.
 // Supported map types are:
 //   - map[string]string
 //   - map[string]bool
 //   - map[string]interface{}
+//
+// Use UseCanonicalEncoding to sort the keys of all maps.
 func (e *Encoder) SetSortMapKeys(on bool) *Encoder {
 	if on {
 		e.flags |= sortMapKeysFlag
@@ -177,6 +236,13 @@ func (e *Encoder) UseCompactInts(on bool) {
 	}
 }
 
+// CompactInts reports whether sized integers, e.g. int8 or uint32, are encoded
+// in the most compact form as with UseCompactInts or UseCanonicalEncoding.
+// Otherwise they are encoded with their size preserved, e.g. with EncodeInt8.
+func (e *Encoder) CompactInts() bool {
+	return e.flags&(useCompactIntsFlag|canonicalEncodingFlag) != 0
+}
+
 // UseCompactFloats causes the Encoder to chose a compact integer encoding
 // for floats that can be represented as integers.
 func (e *Encoder) UseCompactFloats(on bool) {
@@ -187,6 +253,26 @@ func (e *Encoder) UseCompactFloats(on bool) {
 	}
 }
 
+// UseCanonicalEncoding causes the Encoder to produce byte-identical output
+// for equal values, which is required to hash or sign the encoded data:
+//   - map keys of every map type are sorted by their encoded bytes,
+//     so shorter strings go first;
+//   - struct fields are sorted the same way unless the struct
+//     is encoded as an array;
+//   - integers and floats are encoded in the shortest form that preserves
+//     the value and NaN is encoded as a float32 quiet NaN.
+//
+// Maps with keys that have the same encoding, e.g. NaN keys, are rejected.
+// Map keys are never interned. Values implementing CustomEncoder or Marshaler,
+// including the ones generated by cmd/msgpackgen, are encoded as they are.
+func (e *Encoder) UseCanonicalEncoding(on bool) {
+	if on {
+		e.flags |= canonicalEncodingFlag
+	} else {
+		e.flags &= ^canonicalEncodingFlag
+	}
+}
+
 // UseInternedStrings causes the Encoder to intern strings.
 func (e *Encoder) UseInternedStrings(on bool) {
 	if on {
@@ -236,7 +322,7 @@ func (e *Encoder) EncodeMulti(v ...interface{}) error {
 }
 
 func (e *Encoder) EncodeValue(v reflect.Value) error {
-	fn := getEncoder(v.Type())
+	fn := e.registry().getEncoder(v.Type())
 	return fn(e, v)
 }
 
@@ -255,16 +341,30 @@ func (e *Encoder) EncodeDuration(d time.Duration) error {
 	return e.EncodeInt(int64(d))
 }
 
+// writeCode and the other write methods append to wbuf directly
+// in append mode to avoid the writer interface calls.
 func (e *Encoder) writeCode(c byte) error {
+	if e.appending {
+		e.wbuf = append(e.wbuf, c)
+		return nil
+	}
 	return e.w.WriteByte(c)
 }
 
 func (e *Encoder) write(b []byte) error {
+	if e.appending {
+		e.wbuf = append(e.wbuf, b...)
+		return nil
+	}
 	_, err := e.w.Write(b)
 	return err
 }
 
 func (e *Encoder) writeString(s string) error {
+	if e.appending {
+		e.wbuf = append(e.wbuf, s...)
+		return nil
+	}
 	_, err := e.w.Write(stringToBytes(s))
 	return err
 }
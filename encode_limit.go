@@ -0,0 +1,91 @@
+package msgpack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// defaultCycleDetectionDepth matches encoding/json: cycles are rare,
+// so the encoder only starts tracking pointers in deeply nested values.
+const defaultCycleDetectionDepth = 1000
+
+// CycleError is returned by Encoder when the value being encoded
+// references itself through a pointer, map or slice.
+type CycleError struct {
+	// Type is the type of the pointer, map or slice that closes the cycle.
+	Type reflect.Type
+}
+
+func (err *CycleError) Error() string {
+	return fmt.Sprintf("msgpack: encountered a cycle via %s", err.Type)
+}
+
+// SetCycleDetectionDepth sets the number of nested pointers, maps and slices
+// after which the Encoder starts checking for reference cycles and returns
+// *CycleError instead of overflowing the stack. The default is 1000.
+// Zero checks every pointer and a negative depth disables the check.
+func (e *Encoder) SetCycleDetectionDepth(depth int) {
+	e.cycleDepth = depth
+}
+
+// SetMaxDepth limits the nesting depth of maps, arrays and structs
+// to depth. Exceeding it returns *LimitError. Zero means no limit.
+func (e *Encoder) SetMaxDepth(depth int) {
+	e.maxDepth = depth
+}
+
+// enter must be called before encoding the elements of a map, an array
+// or a struct and paired with leave.
+func (e *Encoder) enter() error {
+	e.depth++
+	if e.maxDepth > 0 && e.depth > e.maxDepth {
+		e.depth--
+		return &LimitError{Limit: "MaxDepth", Max: int64(e.maxDepth), Value: int64(e.maxDepth + 1)}
+	}
+	return nil
+}
+
+func (e *Encoder) leave() {
+	e.depth--
+}
+
+type ptrKey struct {
+	ptr uintptr
+	len int
+}
+
+func newPtrKey(v reflect.Value) ptrKey {
+	key := ptrKey{ptr: v.Pointer()}
+	if v.Kind() == reflect.Slice {
+		// Subslices of the same array are different values.
+		key.len = v.Len()
+	}
+	return key
+}
+
+// enterPtr must be called before following a non-nil pointer, map or slice
+// and paired with leavePtr.
+func (e *Encoder) enterPtr(v reflect.Value) error {
+	e.ptrLevel++
+	if e.cycleDepth < 0 || e.ptrLevel <= e.cycleDepth {
+		return nil
+	}
+
+	key := newPtrKey(v)
+	if _, ok := e.ptrSeen[key]; ok {
+		e.ptrLevel--
+		return &CycleError{Type: v.Type()}
+	}
+	if e.ptrSeen == nil {
+		e.ptrSeen = make(map[ptrKey]struct{})
+	}
+	e.ptrSeen[key] = struct{}{}
+	return nil
+}
+
+func (e *Encoder) leavePtr(v reflect.Value) {
+	if e.cycleDepth >= 0 && e.ptrLevel > e.cycleDepth {
+		delete(e.ptrSeen, newPtrKey(v))
+	}
+	e.ptrLevel--
+}
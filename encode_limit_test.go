@@ -0,0 +1,110 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type cycleNode struct {
+	Name string
+	Next *cycleNode
+}
+
+func requireCycleError(t *testing.T, err error, typ reflect.Type) {
+	var cerr *msgpack.CycleError
+	require.True(t, errors.As(err, &cerr), "got %v", err)
+	require.Equal(t, typ, cerr.Type)
+}
+
+func TestEncoderCycle(t *testing.T) {
+	node := &cycleNode{Name: "a"}
+	node.Next = &cycleNode{Name: "b", Next: node}
+	_, err := msgpack.Marshal(node)
+	requireCycleError(t, err, reflect.TypeOf(node))
+	require.EqualError(t, err, "msgpack: encountered a cycle via *msgpack_test.cycleNode")
+
+	m := map[string]interface{}{}
+	m["self"] = m
+	_, err = msgpack.Marshal(m)
+	requireCycleError(t, err, reflect.TypeOf(m))
+
+	um := map[int]interface{}{}
+	um[1] = []interface{}{um}
+	_, err = msgpack.Marshal(um)
+	requireCycleError(t, err, reflect.TypeOf(um))
+
+	s := []interface{}{nil}
+	s[0] = s
+	_, err = msgpack.Marshal(s)
+	requireCycleError(t, err, reflect.TypeOf(s))
+}
+
+func TestEncoderCycleDetectionDepth(t *testing.T) {
+	shared := &cycleNode{Name: "shared"}
+	v := []*cycleNode{shared, shared, {Name: "c", Next: shared}}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCycleDetectionDepth(0)
+	require.Nil(t, enc.Encode(v))
+
+	var out []*cycleNode
+	require.Nil(t, msgpack.Unmarshal(buf.Bytes(), &out))
+	require.Equal(t, v, out)
+
+	node := &cycleNode{}
+	node.Next = node
+	enc.SetCycleDetectionDepth(0)
+	requireCycleError(t, enc.Encode(node), reflect.TypeOf(node))
+
+	// Nested values below the threshold are not tracked.
+	deep := &cycleNode{}
+	for i := 0; i < 10; i++ {
+		deep = &cycleNode{Next: deep}
+	}
+	enc.SetCycleDetectionDepth(-1)
+	require.Nil(t, enc.Encode(deep))
+}
+
+func TestEncoderMaxDepth(t *testing.T) {
+	var v interface{} = "leaf"
+	for i := 0; i < 5; i++ {
+		v = []interface{}{v}
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetMaxDepth(5)
+	require.Nil(t, enc.Encode(v))
+
+	enc.SetMaxDepth(4)
+	err := enc.Encode(v)
+	var lerr *msgpack.LimitError
+	require.True(t, errors.As(err, &lerr))
+	require.Equal(t, "MaxDepth", lerr.Limit)
+	require.Equal(t, int64(4), lerr.Max)
+
+	for _, v := range []interface{}{
+		[]interface{}{map[string]string{}},
+		[]interface{}{map[string]interface{}{}},
+		[]interface{}{[]string{}},
+		map[string]interface{}{"a": cycleNode{}},
+		[1][1]int{},
+	} {
+		enc.SetMaxDepth(1)
+		err := enc.Encode(v)
+		require.True(t, errors.As(err, &lerr), "%T", v)
+
+		enc.SetMaxDepth(2)
+		require.Nil(t, enc.Encode(v))
+	}
+
+	enc.Reset(&buf)
+	require.Nil(t, enc.Encode(v))
+}
@@ -1,6 +1,8 @@
 package msgpack
 
 import (
+	"bytes"
+	"fmt"
 	"math"
 	"reflect"
 	"sort"
@@ -12,6 +14,18 @@ func encodeMapValue(e *Encoder, v reflect.Value) error {
 	if v.IsNil() {
 		return e.EncodeNil()
 	}
+	if err := e.enterPtr(v); err != nil {
+		return err
+	}
+	defer e.leavePtr(v)
+	if err := e.enter(); err != nil {
+		return err
+	}
+	defer e.leave()
+
+	if e.flags&canonicalEncodingFlag != 0 {
+		return e.encodeMapCanonical(v)
+	}
 
 	if err := e.EncodeMapLen(v.Len()); err != nil {
 		return err
@@ -34,6 +48,14 @@ func encodeMapStringBoolValue(e *Encoder, v reflect.Value) error {
 	if v.IsNil() {
 		return e.EncodeNil()
 	}
+	if err := e.enter(); err != nil {
+		return err
+	}
+	defer e.leave()
+
+	if e.flags&canonicalEncodingFlag != 0 {
+		return e.encodeMapCanonical(v)
+	}
 
 	if err := e.EncodeMapLen(v.Len()); err != nil {
 		return err
@@ -60,6 +82,14 @@ func encodeMapStringStringValue(e *Encoder, v reflect.Value) error {
 	if v.IsNil() {
 		return e.EncodeNil()
 	}
+	if err := e.enter(); err != nil {
+		return err
+	}
+	defer e.leave()
+
+	if e.flags&canonicalEncodingFlag != 0 {
+		return e.encodeMapCanonical(v)
+	}
 
 	if err := e.EncodeMapLen(v.Len()); err != nil {
 		return err
@@ -86,6 +116,17 @@ func encodeMapStringInterfaceValue(e *Encoder, v reflect.Value) error {
 	if v.IsNil() {
 		return e.EncodeNil()
 	}
+	if err := e.enterPtr(v); err != nil {
+		return err
+	}
+	defer e.leavePtr(v)
+	if err := e.enter(); err != nil {
+		return err
+	}
+	defer e.leave()
+	if e.flags&canonicalEncodingFlag != 0 {
+		return e.encodeMapCanonical(v)
+	}
 	m := v.Convert(mapStringInterfaceType).Interface().(map[string]interface{})
 	if e.flags&sortMapKeysFlag != 0 {
 		return e.EncodeMapSorted(m)
@@ -97,6 +138,9 @@ func (e *Encoder) EncodeMap(m map[string]interface{}) error {
 	if m == nil {
 		return e.EncodeNil()
 	}
+	if e.flags&canonicalEncodingFlag != 0 {
+		return e.encodeMapCanonical(reflect.ValueOf(m))
+	}
 	if err := e.EncodeMapLen(len(m)); err != nil {
 		return err
 	}
@@ -104,7 +148,7 @@ func (e *Encoder) EncodeMap(m map[string]interface{}) error {
 		if err := e.EncodeString(mk); err != nil {
 			return err
 		}
-		if err := e.Encode(mv); err != nil {
+		if err := e.encodeInterface(mv); err != nil {
 			return err
 		}
 	}
@@ -131,7 +175,7 @@ func (e *Encoder) EncodeMapSorted(m map[string]interface{}) error {
 		if err := e.EncodeString(k); err != nil {
 			return err
 		}
-		if err := e.Encode(m[k]); err != nil {
+		if err := e.encodeInterface(m[k]); err != nil {
 			return err
 		}
 	}
@@ -179,6 +223,66 @@ func (e *Encoder) encodeSortedMapStringString(m map[string]string) error {
 	return nil
 }
 
+type canonicalMapEntry struct {
+	key   []byte
+	value reflect.Value
+}
+
+// encodeMapCanonical encodes the map with the entries sorted by the encoded keys.
+// The keys are encoded with a separate encoder, so they are never interned.
+func (e *Encoder) encodeMapCanonical(v reflect.Value) error {
+	if err := e.EncodeMapLen(v.Len()); err != nil {
+		return err
+	}
+
+	ke := GetEncoder()
+	defer PutEncoder(ke)
+
+	ke.Reset(nil)
+	ke.ResetBytes(nil)
+	ke.flags = e.flags &^ useInternedStringsFlag
+	ke.structTag = e.structTag
+	ke.timeFormat = e.timeFormat
+	ke.reg = e.reg
+
+	ends := make([]int, 0, v.Len())
+	entries := make([]canonicalMapEntry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		if err := ke.EncodeValue(iter.Key()); err != nil {
+			return err
+		}
+		ends = append(ends, len(ke.Bytes()))
+		entries = append(entries, canonicalMapEntry{value: iter.Value()})
+	}
+
+	// Slice the keys once the buffer stops growing.
+	b := ke.Bytes()
+	start := 0
+	for i, end := range ends {
+		entries[i].key = b[start:end:end]
+		start = end
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	for i, entry := range entries {
+		if i > 0 && bytes.Equal(entries[i-1].key, entry.key) {
+			return fmt.Errorf("msgpack: %s has keys with the same encoding %x", v.Type(), entry.key)
+		}
+		if err := e.write(entry.key); err != nil {
+			return err
+		}
+		if err := e.EncodeValue(entry.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (e *Encoder) EncodeMapLen(l int) error {
 	if l < 16 {
 		return e.writeCode(msgpcode.FixedMapLow | byte(l))
@@ -190,21 +294,73 @@ func (e *Encoder) EncodeMapLen(l int) error {
 }
 
 func encodeStructValue(e *Encoder, strct reflect.Value) error {
-	structFields := structs.Fields(strct.Type(), e.structTag)
+	structFields := e.registry().structFields(strct.Type(), e.structTag)
+	return e.encodeStruct(strct, structFields)
+}
+
+func (e *Encoder) encodeStruct(strct reflect.Value, structFields *fields) error {
+	if err := e.enter(); err != nil {
+		return err
+	}
+	defer e.leave()
+
 	if e.flags&arrayEncodedStructsFlag != 0 || structFields.AsArray {
 		return encodeStructValueAsArray(e, strct, structFields.List)
 	}
+	return e.encodeStructMap(strct, structFields, nil)
+}
+
+// structEntry is an entry of an encoded struct map that is not a field:
+// a union key or an entry of the remain field.
+type structEntry struct {
+	name  string
+	value reflect.Value
+}
+
+// encodeStructMap encodes the struct as a map of the head entries,
+// the fields and the entries of the remain field. With canonical encoding
+// the entries are sorted together with the fields.
+func (e *Encoder) encodeStructMap(strct reflect.Value, structFields *fields, head []structEntry) error {
 	fields := structFields.OmitEmpty(e, strct)
+	tail := e.remainEntries(strct, structFields, head)
 
-	if err := e.EncodeMapLen(len(fields)); err != nil {
+	if err := e.EncodeMapLen(len(head) + len(fields) + len(tail)); err != nil {
 		return err
 	}
 
+	if e.flags&canonicalEncodingFlag != 0 && len(head)+len(tail) > 0 {
+		entries := append(head[:len(head):len(head)], tail...)
+		sort.Slice(entries, func(i, j int) bool {
+			return canonicalLess(entries[i].name, entries[j].name)
+		})
+		head, tail = nil, entries
+		for len(fields) > 0 && len(tail) > 0 {
+			if canonicalLess(fields[0].name, tail[0].name) {
+				if err := e.encodeStructField(strct, fields[0]); err != nil {
+					return err
+				}
+				fields = fields[1:]
+			} else {
+				if err := e.encodeStructEntry(tail[0]); err != nil {
+					return err
+				}
+				tail = tail[1:]
+			}
+		}
+	}
+
+	for _, entry := range head {
+		if err := e.encodeStructEntry(entry); err != nil {
+			return err
+		}
+	}
 	for _, f := range fields {
-		if err := e.EncodeString(f.name); err != nil {
+		if err := e.encodeStructField(strct, f); err != nil {
 			return err
 		}
-		if err := f.EncodeValue(e, strct); err != nil {
+	}
+	for _, entry := range tail {
+		if err := e.encodeStructEntry(entry); err != nil {
 			return err
 		}
 	}
@@ -212,6 +368,55 @@ func encodeStructValue(e *Encoder, strct reflect.Value) error {
 	return nil
 }
 
+func (e *Encoder) encodeStructField(strct reflect.Value, f *field) error {
+	if err := e.EncodeString(f.name); err != nil {
+		return err
+	}
+	return f.EncodeValue(e, strct)
+}
+
+func (e *Encoder) encodeStructEntry(entry structEntry) error {
+	if err := e.EncodeString(entry.name); err != nil {
+		return err
+	}
+	return e.EncodeValue(entry.value)
+}
+
+// remainEntries returns the entries of the remain field sorted by name
+// except the ones that have the same name as a field or a head entry.
+func (e *Encoder) remainEntries(
+	strct reflect.Value, structFields *fields, head []structEntry,
+) []structEntry {
+	if structFields.remain == nil {
+		return nil
+	}
+	m, ok := fieldByIndex(strct, structFields.remain.index)
+	if !ok || m.Len() == 0 {
+		return nil
+	}
+
+	entries := make([]structEntry, 0, m.Len())
+	iter := m.MapRange()
+loop:
+	for iter.Next() {
+		name := iter.Key().String()
+		if _, ok := structFields.Map[name]; ok {
+			continue
+		}
+		for _, entry := range head {
+			if entry.name == name {
+				continue loop
+			}
+		}
+		entries = append(entries, structEntry{name: name, value: iter.Value()})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	return entries
+}
+
 func encodeStructValueAsArray(e *Encoder, strct reflect.Value, fields []*field) error {
 	if err := e.EncodeArrayLen(len(fields)); err != nil {
 		return err
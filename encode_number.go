@@ -3,17 +3,21 @@ package msgpack
 import (
 	"math"
 	"reflect"
+	"strconv"
 
 	"github.com/vmihailenco/msgpack/v5/msgpcode"
 )
 
+// canonicalNaN is the float32 quiet NaN used by canonical encoding.
+const canonicalNaN = 0x7fc00000
+
 // EncodeUint8 encodes an uint8 in 2 bytes preserving type of the number.
 func (e *Encoder) EncodeUint8(n uint8) error {
 	return e.write1(msgpcode.Uint8, n)
 }
 
 func (e *Encoder) encodeUint8Cond(n uint8) error {
-	if e.flags&useCompactIntsFlag != 0 {
+	if e.flags&(useCompactIntsFlag|canonicalEncodingFlag) != 0 {
 		return e.EncodeUint(uint64(n))
 	}
 	return e.EncodeUint8(n)
@@ -25,7 +29,7 @@ func (e *Encoder) EncodeUint16(n uint16) error {
 }
 
 func (e *Encoder) encodeUint16Cond(n uint16) error {
-	if e.flags&useCompactIntsFlag != 0 {
+	if e.flags&(useCompactIntsFlag|canonicalEncodingFlag) != 0 {
 		return e.EncodeUint(uint64(n))
 	}
 	return e.EncodeUint16(n)
@@ -37,7 +41,7 @@ func (e *Encoder) EncodeUint32(n uint32) error {
 }
 
 func (e *Encoder) encodeUint32Cond(n uint32) error {
-	if e.flags&useCompactIntsFlag != 0 {
+	if e.flags&(useCompactIntsFlag|canonicalEncodingFlag) != 0 {
 		return e.EncodeUint(uint64(n))
 	}
 	return e.EncodeUint32(n)
@@ -49,7 +53,7 @@ func (e *Encoder) EncodeUint64(n uint64) error {
 }
 
 func (e *Encoder) encodeUint64Cond(n uint64) error {
-	if e.flags&useCompactIntsFlag != 0 {
+	if e.flags&(useCompactIntsFlag|canonicalEncodingFlag) != 0 {
 		return e.EncodeUint(n)
 	}
 	return e.EncodeUint64(n)
@@ -61,7 +65,7 @@ func (e *Encoder) EncodeInt8(n int8) error {
 }
 
 func (e *Encoder) encodeInt8Cond(n int8) error {
-	if e.flags&useCompactIntsFlag != 0 {
+	if e.flags&(useCompactIntsFlag|canonicalEncodingFlag) != 0 {
 		return e.EncodeInt(int64(n))
 	}
 	return e.EncodeInt8(n)
@@ -73,7 +77,7 @@ func (e *Encoder) EncodeInt16(n int16) error {
 }
 
 func (e *Encoder) encodeInt16Cond(n int16) error {
-	if e.flags&useCompactIntsFlag != 0 {
+	if e.flags&(useCompactIntsFlag|canonicalEncodingFlag) != 0 {
 		return e.EncodeInt(int64(n))
 	}
 	return e.EncodeInt16(n)
@@ -85,7 +89,7 @@ func (e *Encoder) EncodeInt32(n int32) error {
 }
 
 func (e *Encoder) encodeInt32Cond(n int32) error {
-	if e.flags&useCompactIntsFlag != 0 {
+	if e.flags&(useCompactIntsFlag|canonicalEncodingFlag) != 0 {
 		return e.EncodeInt(int64(n))
 	}
 	return e.EncodeInt32(n)
@@ -97,7 +101,7 @@ func (e *Encoder) EncodeInt64(n int64) error {
 }
 
 func (e *Encoder) encodeInt64Cond(n int64) error {
-	if e.flags&useCompactIntsFlag != 0 {
+	if e.flags&(useCompactIntsFlag|canonicalEncodingFlag) != 0 {
 		return e.EncodeInt(n)
 	}
 	return e.EncodeInt64(n)
@@ -107,7 +111,7 @@ func (e *Encoder) encodeInt64Cond(n int64) error {
 // Type of the number is lost during encoding.
 func (e *Encoder) EncodeUint(n uint64) error {
 	if n <= math.MaxInt8 {
-		return e.w.WriteByte(byte(n))
+		return e.writeCode(byte(n))
 	}
 	if n <= math.MaxUint8 {
 		return e.EncodeUint8(uint8(n))
@@ -128,7 +132,7 @@ func (e *Encoder) EncodeInt(n int64) error {
 		return e.EncodeUint(uint64(n))
 	}
 	if n >= int64(int8(msgpcode.NegFixedNumLow)) {
-		return e.w.WriteByte(byte(n))
+		return e.writeCode(byte(n))
 	}
 	if n >= math.MinInt8 {
 		return e.EncodeInt8(int8(n))
@@ -148,6 +152,9 @@ func (e *Encoder) EncodeFloat32(n float32) error {
 			return e.EncodeInt(int64(n))
 		}
 	}
+	if e.flags&canonicalEncodingFlag != 0 && n != n {
+		return e.write4(msgpcode.Float, canonicalNaN)
+	}
 	return e.write4(msgpcode.Float, math.Float32bits(n))
 }
 
@@ -161,10 +168,22 @@ func (e *Encoder) EncodeFloat64(n float64) error {
 			return e.EncodeInt(int64(n))
 		}
 	}
+	if e.flags&canonicalEncodingFlag != 0 {
+		if n != n {
+			return e.write4(msgpcode.Float, canonicalNaN)
+		}
+		if float64(float32(n)) == n {
+			return e.write4(msgpcode.Float, math.Float32bits(float32(n)))
+		}
+	}
 	return e.write8(msgpcode.Double, math.Float64bits(n))
 }
 
 func (e *Encoder) write1(code byte, n uint8) error {
+	if e.appending {
+		e.wbuf = append(e.wbuf, code, n)
+		return nil
+	}
 	e.buf = e.buf[:2]
 	e.buf[0] = code
 	e.buf[1] = n
@@ -172,6 +191,10 @@ func (e *Encoder) write1(code byte, n uint8) error {
 }
 
 func (e *Encoder) write2(code byte, n uint16) error {
+	if e.appending {
+		e.wbuf = append(e.wbuf, code, byte(n>>8), byte(n))
+		return nil
+	}
 	e.buf = e.buf[:3]
 	e.buf[0] = code
 	e.buf[1] = byte(n >> 8)
@@ -180,6 +203,10 @@ func (e *Encoder) write2(code byte, n uint16) error {
 }
 
 func (e *Encoder) write4(code byte, n uint32) error {
+	if e.appending {
+		e.wbuf = append(e.wbuf, code, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		return nil
+	}
 	e.buf = e.buf[:5]
 	e.buf[0] = code
 	e.buf[1] = byte(n >> 24)
@@ -190,6 +217,11 @@ func (e *Encoder) write4(code byte, n uint32) error {
 }
 
 func (e *Encoder) write8(code byte, n uint64) error {
+	if e.appending {
+		e.wbuf = append(e.wbuf, code, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32),
+			byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		return nil
+	}
 	e.buf = e.buf[:9]
 	e.buf[0] = code
 	e.buf[1] = byte(n >> 56)
@@ -250,3 +282,31 @@ func encodeFloat32Value(e *Encoder, v reflect.Value) error {
 func encodeFloat64Value(e *Encoder, v reflect.Value) error {
 	return e.EncodeFloat64(v.Float())
 }
+
+// encodeNumberStringValue encodes bool and number values as strings
+// for the string tag option.
+func encodeNumberStringValue(e *Encoder, v reflect.Value) error {
+	var s string
+	switch v.Kind() {
+	case reflect.Bool:
+		s = strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(v.Int(), 10)
+	case reflect.Float32:
+		s = strconv.FormatFloat(v.Float(), 'g', -1, 32)
+	case reflect.Float64:
+		s = strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	default:
+		s = strconv.FormatUint(v.Uint(), 10)
+	}
+	return e.EncodeString(s)
+}
+
+// encodeNumberStringPtrValue encodes pointers to bool and number values
+// for the string tag option. Nil pointers are encoded as nil.
+func encodeNumberStringPtrValue(e *Encoder, v reflect.Value) error {
+	if v.IsNil() {
+		return e.EncodeNil()
+	}
+	return encodeNumberStringValue(e, v.Elem())
+}
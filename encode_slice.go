@@ -107,6 +107,10 @@ func (e *Encoder) encodeStringSlice(s []string) error {
 	if s == nil {
 		return e.EncodeNil()
 	}
+	if err := e.enter(); err != nil {
+		return err
+	}
+	defer e.leave()
 	if err := e.EncodeArrayLen(len(s)); err != nil {
 		return err
 	}
@@ -122,10 +126,19 @@ func encodeSliceValue(e *Encoder, v reflect.Value) error {
 	if v.IsNil() {
 		return e.EncodeNil()
 	}
+	if err := e.enterPtr(v); err != nil {
+		return err
+	}
+	defer e.leavePtr(v)
 	return encodeArrayValue(e, v)
 }
 
 func encodeArrayValue(e *Encoder, v reflect.Value) error {
+	if err := e.enter(); err != nil {
+		return err
+	}
+	defer e.leave()
+
 	l := v.Len()
 	if err := e.EncodeArrayLen(l); err != nil {
 		return err
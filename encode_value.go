@@ -39,21 +39,26 @@ func init() {
 	}
 }
 
-func getEncoder(typ reflect.Type) encoderFunc {
-	if v, ok := typeEncMap.Load(typ); ok {
+func (r *Registry) getEncoder(typ reflect.Type) encoderFunc {
+	cache := r.cache.Load()
+	if v, ok := cache.enc.Load(typ); ok {
 		return v.(encoderFunc)
 	}
-	fn := _getEncoder(typ)
-	typeEncMap.Store(typ, fn)
+	fn := r._getEncoder(typ)
+	cache.enc.Store(typ, fn)
 	return fn
 }
 
-func _getEncoder(typ reflect.Type) encoderFunc {
+func (r *Registry) _getEncoder(typ reflect.Type) encoderFunc {
+	if v, ok := r.encMap.Load(typ); ok {
+		return v.(encoderFunc)
+	}
+
 	kind := typ.Kind()
 
 	if kind == reflect.Ptr {
-		if _, ok := typeEncMap.Load(typ.Elem()); ok {
-			return ptrEncoderFunc(typ)
+		if _, ok := r.encMap.Load(typ.Elem()); ok {
+			return r.ptrEncoderFunc(typ)
 		}
 	}
 
@@ -93,7 +98,7 @@ func _getEncoder(typ reflect.Type) encoderFunc {
 
 	switch kind {
 	case reflect.Ptr:
-		return ptrEncoderFunc(typ)
+		return r.ptrEncoderFunc(typ)
 	case reflect.Slice:
 		elem := typ.Elem()
 		if elem.Kind() == reflect.Uint8 {
@@ -122,12 +127,16 @@ func _getEncoder(typ reflect.Type) encoderFunc {
 	return valueEncoders[kind]
 }
 
-func ptrEncoderFunc(typ reflect.Type) encoderFunc {
-	encoder := getEncoder(typ.Elem())
+func (r *Registry) ptrEncoderFunc(typ reflect.Type) encoderFunc {
+	encoder := r.getEncoder(typ.Elem())
 	return func(e *Encoder, v reflect.Value) error {
 		if v.IsNil() {
 			return e.EncodeNil()
 		}
+		if err := e.enterPtr(v); err != nil {
+			return err
+		}
+		defer e.leavePtr(v)
 		return encoder(e, v.Elem())
 	}
 }
@@ -166,8 +175,7 @@ func marshalValue(e *Encoder, v reflect.Value) error {
 	if err != nil {
 		return err
 	}
-	_, err = e.w.Write(b)
-	return err
+	return e.write(b)
 }
 
 func encodeBoolValue(e *Encoder, v reflect.Value) error {
@@ -178,6 +186,9 @@ func encodeInterfaceValue(e *Encoder, v reflect.Value) error {
 	if v.IsNil() {
 		return e.EncodeNil()
 	}
+	if e.flags&useTypeNamesFlag != 0 {
+		return e.encodeTypeName(v.Elem())
+	}
 	return e.EncodeValue(v.Elem())
 }
 
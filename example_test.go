@@ -117,6 +117,29 @@ func ExampleDecoder_Query() {
 	// 2nd phone is 54321
 }
 
+func ExampleCompileQuery() {
+	b, err := msgpack.Marshal([]map[string]interface{}{
+		{"id": 1, "status": "ok", "attrs": map[string]interface{}{"phone": 12345}},
+		{"id": 2, "status": "failed", "attrs": map[string]interface{}{"phone": 54321}},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	query := msgpack.MustCompileQuery("*.id", `[?(@.status=="ok")].attrs.phone`)
+
+	var ids []int
+	var phones []int64
+	dec := msgpack.NewDecoder(bytes.NewBuffer(b))
+	if err := query.ExecInto(dec, &ids, &phones); err != nil {
+		panic(err)
+	}
+	fmt.Println("ids are", ids)
+	fmt.Println("ok phones are", phones)
+	// Output: ids are [1 2]
+	// ok phones are [12345]
+}
+
 func ExampleEncoder_UseArrayEncodedStructs() {
 	type Item struct {
 		Foo string
@@ -13,58 +13,100 @@ type extInfo struct {
 	Decoder func(d *Decoder, v reflect.Value, extLen int) error
 }
 
-var extTypes = make(map[int8]*extInfo)
-
 type MarshalerUnmarshaler interface {
 	Marshaler
 	Unmarshaler
 }
 
+// RegisterExt registers the ext id for the type with the default registry.
 func RegisterExt(extID int8, value MarshalerUnmarshaler) {
-	RegisterExtEncoder(extID, value, func(e *Encoder, v reflect.Value) ([]byte, error) {
+	defaultRegistry.RegisterExt(extID, value)
+}
+
+// UnregisterExt unregisters the ext id with the default registry.
+func UnregisterExt(extID int8) {
+	defaultRegistry.UnregisterExt(extID)
+}
+
+// RegisterExtEncoder registers the ext encoder with the default registry.
+func RegisterExtEncoder(
+	extID int8,
+	value interface{},
+	encoder func(enc *Encoder, v reflect.Value) ([]byte, error),
+) {
+	defaultRegistry.RegisterExtEncoder(extID, value, encoder)
+}
+
+// RegisterExtDecoder registers the ext decoder with the default registry.
+func RegisterExtDecoder(
+	extID int8,
+	value interface{},
+	decoder func(dec *Decoder, v reflect.Value, extLen int) error,
+) {
+	defaultRegistry.RegisterExtDecoder(extID, value, decoder)
+}
+
+// RegisterExt registers the ext id for the type implementing
+// Marshaler and Unmarshaler.
+func (r *Registry) RegisterExt(extID int8, value MarshalerUnmarshaler) {
+	r.RegisterExtEncoder(extID, value, func(e *Encoder, v reflect.Value) ([]byte, error) {
 		marshaler := v.Interface().(Marshaler)
 		return marshaler.MarshalMsgpack()
 	})
-	RegisterExtDecoder(extID, value, func(d *Decoder, v reflect.Value, extLen int) error {
+	r.RegisterExtDecoder(extID, value, func(d *Decoder, v reflect.Value, extLen int) error {
 		b, err := d.readN(extLen)
 		if err != nil {
 			return err
 		}
+		if d.data != nil && !d.noCopy {
+			// The Unmarshaler may keep b, so it must not alias the input.
+			b = append(make([]byte, 0, len(b)), b...)
+		}
 		return v.Interface().(Unmarshaler).UnmarshalMsgpack(b)
 	})
 }
 
-func UnregisterExt(extID int8) {
-	unregisterExtEncoder(extID)
-	unregisterExtDecoder(extID)
+// UnregisterExt unregisters the ext encoder and decoder for the ext id.
+func (r *Registry) UnregisterExt(extID int8) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.unregisterExtEncoder(extID)
+	r.unregisterExtDecoder(extID)
+	r.resetCache()
 }
 
-func RegisterExtEncoder(
+// RegisterExtEncoder registers the encoder for the type and the ext id.
+func (r *Registry) RegisterExtEncoder(
 	extID int8,
 	value interface{},
 	encoder func(enc *Encoder, v reflect.Value) ([]byte, error),
 ) {
-	unregisterExtEncoder(extID)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.unregisterExtEncoder(extID)
 
 	typ := reflect.TypeOf(value)
 	extEncoder := makeExtEncoder(extID, typ, encoder)
-	typeEncMap.Store(extID, typ)
-	typeEncMap.Store(typ, extEncoder)
+	r.encMap.Store(extID, typ)
+	r.encMap.Store(typ, extEncoder)
 	if typ.Kind() == reflect.Ptr {
-		typeEncMap.Store(typ.Elem(), makeExtEncoderAddr(extEncoder))
+		r.encMap.Store(typ.Elem(), makeExtEncoderAddr(extEncoder))
 	}
+	r.resetCache()
 }
 
-func unregisterExtEncoder(extID int8) {
-	t, ok := typeEncMap.Load(extID)
+func (r *Registry) unregisterExtEncoder(extID int8) {
+	t, ok := r.encMap.Load(extID)
 	if !ok {
 		return
 	}
-	typeEncMap.Delete(extID)
+	r.encMap.Delete(extID)
 	typ := t.(reflect.Type)
-	typeEncMap.Delete(typ)
+	r.encMap.Delete(typ)
 	if typ.Kind() == reflect.Ptr {
-		typeEncMap.Delete(typ.Elem())
+		r.encMap.Delete(typ.Elem())
 	}
 }
 
@@ -102,38 +144,43 @@ func makeExtEncoderAddr(extEncoder encoderFunc) encoderFunc {
 	}
 }
 
-func RegisterExtDecoder(
+// RegisterExtDecoder registers the decoder for the type and the ext id.
+func (r *Registry) RegisterExtDecoder(
 	extID int8,
 	value interface{},
 	decoder func(dec *Decoder, v reflect.Value, extLen int) error,
 ) {
-	unregisterExtDecoder(extID)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.unregisterExtDecoder(extID)
 
 	typ := reflect.TypeOf(value)
 	extDecoder := makeExtDecoder(extID, typ, decoder)
-	extTypes[extID] = &extInfo{
+	r.extTypes[extID] = &extInfo{
 		Type:    typ,
 		Decoder: decoder,
 	}
 
-	typeDecMap.Store(extID, typ)
-	typeDecMap.Store(typ, extDecoder)
+	r.decMap.Store(extID, typ)
+	r.decMap.Store(typ, extDecoder)
 	if typ.Kind() == reflect.Ptr {
-		typeDecMap.Store(typ.Elem(), makeExtDecoderAddr(extDecoder))
+		r.decMap.Store(typ.Elem(), makeExtDecoderAddr(extDecoder))
 	}
+	r.resetCache()
 }
 
-func unregisterExtDecoder(extID int8) {
-	t, ok := typeDecMap.Load(extID)
+func (r *Registry) unregisterExtDecoder(extID int8) {
+	t, ok := r.decMap.Load(extID)
 	if !ok {
 		return
 	}
-	typeDecMap.Delete(extID)
-	delete(extTypes, extID)
+	r.decMap.Delete(extID)
+	delete(r.extTypes, extID)
 	typ := t.(reflect.Type)
-	typeDecMap.Delete(typ)
+	r.decMap.Delete(typ)
 	if typ.Kind() == reflect.Ptr {
-		typeDecMap.Delete(typ.Elem())
+		r.decMap.Delete(typ.Elem())
 	}
 }
 
@@ -167,7 +214,7 @@ func (e *Encoder) EncodeExtHeader(extID int8, extLen int) error {
 	if err := e.encodeExtLen(extLen); err != nil {
 		return err
 	}
-	if err := e.w.WriteByte(byte(extID)); err != nil {
+	if err := e.writeCode(byte(extID)); err != nil {
 		return err
 	}
 	return nil
@@ -218,6 +265,17 @@ func (d *Decoder) extHeader(c byte) (int8, int, error) {
 }
 
 func (d *Decoder) parseExtLen(c byte) (int, error) {
+	n, err := d.extLen(c)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.checkLen("MaxStringLen", d.limits.MaxStringLen, n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (d *Decoder) extLen(c byte) (int, error) {
 	switch c {
 	case msgpcode.FixExt1:
 		return 1, nil
@@ -239,7 +297,7 @@ func (d *Decoder) parseExtLen(c byte) (int, error) {
 		n, err := d.uint32()
 		return int(n), err
 	default:
-		return 0, fmt.Errorf("msgpack: invalid code=%x decoding ext len", c)
+		return 0, invalidCodeError{code: c, hint: "ext len"}
 	}
 }
 
@@ -249,7 +307,11 @@ func (d *Decoder) decodeInterfaceExt(c byte) (interface{}, error) {
 		return nil, err
 	}
 
-	info, ok := extTypes[extID]
+	if extID == TypeNameExtID {
+		return d.decodeTypeName(extLen)
+	}
+
+	info, ok := d.registry().extInfo(extID)
 	if !ok {
 		return nil, fmt.Errorf("msgpack: unknown ext id=%d", extID)
 	}
@@ -9,14 +9,23 @@ import (
 )
 
 const (
-	minInternedStringLen = 3
-	maxDictLen           = math.MaxUint16
+	// InternedStringExtID is the ext type of strings interned by
+	// Encoder.UseInternedStrings. The ext data is the big-endian uint8, uint16
+	// or uint32 index of the string in the dictionary.
+	InternedStringExtID int8 = math.MinInt8
+	// MinInternedStringLen is the minimum length of interned strings.
+	// Shorter strings are encoded as is and not added to the dictionary.
+	MinInternedStringLen = 3
+	// MaxInternedStrings is the maximum size of the dictionary. Strings
+	// encoded after it is full are encoded as is.
+	MaxInternedStrings = math.MaxUint16
 )
 
-var internedStringExtID = int8(math.MinInt8)
+// internedStringExtCode is InternedStringExtID written as a byte.
+const internedStringExtCode byte = 0x80
 
-func init() {
-	extTypes[internedStringExtID] = &extInfo{
+func registerInternedStrings(r *Registry) {
+	r.extTypes[InternedStringExtID] = &extInfo{
 		Type:    stringType,
 		Decoder: decodeInternedStringExt,
 	}
@@ -55,13 +64,20 @@ func encodeInternedStringValue(e *Encoder, v reflect.Value) error {
 	return e.encodeInternedString(v.String(), true)
 }
 
+// EncodeInternedString encodes s the same way as a struct field with the intern
+// tag option: s is added to the encoder dict and subsequent occurrences
+// of s are encoded as an index into the dict.
+func (e *Encoder) EncodeInternedString(s string) error {
+	return e.encodeInternedString(s, true)
+}
+
 func (e *Encoder) encodeInternedString(s string, intern bool) error {
 	// Interned string takes at least 3 bytes. Plain string 1 byte + string len.
 	if idx, ok := e.dict[s]; ok {
 		return e.encodeInternedStringIndex(idx)
 	}
 
-	if intern && len(s) >= minInternedStringLen && len(e.dict) < maxDictLen {
+	if intern && len(s) >= MinInternedStringLen && len(e.dict) < MaxInternedStrings {
 		if e.dict == nil {
 			e.dict = make(map[string]int)
 		}
@@ -77,21 +93,21 @@ func (e *Encoder) encodeInternedStringIndex(idx int) error {
 		if err := e.writeCode(msgpcode.FixExt1); err != nil {
 			return err
 		}
-		return e.write1(byte(internedStringExtID), uint8(idx))
+		return e.write1(internedStringExtCode, uint8(idx))
 	}
 
 	if idx <= math.MaxUint16 {
 		if err := e.writeCode(msgpcode.FixExt2); err != nil {
 			return err
 		}
-		return e.write2(byte(internedStringExtID), uint16(idx))
+		return e.write2(internedStringExtCode, uint16(idx))
 	}
 
 	if uint64(idx) <= math.MaxUint32 {
 		if err := e.writeCode(msgpcode.FixExt4); err != nil {
 			return err
 		}
-		return e.write4(byte(internedStringExtID), uint32(idx))
+		return e.write4(internedStringExtCode, uint32(idx))
 	}
 
 	return fmt.Errorf("msgpack: interned string index=%d is too large", idx)
@@ -111,7 +127,7 @@ func decodeInternedInterfaceValue(d *Decoder, v reflect.Value) error {
 		}
 	}
 
-	if err := d.s.UnreadByte(); err != nil {
+	if err := d.unreadByte(); err != nil {
 		return err
 	}
 	return decodeInterfaceValue(d, v)
@@ -127,6 +143,12 @@ func decodeInternedStringValue(d *Decoder, v reflect.Value) error {
 	return nil
 }
 
+// DecodeInternedString decodes a string encoded with EncodeInternedString
+// and adds it to the decoder dict.
+func (d *Decoder) DecodeInternedString() (string, error) {
+	return d.decodeInternedString(true)
+}
+
 func (d *Decoder) decodeInternedString(intern bool) (string, error) {
 	c, err := d.readCode()
 	if err != nil {
@@ -146,9 +168,9 @@ func (d *Decoder) decodeInternedString(intern bool) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		if typeID != internedStringExtID {
+		if typeID != InternedStringExtID {
 			err := fmt.Errorf("msgpack: got ext type=%d, wanted %d",
-				typeID, internedStringExtID)
+				typeID, InternedStringExtID)
 			return "", err
 		}
 
@@ -222,13 +244,16 @@ func (d *Decoder) decodeInternedStringWithLen(n int, intern bool) (string, error
 	if n <= 0 {
 		return "", nil
 	}
+	if err := d.checkLen("MaxStringLen", d.limits.MaxStringLen, n); err != nil {
+		return "", err
+	}
 
 	s, err := d.stringWithLen(n)
 	if err != nil {
 		return "", err
 	}
 
-	if intern && len(s) >= minInternedStringLen && len(d.dict) < maxDictLen {
+	if intern && len(s) >= MinInternedStringLen && len(d.dict) < MaxInternedStrings {
 		d.dict = append(d.dict, s)
 	}
 
@@ -1,6 +1,11 @@
 package msgpack
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
 
 type Marshaler interface {
 	MarshalMsgpack() ([]byte, error)
@@ -50,3 +55,133 @@ type unexpectedCodeError struct {
 func (err unexpectedCodeError) Error() string {
 	return fmt.Sprintf("msgpack: unexpected code=%x decoding %s", err.code, err.hint)
 }
+
+type invalidCodeError struct {
+	hint string
+	code byte
+}
+
+func (err invalidCodeError) Error() string {
+	return fmt.Sprintf("msgpack: invalid code=%x decoding %s", err.code, err.hint)
+}
+
+//------------------------------------------------------------------------------
+
+// A DecodeError describes an error that occurred while decoding a value.
+// Use errors.As to get it from the error returned by Decoder.Decode
+// or Unmarshal, and errors.Is or errors.As to inspect the cause.
+type DecodeError struct {
+	// Offset is the input offset of the value that could not be decoded.
+	Offset int64
+	// Path is the location of the value, e.g. "Order.Items[3].Price".
+	// It is empty if the error occurred in the top-level value.
+	Path string
+	// Type is the Go type that was being decoded, if known.
+	Type reflect.Type
+	// Code is the msgpcode that caused the error or -1 if the error
+	// is not caused by an unexpected code.
+	Code int
+	// Err is the underlying error.
+	Err error
+
+	// elems holds the path elements, e.g. ".Price" or "[3]", starting from
+	// the innermost one as the decoder unwinds. Only maxErrorPathLen
+	// elements are kept; the number of dropped outer ones is in dropped.
+	elems   []string
+	dropped int
+	// root is the name of the outermost struct if the path starts with a field.
+	root string
+}
+
+// maxErrorPathLen is the number of innermost path elements kept
+// by DecodeError, so deeply nested input doesn't produce huge errors.
+const maxErrorPathLen = 32
+
+func (e *DecodeError) Error() string {
+	path := e.path()
+	if path == "" {
+		return e.Err.Error()
+	}
+	msg := strings.TrimPrefix(e.Err.Error(), "msgpack: ")
+	return fmt.Sprintf("msgpack: decoding %s at offset %d: %s", path, e.Offset, msg)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *DecodeError) addField(strct reflect.Type, name string) {
+	if len(e.elems) < maxErrorPathLen {
+		e.elems = append(e.elems, "."+name)
+	} else {
+		e.dropped++
+	}
+	e.root = strct.Name()
+}
+
+func (e *DecodeError) addIndex(index string) {
+	if len(e.elems) < maxErrorPathLen {
+		e.elems = append(e.elems, "["+index+"]")
+	} else {
+		e.dropped++
+	}
+	e.root = ""
+}
+
+// path joins the path elements. Dropped elements are replaced with "...",
+// e.g. "Tree...[0].Value".
+func (e *DecodeError) path() string {
+	if len(e.elems) == 0 {
+		return e.Path
+	}
+
+	var b strings.Builder
+	b.WriteString(e.root)
+	if e.dropped > 0 {
+		b.WriteString("...")
+	}
+	for i := len(e.elems) - 1; i >= 0; i-- {
+		elem := e.elems[i]
+		if i == len(e.elems)-1 && (e.dropped > 0 || e.root == "") {
+			elem = strings.TrimPrefix(elem, ".")
+		}
+		b.WriteString(elem)
+	}
+	return b.String()
+}
+
+// setPath sets Path once the error is returned to the caller.
+func (e *DecodeError) setPath() {
+	e.Path = e.path()
+}
+
+// MissingFieldsError is returned by Decoder when the input lacks fields
+// with the required tag option. It lists all missing fields of the struct.
+type MissingFieldsError struct {
+	// Type is the struct type.
+	Type reflect.Type
+	// Fields are the encoded names of the missing fields.
+	Fields []string
+}
+
+func (e *MissingFieldsError) Error() string {
+	names := make([]string, len(e.Fields))
+	for i, name := range e.Fields {
+		names[i] = strconv.Quote(name)
+	}
+	noun := "fields"
+	if len(names) == 1 {
+		noun = "field"
+	}
+	return fmt.Sprintf("msgpack: %s is missing required %s %s", e.Type, noun, strings.Join(names, ", "))
+}
+
+func errorCode(err error) int {
+	switch err := err.(type) {
+	case unexpectedCodeError:
+		return int(err.code)
+	case invalidCodeError:
+		return int(err.code)
+	}
+	return -1
+}
@@ -553,3 +553,51 @@ func TestPtrValueDecode(t *testing.T) {
 	require.NotNil(t, foo.Bar)
 	require.Equal(t, *foo.Bar, bar2)
 }
+
+func TestAppendMarshal(t *testing.T) {
+	prefix := []byte("prefix")
+	b, err := msgpack.AppendMarshal(prefix, map[string]interface{}{"foo": "bar"})
+	require.Nil(t, err)
+	require.Equal(t, "prefix", string(b[:len(prefix)]))
+
+	var out map[string]interface{}
+	require.Nil(t, msgpack.Unmarshal(b[len(prefix):], &out))
+	require.Equal(t, map[string]interface{}{"foo": "bar"}, out)
+
+	b, err = msgpack.AppendMarshal(prefix, make(chan bool))
+	require.NotNil(t, err)
+	require.Equal(t, prefix, b)
+}
+
+func TestEncoderResetBytes(t *testing.T) {
+	var buf bytes.Buffer
+	wanted := msgpack.NewEncoder(&buf)
+	wanted.UseCompactInts(true)
+
+	enc := msgpack.NewEncoder(nil)
+	enc.UseCompactInts(true)
+
+	b := make([]byte, 0, 64)
+	for i := 0; i < 3; i++ {
+		enc.ResetBytes(b[:0])
+		require.Nil(t, enc.EncodeMulti(
+			int64(i), uint16(1000), "hello", []byte("world"), float32(1.5),
+			[]string{"a", "b"}, time.Unix(int64(i), 0),
+		))
+		require.Nil(t, enc.EncodeArrayLen(1))
+		require.Nil(t, enc.EncodeExtHeader(1, 1))
+		_, err := enc.Writer().Write([]byte{0})
+		require.Nil(t, err)
+
+		buf.Reset()
+		require.Nil(t, wanted.EncodeMulti(
+			int64(i), uint16(1000), "hello", []byte("world"), float32(1.5),
+			[]string{"a", "b"}, time.Unix(int64(i), 0),
+		))
+		require.Nil(t, wanted.EncodeArrayLen(1))
+		require.Nil(t, wanted.EncodeExtHeader(1, 1))
+		require.Nil(t, buf.WriteByte(0))
+
+		require.Equal(t, buf.Bytes(), enc.Bytes())
+	}
+}
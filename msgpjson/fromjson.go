@@ -0,0 +1,219 @@
+package msgpjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// FromJSON reads JSON values from r until EOF and writes them to w as
+// MessagePack values. Integers are encoded as the smallest MessagePack int
+// that holds them and other numbers as float64. Strings are always encoded
+// as strings, i.e. bin and ext values produced by ToJSON are not restored.
+//
+// MessagePack arrays and maps are prefixed with their length, so each
+// top-level JSON value is buffered before it is written to w. The largest
+// header is reserved when a container is opened and the unused bytes are
+// removed once the value is complete.
+func (cfg *Config) FromJSON(w io.Writer, r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	f := &fromJSON{}
+	f.enc = msgpack.NewEncoder(&f.buf)
+	f.enc.UseInternedStrings(cfg.InternedStrings)
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				if len(f.stack) > 0 {
+					return io.ErrUnexpectedEOF
+				}
+				return nil
+			}
+			return err
+		}
+
+		if err := f.token(tok); err != nil {
+			return err
+		}
+
+		if len(f.stack) == 0 {
+			f.compact()
+			if _, err := w.Write(f.buf.b); err != nil {
+				return err
+			}
+			f.buf.b = f.buf.b[:0]
+		}
+	}
+}
+
+type fromJSON struct {
+	enc   *msgpack.Encoder
+	buf   appendBuffer
+	stack []container
+	// gaps are the unused bytes of the reserved headers in the order
+	// the containers were opened, i.e. sorted by offset.
+	gaps []gap
+}
+
+// maxHeaderLen is the length of the array32 and map32 headers.
+const maxHeaderLen = 5
+
+// container is an array or a map which header is written
+// once all the elements are known. The header space is reserved
+// when the container is opened.
+type container struct {
+	start int
+	n     int
+	isMap bool
+	gap   int
+}
+
+type gap struct {
+	pos int
+	n   int
+}
+
+func (f *fromJSON) token(tok json.Token) error {
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '[', '{':
+			if len(f.stack) >= maxDepth {
+				return fmt.Errorf("msgpjson: exceeded max depth of %d", maxDepth)
+			}
+			f.stack = append(f.stack, container{
+				start: len(f.buf.b),
+				isMap: tok == '{',
+				gap:   len(f.gaps),
+			})
+			f.gaps = append(f.gaps, gap{})
+			f.buf.b = append(f.buf.b, make([]byte, maxHeaderLen)...)
+			return nil
+		default:
+			c := f.stack[len(f.stack)-1]
+			f.stack = f.stack[:len(f.stack)-1]
+			f.writeHeader(c)
+			f.next()
+			return nil
+		}
+	case nil:
+		if err := f.enc.EncodeNil(); err != nil {
+			return err
+		}
+	case bool:
+		if err := f.enc.EncodeBool(tok); err != nil {
+			return err
+		}
+	case json.Number:
+		if err := f.number(string(tok)); err != nil {
+			return err
+		}
+	case string:
+		if err := f.enc.EncodeString(tok); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("msgpjson: unexpected JSON token %v", tok)
+	}
+
+	f.next()
+	return nil
+}
+
+// next counts an encoded element of the innermost container.
+func (f *fromJSON) next() {
+	if len(f.stack) > 0 {
+		f.stack[len(f.stack)-1].n++
+	}
+}
+
+func (f *fromJSON) number(s string) error {
+	if !strings.ContainsAny(s, ".eE") {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return f.enc.EncodeInt(n)
+		}
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return f.enc.EncodeUint(n)
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("msgpjson: invalid number %q", s)
+	}
+	return f.enc.EncodeFloat64(n)
+}
+
+// writeHeader writes the array or map header to the space reserved
+// before the container elements. The unused bytes are removed by compact.
+func (f *fromJSON) writeHeader(c container) {
+	var hdr [maxHeaderLen]byte
+	h := hdr[:0]
+
+	n := c.n
+	if c.isMap {
+		n /= 2
+		switch {
+		case n <= 15:
+			h = append(h, msgpcode.FixedMapLow|byte(n))
+		case n <= math.MaxUint16:
+			h = append(h, msgpcode.Map16, byte(n>>8), byte(n))
+		default:
+			h = append(h, msgpcode.Map32, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		}
+	} else {
+		switch {
+		case n <= 15:
+			h = append(h, msgpcode.FixedArrayLow|byte(n))
+		case n <= math.MaxUint16:
+			h = append(h, msgpcode.Array16, byte(n>>8), byte(n))
+		default:
+			h = append(h, msgpcode.Array32, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		}
+	}
+
+	copy(f.buf.b[c.start:], h)
+	f.gaps[c.gap] = gap{pos: c.start + len(h), n: maxHeaderLen - len(h)}
+}
+
+// compact removes the unused header bytes of a top-level value,
+// moving every byte at most once.
+func (f *fromJSON) compact() {
+	if len(f.gaps) == 0 {
+		return
+	}
+
+	b := f.buf.b
+	w := f.gaps[0].pos
+	for i, g := range f.gaps {
+		end := len(b)
+		if i+1 < len(f.gaps) {
+			end = f.gaps[i+1].pos
+		}
+		w += copy(b[w:], b[g.pos+g.n:end])
+	}
+	f.buf.b = b[:w]
+	f.gaps = f.gaps[:0]
+}
+
+type appendBuffer struct {
+	b []byte
+}
+
+func (w *appendBuffer) Write(b []byte) (int, error) {
+	w.b = append(w.b, b...)
+	return len(b), nil
+}
+
+func (w *appendBuffer) WriteByte(c byte) error {
+	w.b = append(w.b, c)
+	return nil
+}
@@ -0,0 +1,104 @@
+// Package msgpjson converts between MessagePack and JSON without building
+// intermediate Go values.
+//
+// MessagePack is richer than JSON, so the conversion to JSON is lossy:
+// bin values become base64 or hex strings, non-string map keys become strings
+// and ext values are rendered by Config.Ext. Converting the JSON back produces
+// strings in place of those values.
+package msgpjson
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// BinEncoding is the JSON string encoding of MessagePack bin values.
+type BinEncoding int
+
+const (
+	// Base64 is the standard base64 encoding with padding, like encoding/json uses for []byte.
+	Base64 BinEncoding = iota
+	// Hex is the lowercase hexadecimal encoding.
+	Hex
+)
+
+// NonFinite controls how NaN and infinite floats are written to JSON,
+// which has no representation for them.
+type NonFinite int
+
+const (
+	// NonFiniteError fails the conversion.
+	NonFiniteError NonFinite = iota
+	// NonFiniteNull writes null.
+	NonFiniteNull
+	// NonFiniteString writes "NaN", "+Inf" or "-Inf".
+	NonFiniteString
+)
+
+// ExtFunc returns the JSON encoding of the ext value with the type id and
+// payload data. It returns nil to fall back to the default representation,
+// which is an object with the "type" and "data" fields.
+type ExtFunc func(id int8, data []byte) ([]byte, error)
+
+const maxDepth = 10000
+
+// Config holds the conversion options. The zero Config is ready to use.
+type Config struct {
+	// Bin is the encoding of bin values.
+	Bin BinEncoding
+	// NonFinite is the policy for NaN and infinite floats.
+	NonFinite NonFinite
+	// Ext renders ext values. Timestamps are rendered by TimeExt if Ext is nil
+	// or returns nil for them.
+	Ext ExtFunc
+	// InternedStrings resolves strings interned by Encoder.UseInternedStrings.
+	InternedStrings bool
+}
+
+var defaultConfig Config
+
+// ToJSON is like Config.ToJSON with the zero Config.
+func ToJSON(w io.Writer, r io.Reader) error {
+	return defaultConfig.ToJSON(w, r)
+}
+
+// FromJSON is like Config.FromJSON with the zero Config.
+func FromJSON(w io.Writer, r io.Reader) error {
+	return defaultConfig.FromJSON(w, r)
+}
+
+// TimeExt renders MessagePack timestamps as RFC 3339 strings in UTC and
+// times encoded with msgpack.TimeFormatExtZone with their UTC offset.
+// It returns nil for other ext types.
+func TimeExt(id int8, data []byte) ([]byte, error) {
+	if id != msgpack.TimeExtID && id != msgpack.TimeZoneExtID {
+		return nil, nil
+	}
+	tm, err := msgpack.DecodeTimeExt(id, data)
+	if err != nil {
+		return nil, err
+	}
+	if id == msgpack.TimeExtID {
+		tm = tm.UTC()
+	}
+
+	b := make([]byte, 0, len(time.RFC3339Nano)+2)
+	b = append(b, '"')
+	b = tm.AppendFormat(b, time.RFC3339Nano)
+	b = append(b, '"')
+	return b, nil
+}
+
+func appendNonFinite(b []byte, f float64, policy NonFinite) ([]byte, error) {
+	switch policy {
+	case NonFiniteNull:
+		return append(b, "null"...), nil
+	case NonFiniteString:
+		return strconv.AppendQuote(b, strconv.FormatFloat(f, 'g', -1, 64)), nil
+	}
+	return b, fmt.Errorf("msgpjson: unsupported float value %v", f)
+}
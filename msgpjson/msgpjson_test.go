@@ -0,0 +1,215 @@
+package msgpjson_test
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpjson"
+)
+
+func toJSON(t *testing.T, cfg *msgpjson.Config, values ...interface{}) string {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for _, v := range values {
+		require.Nil(t, enc.Encode(v))
+	}
+
+	var out bytes.Buffer
+	require.Nil(t, cfg.ToJSON(&out, &buf))
+	return out.String()
+}
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		wanted string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{int64(-1 << 40), "-1099511627776"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{float32(1.5), "1.5"},
+		{1e-7, "1e-7"},
+		{"a\"b\\c\n\x01\u2028", `"a\"b\\c\n\u0001\u2028"`},
+		{"\xff", `"\ufffd"`},
+		{[]byte{1, 2, 3}, `"AQID"`},
+		{[]interface{}{1, "a", nil}, `[1,"a",null]`},
+		{map[string]interface{}{"a": []int{1}}, `{"a":[1]}`},
+		{map[int]bool{1: true}, `{"1":true}`},
+		{map[interface{}]interface{}{nil: 1}, `{"null":1}`},
+		{map[bool]string{true: "x"}, `{"true":"x"}`},
+		{time.Unix(1, 5).UTC(), `"1970-01-01T00:00:01.000000005Z"`},
+	}
+	for _, test := range tests {
+		got := toJSON(t, &msgpjson.Config{}, test.value)
+		require.Equal(t, test.wanted+"\n", got, "%#v", test.value)
+	}
+}
+
+func TestToJSONTimeZone(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetTimeFormat(msgpack.TimeFormatExtZone)
+	require.Nil(t, enc.Encode([]time.Time{
+		time.Unix(1, 0).UTC(),
+		time.Unix(1, 0).In(time.FixedZone("X", -3600)),
+	}))
+
+	var out bytes.Buffer
+	require.Nil(t, msgpjson.ToJSON(&out, &buf))
+	require.Equal(t, `["1970-01-01T00:00:01Z","1969-12-31T23:00:01-01:00"]`+"\n", out.String())
+}
+
+func TestToJSONStream(t *testing.T) {
+	got := toJSON(t, &msgpjson.Config{}, 1, "a", []int{2})
+	require.Equal(t, "1\n\"a\"\n[2]\n", got)
+}
+
+func TestToJSONBinHex(t *testing.T) {
+	got := toJSON(t, &msgpjson.Config{Bin: msgpjson.Hex}, []byte{0xca, 0xfe})
+	require.Equal(t, "\"cafe\"\n", got)
+}
+
+func TestToJSONNonFinite(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, msgpack.NewEncoder(&buf).Encode(math.NaN()))
+	err := msgpjson.ToJSON(&bytes.Buffer{}, bytes.NewReader(buf.Bytes()))
+	require.EqualError(t, err, "msgpjson: unsupported float value NaN")
+
+	cfg := &msgpjson.Config{NonFinite: msgpjson.NonFiniteNull}
+	require.Equal(t, "null\n", toJSON(t, cfg, math.Inf(1)))
+
+	cfg = &msgpjson.Config{NonFinite: msgpjson.NonFiniteString}
+	require.Equal(t, "[\"NaN\",\"+Inf\",\"-Inf\"]\n",
+		toJSON(t, cfg, []float64{math.NaN(), math.Inf(1), math.Inf(-1)}))
+}
+
+func TestToJSONExt(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	require.Nil(t, enc.EncodeExtHeader(7, 2))
+	_, err := buf.Write([]byte{1, 2})
+	require.Nil(t, err)
+	data := buf.Bytes()
+
+	var out bytes.Buffer
+	require.Nil(t, msgpjson.ToJSON(&out, bytes.NewReader(data)))
+	require.Equal(t, "{\"type\":7,\"data\":\"AQI=\"}\n", out.String())
+
+	cfg := &msgpjson.Config{
+		Ext: func(id int8, data []byte) ([]byte, error) {
+			if id == 7 {
+				return []byte(`"seven"`), nil
+			}
+			return nil, nil
+		},
+	}
+	out.Reset()
+	require.Nil(t, cfg.ToJSON(&out, bytes.NewReader(data)))
+	require.Equal(t, "\"seven\"\n", out.String())
+}
+
+func TestToJSONInternedStrings(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseInternedStrings(true)
+	require.Nil(t, enc.Encode([]map[string]string{{"key": "value"}, {"key": "value"}}))
+
+	var out bytes.Buffer
+	cfg := &msgpjson.Config{InternedStrings: true}
+	require.Nil(t, cfg.ToJSON(&out, &buf))
+	require.Equal(t, "[{\"key\":\"value\"},{\"key\":\"value\"}]\n", out.String())
+}
+
+func TestToJSONInvalid(t *testing.T) {
+	err := msgpjson.ToJSON(&bytes.Buffer{}, bytes.NewReader([]byte{0xc1}))
+	require.EqualError(t, err, "msgpjson: invalid code=c1")
+
+	err = msgpjson.ToJSON(&bytes.Buffer{}, bytes.NewReader([]byte{0x92, 0x01}))
+	require.NotNil(t, err)
+}
+
+func TestFromJSON(t *testing.T) {
+	in := `{"a":[1,-2,18446744073709551615,1.5,"s",true,null],"b":{}}` + "\n" + `"x"`
+
+	var out bytes.Buffer
+	require.Nil(t, msgpjson.FromJSON(&out, strings.NewReader(in)))
+
+	dec := msgpack.NewDecoder(&out)
+	v, err := dec.DecodeInterface()
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{
+		"a": []interface{}{
+			int8(1), int8(-2), uint64(math.MaxUint64), 1.5, "s", true, nil,
+		},
+		"b": map[string]interface{}{},
+	}, v)
+
+	s, err := dec.DecodeString()
+	require.Nil(t, err)
+	require.Equal(t, "x", s)
+}
+
+func TestFromJSONLarge(t *testing.T) {
+	in := "[" + strings.Repeat("1,", 70000) + "1]"
+
+	var out bytes.Buffer
+	require.Nil(t, msgpjson.FromJSON(&out, strings.NewReader(in)))
+
+	var v []int
+	require.Nil(t, msgpack.Unmarshal(out.Bytes(), &v))
+	require.Len(t, v, 70001)
+}
+
+func TestFromJSONNested(t *testing.T) {
+	const depth = 1000
+	in := strings.Repeat(`[{"k":`, depth) + "[" + strings.Repeat("1,", 20) + "1]" + strings.Repeat("}]", depth)
+
+	var out bytes.Buffer
+	require.Nil(t, msgpjson.FromJSON(&out, strings.NewReader(in+"\n"+in)))
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for i := 0; i < depth; i++ {
+		require.Nil(t, enc.EncodeArrayLen(1))
+		require.Nil(t, enc.EncodeMapLen(1))
+		require.Nil(t, enc.EncodeString("k"))
+	}
+	require.Nil(t, enc.EncodeArrayLen(21))
+	for i := 0; i < 21; i++ {
+		require.Nil(t, enc.EncodeInt(1))
+	}
+	wanted := buf.Bytes()
+	require.Equal(t, append(wanted[:len(wanted):len(wanted)], wanted...), out.Bytes())
+}
+
+func TestFromJSONInternedStrings(t *testing.T) {
+	in := `[{"key":"value"},{"key":"value"}]`
+
+	var out bytes.Buffer
+	cfg := &msgpjson.Config{InternedStrings: true}
+	require.Nil(t, cfg.FromJSON(&out, strings.NewReader(in)))
+
+	dec := msgpack.NewDecoder(&out)
+	dec.UseInternedStrings(true)
+	var v []map[string]string
+	require.Nil(t, dec.Decode(&v))
+	require.Equal(t, []map[string]string{{"key": "value"}, {"key": "value"}}, v)
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := `{"a":[1,2.5,"x",{"b":null}],"c":false}` + "\n"
+
+	var mp bytes.Buffer
+	require.Nil(t, msgpjson.FromJSON(&mp, strings.NewReader(in)))
+
+	var out bytes.Buffer
+	require.Nil(t, msgpjson.ToJSON(&out, &mp))
+	require.JSONEq(t, in, out.String())
+}
@@ -0,0 +1,423 @@
+package msgpjson
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// flushSize is the size of the JSON buffered before it is written out.
+const flushSize = 32 << 10
+
+// maxChunk bounds the memory allocated for an ext payload ahead of reading it.
+const maxChunk = 64 << 10
+
+// ToJSON reads MessagePack values from r until EOF and writes them
+// to w as JSON values separated by newlines.
+func (cfg *Config) ToJSON(w io.Writer, r io.Reader) error {
+	t := &toJSON{
+		cfg: cfg,
+		d:   msgpack.NewDecoder(r),
+		w:   bufio.NewWriter(w),
+	}
+	for {
+		if _, err := t.d.PeekCode(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := t.value(); err != nil {
+			return err
+		}
+		t.buf = append(t.buf, '\n')
+		if err := t.flush(); err != nil {
+			return err
+		}
+		if err := t.w.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+type toJSON struct {
+	cfg   *Config
+	d     *msgpack.Decoder
+	w     *bufio.Writer
+	buf   []byte
+	dict  []string
+	depth int
+	// keys is the number of map keys being rendered. Keys are rendered
+	// into buf and quoted afterwards, so buf can't be flushed meanwhile.
+	keys int
+}
+
+func (t *toJSON) flush() error {
+	_, err := t.w.Write(t.buf)
+	t.buf = t.buf[:0]
+	return err
+}
+
+func (t *toJSON) maybeFlush() error {
+	if t.keys == 0 && len(t.buf) >= flushSize {
+		return t.flush()
+	}
+	return nil
+}
+
+func (t *toJSON) value() error {
+	c, err := t.d.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case c == msgpcode.Nil:
+		if err := t.d.DecodeNil(); err != nil {
+			return err
+		}
+		t.buf = append(t.buf, "null"...)
+		return nil
+	case c == msgpcode.False || c == msgpcode.True:
+		v, err := t.d.DecodeBool()
+		if err != nil {
+			return err
+		}
+		t.buf = strconv.AppendBool(t.buf, v)
+		return nil
+	case msgpcode.IsFixedNum(c), c >= msgpcode.Int8 && c <= msgpcode.Int64:
+		n, err := t.d.DecodeInt64()
+		if err != nil {
+			return err
+		}
+		t.buf = strconv.AppendInt(t.buf, n, 10)
+		return nil
+	case c >= msgpcode.Uint8 && c <= msgpcode.Uint64:
+		n, err := t.d.DecodeUint64()
+		if err != nil {
+			return err
+		}
+		t.buf = strconv.AppendUint(t.buf, n, 10)
+		return nil
+	case c == msgpcode.Float:
+		f, err := t.d.DecodeFloat32()
+		if err != nil {
+			return err
+		}
+		return t.float(float64(f), 32)
+	case c == msgpcode.Double:
+		f, err := t.d.DecodeFloat64()
+		if err != nil {
+			return err
+		}
+		return t.float(f, 64)
+	case msgpcode.IsString(c):
+		return t.string()
+	case msgpcode.IsBin(c):
+		b, err := t.d.DecodeBytes()
+		if err != nil {
+			return err
+		}
+		t.buf = t.appendBin(t.buf, b)
+		return nil
+	case msgpcode.IsExt(c):
+		return t.ext()
+	case msgpcode.IsFixedArray(c), c == msgpcode.Array16, c == msgpcode.Array32:
+		return t.array()
+	case msgpcode.IsFixedMap(c), c == msgpcode.Map16, c == msgpcode.Map32:
+		return t.object()
+	}
+
+	return fmt.Errorf("msgpjson: invalid code=%x", c)
+}
+
+func (t *toJSON) float(f float64, bits int) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		b, err := appendNonFinite(t.buf, f, t.cfg.NonFinite)
+		if err != nil {
+			return err
+		}
+		t.buf = b
+		return nil
+	}
+	t.buf = appendFloat(t.buf, f, bits)
+	return nil
+}
+
+// appendFloat formats f the same way as encoding/json.
+func appendFloat(b []byte, f float64, bits int) []byte {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+func (t *toJSON) string() error {
+	s, err := t.d.DecodeStringTemp()
+	if err != nil {
+		return err
+	}
+	if t.cfg.InternedStrings && len(s) >= msgpack.MinInternedStringLen && len(t.dict) < msgpack.MaxInternedStrings {
+		t.dict = append(t.dict, string([]byte(s)))
+	}
+	t.buf = appendString(t.buf, s)
+	return nil
+}
+
+func (t *toJSON) appendBin(b, data []byte) []byte {
+	b = append(b, '"')
+	switch t.cfg.Bin {
+	case Hex:
+		n := len(b)
+		b = append(b, make([]byte, hex.EncodedLen(len(data)))...)
+		hex.Encode(b[n:], data)
+	default:
+		n := len(b)
+		b = append(b, make([]byte, base64.StdEncoding.EncodedLen(len(data)))...)
+		base64.StdEncoding.Encode(b[n:], data)
+	}
+	return append(b, '"')
+}
+
+func (t *toJSON) ext() error {
+	id, n, err := t.d.DecodeExtHeader()
+	if err != nil {
+		return err
+	}
+	data, err := t.readN(n)
+	if err != nil {
+		return err
+	}
+
+	if id == msgpack.InternedStringExtID && t.cfg.InternedStrings {
+		return t.internedString(data)
+	}
+
+	var js []byte
+	if t.cfg.Ext != nil {
+		js, err = t.cfg.Ext(id, data)
+		if err != nil {
+			return err
+		}
+	}
+	if js == nil {
+		js, err = TimeExt(id, data)
+		if err != nil {
+			return err
+		}
+	}
+	if js != nil {
+		t.buf = append(t.buf, js...)
+		return nil
+	}
+
+	t.buf = append(t.buf, `{"type":`...)
+	t.buf = strconv.AppendInt(t.buf, int64(id), 10)
+	t.buf = append(t.buf, `,"data":`...)
+	t.buf = t.appendBin(t.buf, data)
+	t.buf = append(t.buf, '}')
+	return nil
+}
+
+// readN reads n bytes growing the buffer as the data arrives,
+// so a corrupted length does not allocate up front.
+func (t *toJSON) readN(n int) ([]byte, error) {
+	var b []byte
+	for len(b) < n {
+		chunk := n - len(b)
+		if chunk > maxChunk {
+			chunk = maxChunk
+		}
+		start := len(b)
+		b = append(b, make([]byte, chunk)...)
+		if err := t.d.ReadFull(b[start:]); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (t *toJSON) internedString(data []byte) error {
+	var idx int
+	switch len(data) {
+	case 1:
+		idx = int(data[0])
+	case 2:
+		idx = int(binary.BigEndian.Uint16(data))
+	case 4:
+		idx = int(binary.BigEndian.Uint32(data))
+	default:
+		return fmt.Errorf("msgpjson: unsupported ext len=%d decoding interned string", len(data))
+	}
+	if idx >= len(t.dict) {
+		return fmt.Errorf("msgpjson: interned string at index=%d does not exist", idx)
+	}
+	t.buf = appendString(t.buf, t.dict[idx])
+	return nil
+}
+
+func (t *toJSON) enter() error {
+	t.depth++
+	if t.depth > maxDepth {
+		t.depth--
+		return fmt.Errorf("msgpjson: exceeded max depth of %d", maxDepth)
+	}
+	return nil
+}
+
+func (t *toJSON) leave() {
+	t.depth--
+}
+
+func (t *toJSON) array() error {
+	if err := t.enter(); err != nil {
+		return err
+	}
+	defer t.leave()
+
+	n, err := t.d.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	t.buf = append(t.buf, '[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			t.buf = append(t.buf, ',')
+		}
+		if err := t.value(); err != nil {
+			return err
+		}
+		if err := t.maybeFlush(); err != nil {
+			return err
+		}
+	}
+	t.buf = append(t.buf, ']')
+	return nil
+}
+
+func (t *toJSON) object() error {
+	if err := t.enter(); err != nil {
+		return err
+	}
+	defer t.leave()
+
+	n, err := t.d.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+
+	t.buf = append(t.buf, '{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			t.buf = append(t.buf, ',')
+		}
+		if err := t.key(); err != nil {
+			return err
+		}
+		t.buf = append(t.buf, ':')
+		if err := t.value(); err != nil {
+			return err
+		}
+		if err := t.maybeFlush(); err != nil {
+			return err
+		}
+	}
+	t.buf = append(t.buf, '}')
+	return nil
+}
+
+// key renders a map key. Keys that are not rendered as JSON strings,
+// e.g. numbers or arrays, are quoted.
+func (t *toJSON) key() error {
+	start := len(t.buf)
+
+	t.keys++
+	err := t.value()
+	t.keys--
+	if err != nil {
+		return err
+	}
+
+	if t.buf[start] != '"' {
+		s := string(t.buf[start:])
+		t.buf = appendString(t.buf[:start], s)
+	}
+	return nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendString appends s as a JSON string. Invalid UTF-8 is replaced
+// with U+FFFD like encoding/json does.
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are valid JSON, but break JavaScript.
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
@@ -0,0 +1,160 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type noCopyTest struct {
+	Data []byte
+	Raw  msgpack.RawMessage
+	Name string
+}
+
+func TestUnmarshalNoCopy(t *testing.T) {
+	raw, err := msgpack.Marshal("raw")
+	require.Nil(t, err)
+
+	b, err := msgpack.Marshal(&noCopyTest{
+		Data: []byte("data"),
+		Raw:  raw,
+		Name: "name",
+	})
+	require.Nil(t, err)
+
+	var out noCopyTest
+	require.Nil(t, msgpack.UnmarshalNoCopy(b, &out))
+	require.Equal(t, "data", string(out.Data))
+	require.Equal(t, raw, []byte(out.Raw))
+	require.Equal(t, "name", out.Name)
+
+	// Appending must not overwrite the input.
+	_ = append(out.Data, 'x')
+	require.Equal(t, []byte("data"), out.Data)
+
+	for i := range b {
+		b[i] = 0
+	}
+	require.Equal(t, make([]byte, 4), out.Data)
+	require.Equal(t, make([]byte, len(raw)), []byte(out.Raw))
+	require.Equal(t, "name", out.Name)
+}
+
+func TestDecoderResetBytes(t *testing.T) {
+	b, err := msgpack.Marshal(&noCopyTest{Data: []byte("data"), Name: "name"})
+	require.Nil(t, err)
+
+	dec := msgpack.NewDecoder(nil)
+	dec.UseNoCopyStrings(true)
+
+	var out noCopyTest
+	dec.ResetBytes(b, false)
+	require.Nil(t, dec.Decode(&out))
+
+	dec.ResetBytes(b, true)
+	var out2 noCopyTest
+	require.Nil(t, dec.Decode(&out2))
+
+	copy(b, bytes.Repeat([]byte{'x'}, len(b)))
+	require.Equal(t, "data", string(out.Data))
+	require.Equal(t, "name", out.Name)
+	require.Equal(t, "xxxx", string(out2.Data))
+	require.Equal(t, "xxxx", out2.Name)
+}
+
+func TestDecoderResetBytesStream(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	require.Nil(t, enc.EncodeMulti("hello", 42, []int{1, 2}))
+
+	dec := msgpack.NewDecoder(nil)
+	dec.ResetBytes(buf.Bytes(), true)
+
+	var s string
+	var n int
+	var ints []int
+	require.Nil(t, dec.DecodeMulti(&s, &n))
+	c, err := dec.PeekCode()
+	require.Nil(t, err)
+	require.Equal(t, byte(0x92), c)
+	require.Nil(t, dec.Decode(&ints))
+	require.Equal(t, "hello", s)
+	require.Equal(t, 42, n)
+	require.Equal(t, []int{1, 2}, ints)
+
+	_, err = dec.PeekCode()
+	require.NotNil(t, err)
+}
+
+func TestDecoderInternedStringsCopy(t *testing.T) {
+	b, err := msgpack.Marshal("hello")
+	require.Nil(t, err)
+
+	dec := msgpack.NewDecoder(nil)
+	dec.UseInternedStrings(true)
+	dec.ResetBytes(b, true)
+
+	s, err := dec.DecodeString()
+	require.Nil(t, err)
+
+	copy(b, bytes.Repeat([]byte{'x'}, len(b)))
+	require.Equal(t, "hello", s)
+}
+
+// keptBytes keeps the bytes passed to UnmarshalMsgpack.
+type keptBytes struct {
+	b []byte
+}
+
+func (k *keptBytes) MarshalMsgpack() ([]byte, error) { return msgpack.Marshal("kept") }
+
+func (k *keptBytes) UnmarshalMsgpack(b []byte) error {
+	k.b = b
+	return nil
+}
+
+func TestUnmarshalerCopy(t *testing.T) {
+	r := msgpack.NewRegistry()
+	r.RegisterExt(1, (*keptBytes)(nil))
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetRegistry(r)
+	require.Nil(t, enc.Encode(&keptBytes{}))
+	ext := buf.Bytes()
+
+	plain, err := msgpack.Marshal(&keptBytes{})
+	require.Nil(t, err)
+
+	tests := []struct {
+		reg *msgpack.Registry
+		b   []byte
+	}{
+		{r, ext},
+		{msgpack.DefaultRegistry(), plain},
+	}
+	for _, test := range tests {
+		b := test.b
+		want := append([]byte(nil), b...)
+
+		var out keptBytes
+		dec := msgpack.NewDecoder(nil)
+		dec.SetRegistry(test.reg)
+		dec.ResetBytes(b, false)
+		require.Nil(t, dec.Decode(&out))
+		require.NotEmpty(t, out.b)
+
+		// Changing the kept bytes must not change the input and vice versa.
+		for i := range out.b {
+			out.b[i] = 0
+		}
+		require.Equal(t, want, b)
+		for i := range b {
+			b[i] = 0xff
+		}
+		require.Equal(t, make([]byte, len(out.b)), out.b)
+	}
+}
@@ -0,0 +1,155 @@
+package msgpack
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds encoder and decoder functions registered for types,
+// the types registered for ext ids and the type names.
+//
+// Package-level functions such as Register and RegisterExt use the default
+// registry, which is also used by encoders and decoders unless another one is
+// set with Encoder.SetRegistry and Decoder.SetRegistry. A separate registry
+// lets independent packages in one program use the same ext id for different
+// types. Registry is safe for concurrent use.
+type Registry struct {
+	mu sync.RWMutex
+
+	// encMap and decMap hold registered functions by reflect.Type and
+	// registered types by ext id.
+	encMap   sync.Map
+	decMap   sync.Map
+	extTypes map[int8]*extInfo
+
+	// nameTypes and typeNames hold the types registered with RegisterName.
+	nameTypes map[string]reflect.Type
+	typeNames map[reflect.Type]string
+
+	// cache holds the functions resolved for types, including the registered
+	// ones. It is replaced on every registration.
+	cache atomic.Pointer[registryCache]
+}
+
+// registryCache holds the encoder and decoder functions and the struct fields
+// resolved with one state of the registry. Registrations replace the cache
+// instead of clearing it, so functions resolved concurrently with a
+// registration are stored in the stale cache that is no longer used.
+type registryCache struct {
+	enc     sync.Map
+	dec     sync.Map
+	structs *structCache
+}
+
+var defaultRegistry = NewRegistry()
+
+// DefaultRegistry returns the registry used by the package-level functions.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// NewRegistry returns a new registry with built-in types only: time.Time and
+// interned strings. Use DefaultRegistry().Clone() to keep the types registered
+// with the package-level functions.
+func NewRegistry() *Registry {
+	r := newRegistry()
+	registerTime(r)
+	registerInternedStrings(r)
+	return r
+}
+
+func newRegistry() *Registry {
+	r := &Registry{
+		extTypes:  make(map[int8]*extInfo),
+		nameTypes: make(map[string]reflect.Type),
+		typeNames: make(map[reflect.Type]string),
+	}
+	r.resetCache()
+	return r
+}
+
+// Clone returns a copy of the registry. Types registered with the copy
+// do not affect r and vice versa.
+func (r *Registry) Clone() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c := newRegistry()
+	r.encMap.Range(func(key, value interface{}) bool {
+		c.encMap.Store(key, value)
+		return true
+	})
+	r.decMap.Range(func(key, value interface{}) bool {
+		c.decMap.Store(key, value)
+		return true
+	})
+	for extID, info := range r.extTypes {
+		c.extTypes[extID] = info
+	}
+	for name, typ := range r.nameTypes {
+		c.nameTypes[name] = typ
+		c.typeNames[typ] = name
+	}
+	return c
+}
+
+// Register registers encoder and decoder functions for a value.
+// This is low level API and in most cases you should prefer implementing
+// CustomEncoder/CustomDecoder or Marshaler/Unmarshaler interfaces.
+func (r *Registry) Register(value interface{}, enc encoderFunc, dec decoderFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	typ := reflect.TypeOf(value)
+	if enc != nil {
+		r.encMap.Store(typ, enc)
+	}
+	if dec != nil {
+		r.decMap.Store(typ, dec)
+	}
+	r.resetCache()
+}
+
+func (r *Registry) resetCache() {
+	r.cache.Store(&registryCache{structs: newStructCache(r)})
+}
+
+func (r *Registry) structFields(typ reflect.Type, tag string) *fields {
+	return r.cache.Load().structs.Fields(typ, tag)
+}
+
+func (r *Registry) extInfo(extID int8) (*extInfo, bool) {
+	r.mu.RLock()
+	info, ok := r.extTypes[extID]
+	r.mu.RUnlock()
+	return info, ok
+}
+
+//------------------------------------------------------------------------------
+
+// SetRegistry causes the Encoder to use types registered with r instead of
+// the default registry.
+func (e *Encoder) SetRegistry(r *Registry) {
+	e.reg = r
+}
+
+func (e *Encoder) registry() *Registry {
+	if e.reg != nil {
+		return e.reg
+	}
+	return defaultRegistry
+}
+
+// SetRegistry causes the Decoder to use types registered with r instead of
+// the default registry.
+func (d *Decoder) SetRegistry(r *Registry) {
+	d.reg = r
+}
+
+func (d *Decoder) registry() *Registry {
+	if d.reg != nil {
+		return d.reg
+	}
+	return defaultRegistry
+}
@@ -0,0 +1,165 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type registryExtA struct{ S string }
+
+func (ext *registryExtA) MarshalMsgpack() ([]byte, error) { return []byte("a" + ext.S), nil }
+
+func (ext *registryExtA) UnmarshalMsgpack(b []byte) error {
+	ext.S = string(b[1:])
+	return nil
+}
+
+type registryExtB struct{ S string }
+
+func (ext *registryExtB) MarshalMsgpack() ([]byte, error) { return []byte("b" + ext.S), nil }
+
+func (ext *registryExtB) UnmarshalMsgpack(b []byte) error {
+	ext.S = string(b[1:])
+	return nil
+}
+
+func TestRegistryExt(t *testing.T) {
+	ra := msgpack.NewRegistry()
+	ra.RegisterExt(5, (*registryExtA)(nil))
+	rb := msgpack.DefaultRegistry().Clone()
+	rb.RegisterExt(5, (*registryExtB)(nil))
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetRegistry(ra)
+	require.Nil(t, enc.Encode(&registryExtA{S: "x"}))
+	enc.SetRegistry(rb)
+	require.Nil(t, enc.Encode(&registryExtB{S: "y"}))
+
+	dec := msgpack.NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.SetRegistry(ra)
+	v, err := dec.DecodeInterface()
+	require.Nil(t, err)
+	require.Equal(t, &registryExtA{S: "x"}, v)
+	dec.SetRegistry(rb)
+	v, err = dec.DecodeInterface()
+	require.Nil(t, err)
+	require.Equal(t, &registryExtB{S: "y"}, v)
+
+	// The default registry is not affected.
+	_, err = msgpack.NewDecoder(bytes.NewReader(buf.Bytes())).DecodeInterface()
+	require.EqualError(t, err, "msgpack: unknown ext id=5")
+
+	// Reset switches back to the default registry.
+	dec.Reset(bytes.NewReader(buf.Bytes()))
+	_, err = dec.DecodeInterface()
+	require.EqualError(t, err, "msgpack: unknown ext id=5")
+
+	// Built-in types are registered with every registry.
+	tm := time.Unix(1700000000, 0)
+	b, err := msgpack.Marshal(tm)
+	require.Nil(t, err)
+	dec.Reset(bytes.NewReader(b))
+	dec.SetRegistry(ra)
+	v, err = dec.DecodeInterface()
+	require.Nil(t, err)
+	require.True(t, tm.Equal(v.(time.Time)))
+}
+
+type registryCelsius float64
+
+type registryWeather struct {
+	Temp registryCelsius
+}
+
+func TestRegistryClone(t *testing.T) {
+	in := registryWeather{Temp: 21.5}
+
+	// Warm up the default registry caches.
+	b, err := msgpack.Marshal(in)
+	require.Nil(t, err)
+
+	r := msgpack.DefaultRegistry().Clone()
+	r.Register(registryCelsius(0),
+		func(e *msgpack.Encoder, v reflect.Value) error {
+			return e.EncodeString("warm")
+		},
+		func(d *msgpack.Decoder, v reflect.Value) error {
+			s, err := d.DecodeString()
+			if err != nil {
+				return err
+			}
+			if s == "warm" {
+				v.SetFloat(20)
+			}
+			return nil
+		})
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetRegistry(r)
+	require.Nil(t, enc.Encode(in))
+
+	var m map[string]interface{}
+	require.Nil(t, msgpack.Unmarshal(buf.Bytes(), &m))
+	require.Equal(t, map[string]interface{}{"Temp": "warm"}, m)
+
+	var out registryWeather
+	dec := msgpack.NewDecoder(&buf)
+	dec.SetRegistry(r)
+	require.Nil(t, dec.Decode(&out))
+	require.Equal(t, registryWeather{Temp: 20}, out)
+
+	// The default registry keeps encoding the float.
+	b2, err := msgpack.Marshal(in)
+	require.Nil(t, err)
+	require.Equal(t, b, b2)
+}
+
+func TestRegistryRegisterConcurrent(t *testing.T) {
+	encode := func(r *msgpack.Registry) ([]byte, error) {
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetRegistry(r)
+		err := enc.Encode(registryWeather{Temp: 1})
+		return buf.Bytes(), err
+	}
+
+	for i := 0; i < 20; i++ {
+		r := msgpack.NewRegistry()
+
+		var wg sync.WaitGroup
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := 0; k < 100; k++ {
+					if _, err := encode(r); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}()
+		}
+
+		r.Register(registryCelsius(0),
+			func(e *msgpack.Encoder, v reflect.Value) error {
+				return e.EncodeString("warm")
+			}, nil)
+		wg.Wait()
+
+		// Encoders resolved while registering must not be used after it.
+		b, err := encode(r)
+		require.Nil(t, err)
+		var m map[string]interface{}
+		require.Nil(t, msgpack.Unmarshal(b, &m))
+		require.Equal(t, map[string]interface{}{"Temp": "warm"}, m)
+	}
+}
@@ -3,17 +3,105 @@ package msgpack
 import (
 	"encoding/binary"
 	"fmt"
+	"math"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/vmihailenco/msgpack/v5/msgpcode"
 )
 
-var timeExtID int8 = -1
+const (
+	// TimeExtID is the ext type of the MessagePack timestamp extension.
+	TimeExtID int8 = -1
+	// TimeZoneExtID is the ext type of times encoded with TimeFormatExtZone.
+	// Negative ext types are reserved by the MessagePack spec, so it is
+	// an application type: programs that use TimeFormatExtZone must not
+	// register the type for their own values.
+	TimeZoneExtID int8 = 127
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// TimeFormat specifies how time.Time values are encoded.
+//
+// Only TimeFormatExt, TimeFormatExtZone and TimeFormatRFC3339 keep nanoseconds
+// for all times. TimeFormatRFC3339 keeps the UTC offset and TimeFormatExtZone
+// keeps the location: times encoded with other formats are decoded in the local
+// time zone. Call time.Time.In to restore the location after decoding or use
+// TimeFormatExtZone when the zone matters.
+type TimeFormat int
+
+const (
+	// TimeFormatExt encodes times with the MessagePack timestamp extension
+	// type -1. It is the default.
+	TimeFormatExt TimeFormat = iota
+	// TimeFormatUnix encodes times as integer seconds since the Unix epoch.
+	// Fractional seconds are truncated.
+	TimeFormatUnix
+	// TimeFormatUnixMilli encodes times as integer milliseconds since the Unix epoch.
+	TimeFormatUnixMilli
+	// TimeFormatUnixMicro encodes times as integer microseconds since the Unix epoch.
+	TimeFormatUnixMicro
+	// TimeFormatUnixNano encodes times as integer nanoseconds since the Unix epoch.
+	// Times before 1678 or after 2262, including the zero time, can't be encoded.
+	TimeFormatUnixNano
+	// TimeFormatUnixFloat encodes times as float64 seconds since the Unix epoch.
+	// Precision is limited to about a microsecond for current dates.
+	TimeFormatUnixFloat
+	// TimeFormatRFC3339 encodes times as time.RFC3339Nano strings.
+	TimeFormatRFC3339
+	// TimeFormatExtZone encodes times with the ext type TimeZoneExtID that holds
+	// the timestamp and the location, so DecodeTime returns a time in
+	// the original location. IANA locations, e.g. America/New_York, are
+	// loaded with time.LoadLocation on decoding and fall back to the UTC
+	// offset of the time if the location is unknown or more than 1024
+	// unknown names were seen. time.Local is encoded as its UTC offset.
+	// Only this package can decode the ext type.
+	TimeFormatExtZone
+)
 
-func init() {
-	RegisterExtEncoder(timeExtID, time.Time{}, timeEncoder)
-	RegisterExtDecoder(timeExtID, time.Time{}, timeDecoder)
+var timeFormatNames = [...]string{
+	TimeFormatExt:       "ext",
+	TimeFormatUnix:      "unix",
+	TimeFormatUnixMilli: "unixms",
+	TimeFormatUnixMicro: "unixus",
+	TimeFormatUnixNano:  "unixns",
+	TimeFormatUnixFloat: "unixfloat",
+	TimeFormatRFC3339:   "rfc3339",
+	TimeFormatExtZone:   "extzone",
+}
+
+// String returns the name of the format used by the time= struct tag option.
+func (f TimeFormat) String() string {
+	if f >= 0 && int(f) < len(timeFormatNames) {
+		return timeFormatNames[f]
+	}
+	return fmt.Sprintf("TimeFormat(%d)", int(f))
+}
+
+// ParseTimeFormat returns the format with the name used by the time= struct
+// tag option: ext, unix, unixms, unixus, unixns, unixfloat, rfc3339 or extzone.
+func ParseTimeFormat(s string) (TimeFormat, error) {
+	for f, name := range timeFormatNames {
+		if name == s {
+			return TimeFormat(f), nil
+		}
+	}
+	return 0, fmt.Errorf("msgpack: unknown time format %q", s)
+}
+
+func registerTime(r *Registry) {
+	r.RegisterExtEncoder(TimeExtID, time.Time{}, timeEncoder)
+	r.RegisterExtDecoder(TimeExtID, time.Time{}, timeDecoder)
+	r.RegisterExtDecoder(TimeZoneExtID, time.Time{}, timeZoneDecoder)
+
+	// The ext encoder and decoder are still used for the ext id, but
+	// time.Time values respect the encoder and decoder time format.
+	r.Register(time.Time{}, encodeTimeValue, decodeTimeValue)
 }
 
 func timeEncoder(e *Encoder, v reflect.Value) ([]byte, error) {
@@ -37,17 +125,149 @@ func timeDecoder(d *Decoder, v reflect.Value, extLen int) error {
 	return nil
 }
 
+func timeZoneDecoder(d *Decoder, v reflect.Value, extLen int) error {
+	tm, err := d.decodeTimeZone(extLen)
+	if err != nil {
+		return err
+	}
+	ptr := v.Addr().Interface().(*time.Time)
+	*ptr = tm
+	return nil
+}
+
+func encodeTimeValue(e *Encoder, v reflect.Value) error {
+	return e.EncodeTime(v.Interface().(time.Time))
+}
+
+func decodeTimeValue(d *Decoder, v reflect.Value) error {
+	tm, err := d.DecodeTime()
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(tm))
+	return nil
+}
+
+func timeFormatEncoder(format TimeFormat) encoderFunc {
+	return func(e *Encoder, v reflect.Value) error {
+		return e.EncodeTimeFormat(v.Interface().(time.Time), format)
+	}
+}
+
+func timeFormatDecoder(format TimeFormat) decoderFunc {
+	return func(d *Decoder, v reflect.Value) error {
+		tm, err := d.DecodeTimeFormat(format)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(tm))
+		return nil
+	}
+}
+
+// SetTimeFormat sets the format used to encode time.Time values.
+// The time= struct tag option, e.g. `msgpack:"ts,time=unixms"`,
+// overrides it for a field.
+func (e *Encoder) SetTimeFormat(format TimeFormat) {
+	e.timeFormat = format
+}
+
+// EncodeTime encodes tm using the format set with SetTimeFormat.
 func (e *Encoder) EncodeTime(tm time.Time) error {
+	return e.EncodeTimeFormat(tm, e.timeFormat)
+}
+
+// EncodeTimeFormat encodes tm using the format.
+func (e *Encoder) EncodeTimeFormat(tm time.Time, format TimeFormat) error {
+	switch format {
+	case TimeFormatExt:
+		return e.encodeTimeExt(tm)
+	case TimeFormatUnix:
+		return e.EncodeInt(tm.Unix())
+	case TimeFormatUnixMilli:
+		return e.EncodeInt(tm.UnixMilli())
+	case TimeFormatUnixMicro:
+		return e.EncodeInt(tm.UnixMicro())
+	case TimeFormatUnixNano:
+		if sec := tm.Unix(); sec < math.MinInt64/int64(time.Second) || sec >= math.MaxInt64/int64(time.Second) {
+			return fmt.Errorf("msgpack: time %s is out of range for %s", tm, format)
+		}
+		return e.EncodeInt(tm.UnixNano())
+	case TimeFormatUnixFloat:
+		return e.EncodeFloat64(float64(tm.Unix()) + float64(tm.Nanosecond())/1e9)
+	case TimeFormatRFC3339:
+		return e.EncodeString(tm.Format(time.RFC3339Nano))
+	case TimeFormatExtZone:
+		return e.encodeTimeZoneExt(tm)
+	default:
+		return fmt.Errorf("msgpack: unknown time format %s", format)
+	}
+}
+
+func (e *Encoder) encodeTimeExt(tm time.Time) error {
 	b := e.encodeTime(tm)
-	if err := e.encodeExtLen(len(b)); err != nil {
+	if err := e.EncodeExtHeader(TimeExtID, len(b)); err != nil {
 		return err
 	}
-	if err := e.w.WriteByte(byte(timeExtID)); err != nil {
+	return e.write(b)
+}
+
+// The body of the time zone ext is the zone kind, for fixed and named zones
+// followed by the int32 UTC offset in seconds and the name prefixed with its
+// uint8 length, and the timestamp in the format of the timestamp ext.
+const (
+	timeZoneUTC byte = iota
+	timeZoneFixed
+	timeZoneNamed
+)
+
+func (e *Encoder) encodeTimeZoneExt(tm time.Time) error {
+	kind, name, offset := timeZone(tm)
+	if len(name) > math.MaxUint8 {
+		return fmt.Errorf("msgpack: time zone name %q is too long", name)
+	}
+
+	n := 1
+	if kind != timeZoneUTC {
+		n += 5 + len(name)
+	}
+	b := e.encodeTime(tm)
+	if err := e.EncodeExtHeader(TimeZoneExtID, n+len(b)); err != nil {
+		return err
+	}
+	if err := e.writeCode(kind); err != nil {
 		return err
 	}
+	if kind != timeZoneUTC {
+		buf := e.buf[:5]
+		binary.BigEndian.PutUint32(buf, uint32(int32(offset)))
+		buf[4] = byte(len(name))
+		if err := e.write(buf); err != nil {
+			return err
+		}
+		if err := e.writeString(name); err != nil {
+			return err
+		}
+	}
 	return e.write(b)
 }
 
+// timeZone returns how the location of tm is encoded. Locations created with
+// time.FixedZone have the same name as their only zone and are encoded with
+// the offset; time.Local is encoded with the offset too, because its name
+// is "Local" on every machine.
+func timeZone(tm time.Time) (kind byte, name string, offset int) {
+	loc := tm.Location()
+	if loc == time.UTC {
+		return timeZoneUTC, "", 0
+	}
+	zone, offset := tm.Zone()
+	if loc == time.Local || loc.String() == zone {
+		return timeZoneFixed, zone, offset
+	}
+	return timeZoneNamed, loc.String(), offset
+}
+
 func (e *Encoder) encodeTime(tm time.Time) []byte {
 	if e.timeBuf == nil {
 		e.timeBuf = make([]byte, 12)
@@ -74,12 +294,48 @@ func (e *Encoder) encodeTime(tm time.Time) []byte {
 	return b
 }
 
+// SetTimeFormat sets the unit of integer timestamps decoded into time.Time:
+// milliseconds for TimeFormatUnixMilli, microseconds for TimeFormatUnixMicro,
+// nanoseconds for TimeFormatUnixNano and seconds for other formats.
+// The time= struct tag option overrides it for a field.
+func (d *Decoder) SetTimeFormat(format TimeFormat) {
+	d.timeFormat = format
+}
+
+// DecodeTime decodes a time encoded with any of the time formats:
+// the timestamp extension, integer or float Unix timestamps and RFC 3339
+// strings. Integers are interpreted in the unit set with SetTimeFormat.
+// The legacy [sec, nsec] array encoding is also supported and nil is decoded
+// as the zero time.
 func (d *Decoder) DecodeTime() (time.Time, error) {
+	return d.DecodeTimeFormat(d.timeFormat)
+}
+
+// DecodeTimeFormat is like DecodeTime, but integers are interpreted
+// in the unit of the format.
+func (d *Decoder) DecodeTimeFormat(format TimeFormat) (time.Time, error) {
+	tm, err := d.decodeTimeFormat(format)
+	if err != nil {
+		return tm, err
+	}
+
+	if tm.IsZero() && tm.Location() == time.Local {
+		// Zero time does not have timezone information.
+		return tm.UTC(), nil
+	}
+	return tm, nil
+}
+
+func (d *Decoder) decodeTimeFormat(format TimeFormat) (time.Time, error) {
 	c, err := d.readCode()
 	if err != nil {
 		return time.Time{}, err
 	}
 
+	if c == msgpcode.Nil {
+		return time.Time{}, nil
+	}
+
 	// Legacy format.
 	if c == msgpcode.FixedArrayLow|2 {
 		sec, err := d.DecodeInt64()
@@ -103,26 +359,64 @@ func (d *Decoder) DecodeTime() (time.Time, error) {
 		return time.Parse(time.RFC3339Nano, s)
 	}
 
+	switch {
+	case msgpcode.IsFixedNum(c),
+		c == msgpcode.Int8, c == msgpcode.Int16, c == msgpcode.Int32, c == msgpcode.Int64,
+		c == msgpcode.Uint8, c == msgpcode.Uint16, c == msgpcode.Uint32:
+		n, err := d.int(c)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return unixTime(n, format), nil
+	case c == msgpcode.Uint64:
+		n, err := d.uint(c)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if n > math.MaxInt64 {
+			return time.Time{}, fmt.Errorf("msgpack: timestamp %d overflows int64", n)
+		}
+		return unixTime(int64(n), format), nil
+	case c == msgpcode.Float, c == msgpcode.Double:
+		f, err := d.float64(c)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return time.Time{}, fmt.Errorf("msgpack: invalid timestamp %v", f)
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))), nil
+	}
+
 	extID, extLen, err := d.extHeader(c)
 	if err != nil {
 		return time.Time{}, err
 	}
 
+	if extID == TimeZoneExtID {
+		return d.decodeTimeZone(extLen)
+	}
+
 	// NodeJS seems to use extID 13.
-	if extID != timeExtID && extID != 13 {
+	if extID != TimeExtID && extID != 13 {
 		return time.Time{}, fmt.Errorf("msgpack: invalid time ext id=%d", extID)
 	}
 
-	tm, err := d.decodeTime(extLen)
-	if err != nil {
-		return tm, err
-	}
+	return d.decodeTime(extLen)
+}
 
-	if tm.IsZero() {
-		// Zero time does not have timezone information.
-		return tm.UTC(), nil
+func unixTime(n int64, format TimeFormat) time.Time {
+	switch format {
+	case TimeFormatUnixMilli:
+		return time.UnixMilli(n)
+	case TimeFormatUnixMicro:
+		return time.UnixMicro(n)
+	case TimeFormatUnixNano:
+		return time.Unix(0, n)
+	default:
+		return time.Unix(n, 0)
 	}
-	return tm, nil
 }
 
 func (d *Decoder) decodeTime(extLen int) (time.Time, error) {
@@ -130,7 +424,31 @@ func (d *Decoder) decodeTime(extLen int) (time.Time, error) {
 	if err != nil {
 		return time.Time{}, err
 	}
+	return parseTimeExt(b)
+}
+
+func (d *Decoder) decodeTimeZone(extLen int) (time.Time, error) {
+	b, err := d.readN(extLen)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseTimeZoneExt(b)
+}
+
+// DecodeTimeExt decodes the data of an ext value with the type TimeExtID
+// or TimeZoneExtID, e.g. read after Decoder.DecodeExtHeader.
+func DecodeTimeExt(extID int8, data []byte) (time.Time, error) {
+	switch extID {
+	case TimeExtID:
+		return parseTimeExt(data)
+	case TimeZoneExtID:
+		return parseTimeZoneExt(data)
+	default:
+		return time.Time{}, fmt.Errorf("msgpack: invalid time ext id=%d", extID)
+	}
+}
 
+func parseTimeExt(b []byte) (time.Time, error) {
 	switch len(b) {
 	case 4:
 		sec := binary.BigEndian.Uint32(b)
@@ -145,7 +463,91 @@ func (d *Decoder) decodeTime(extLen int) (time.Time, error) {
 		sec := binary.BigEndian.Uint64(b[4:])
 		return time.Unix(int64(sec), int64(nsec)), nil
 	default:
-		err = fmt.Errorf("msgpack: invalid ext len=%d decoding time", extLen)
+		return time.Time{}, fmt.Errorf("msgpack: invalid ext len=%d decoding time", len(b))
+	}
+}
+
+func parseTimeZoneExt(b []byte) (time.Time, error) {
+	if len(b) < 1 {
+		return time.Time{}, fmt.Errorf("msgpack: invalid ext len=%d decoding time zone", len(b))
+	}
+	kind := b[0]
+	b = b[1:]
+
+	loc := time.UTC
+	switch kind {
+	case timeZoneUTC:
+	case timeZoneFixed, timeZoneNamed:
+		if len(b) < 5 || len(b) < 5+int(b[4]) {
+			return time.Time{}, fmt.Errorf("msgpack: invalid ext len=%d decoding time zone", len(b))
+		}
+		offset := int(int32(binary.BigEndian.Uint32(b)))
+		n := int(b[4])
+		loc = timeLocation(kind, string(b[5:5+n]), offset)
+		b = b[5+n:]
+	default:
+		return time.Time{}, fmt.Errorf("msgpack: invalid time zone kind=%d", kind)
+	}
+
+	tm, err := parseTimeExt(b)
+	if err != nil {
 		return time.Time{}, err
 	}
+	return tm.In(loc), nil
+}
+
+// maxTimeLocationErrors bounds the number of cached zone names that failed to
+// load. Once it is reached, names that are not cached are no longer loaded and
+// decoded times get a fixed zone with the encoded offset, so unknown names in
+// the input cost at most one lookup each.
+const maxTimeLocationErrors = 1024
+
+// timeLocations caches the locations loaded by zone name. A nil location
+// records a name that time.LoadLocation failed to load.
+var timeLocations struct {
+	sync.RWMutex
+	m      map[string]*time.Location
+	errors int
+}
+
+func timeLocation(kind byte, name string, offset int) *time.Location {
+	if kind == timeZoneNamed {
+		if loc := loadTimeLocation(name); loc != nil {
+			return loc
+		}
+	}
+	return time.FixedZone(name, offset)
+}
+
+func loadTimeLocation(name string) *time.Location {
+	timeLocations.RLock()
+	loc, ok := timeLocations.m[name]
+	full := timeLocations.errors >= maxTimeLocationErrors
+	timeLocations.RUnlock()
+	if ok || full {
+		return loc
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = nil
+	}
+
+	timeLocations.Lock()
+	defer timeLocations.Unlock()
+
+	if _, ok := timeLocations.m[name]; ok {
+		return loc
+	}
+	if loc == nil {
+		if timeLocations.errors >= maxTimeLocationErrors {
+			return nil
+		}
+		timeLocations.errors++
+	}
+	if timeLocations.m == nil {
+		timeLocations.m = make(map[string]*time.Location)
+	}
+	timeLocations.m[name] = loc
+	return loc
 }
@@ -0,0 +1,239 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func marshalTime(t *testing.T, v interface{}, format msgpack.TimeFormat) []byte {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetTimeFormat(format)
+	require.Nil(t, enc.Encode(v))
+	return buf.Bytes()
+}
+
+func TestTimeFormats(t *testing.T) {
+	tm := time.Date(2023, 11, 14, 22, 13, 20, 123456789, time.FixedZone("", 3600))
+
+	tests := []struct {
+		format msgpack.TimeFormat
+		wanted interface{}
+		parsed time.Time
+	}{
+		{msgpack.TimeFormatExt, tm.Local(), tm},
+		{msgpack.TimeFormatUnix, uint32(1699996400), tm.Truncate(time.Second)},
+		{msgpack.TimeFormatUnixMilli, uint64(1699996400123), tm.Truncate(time.Millisecond)},
+		{msgpack.TimeFormatUnixMicro, uint64(1699996400123456), tm.Truncate(time.Microsecond)},
+		{msgpack.TimeFormatUnixNano, uint64(1699996400123456789), tm},
+		{msgpack.TimeFormatUnixFloat, 1699996400.1234567, tm.Round(time.Microsecond)},
+		{msgpack.TimeFormatRFC3339, "2023-11-14T22:13:20.123456789+01:00", tm},
+	}
+	for _, test := range tests {
+		b := marshalTime(t, tm, test.format)
+
+		dec := msgpack.NewDecoder(bytes.NewReader(b))
+		v, err := dec.DecodeInterface()
+		require.Nil(t, err)
+		require.Equal(t, test.wanted, v, test.format.String())
+
+		// Struct fields and interfaces respect the format too.
+		require.Equal(t, b, marshalTime(t, []interface{}{tm}, test.format)[1:])
+
+		dec = msgpack.NewDecoder(bytes.NewReader(b))
+		dec.SetTimeFormat(test.format)
+		got, err := dec.DecodeTime()
+		require.Nil(t, err)
+		if test.format == msgpack.TimeFormatUnixFloat {
+			require.InDelta(t, test.parsed.UnixNano(), got.UnixNano(), 1000)
+		} else {
+			require.True(t, test.parsed.Equal(got), "%s: got %s", test.format, got)
+		}
+
+		if test.format == msgpack.TimeFormatRFC3339 {
+			_, offset := got.Zone()
+			require.Equal(t, 3600, offset)
+		}
+	}
+}
+
+func TestTimeFormatUnixNanoRange(t *testing.T) {
+	enc := msgpack.NewEncoder(new(bytes.Buffer))
+	enc.SetTimeFormat(msgpack.TimeFormatUnixNano)
+	err := enc.Encode(time.Time{})
+	require.EqualError(t, err,
+		"msgpack: time 0001-01-01 00:00:00 +0000 UTC is out of range for unixns")
+}
+
+type timeFormatTag struct {
+	Ext   time.Time
+	Milli time.Time  `msgpack:"ms,time=unixms"`
+	Float time.Time  `msgpack:",time:unixfloat"`
+	RFC   time.Time  `msgpack:"rfc,time=rfc3339,omitempty"`
+	Ptr   *time.Time `msgpack:",omitempty"`
+}
+
+func TestTimeFormatTag(t *testing.T) {
+	tm := time.Unix(1700000000, 5e6)
+	in := timeFormatTag{Ext: tm, Milli: tm, Float: tm, RFC: tm.UTC()}
+
+	b := marshalTime(t, in, msgpack.TimeFormatUnix)
+	var m map[string]interface{}
+	require.Nil(t, msgpack.Unmarshal(b, &m))
+	require.Equal(t, map[string]interface{}{
+		"Ext":   uint32(1700000000),
+		"ms":    uint64(1700000000005),
+		"Float": 1700000000.005,
+		"rfc":   "2023-11-14T22:13:20.005Z",
+	}, m)
+
+	var out timeFormatTag
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.True(t, out.Ext.Equal(tm.Truncate(time.Second)))
+	require.True(t, out.Milli.Equal(tm))
+	require.InDelta(t, tm.UnixNano(), out.Float.UnixNano(), 1000)
+	require.Equal(t, tm.UTC(), out.RFC)
+}
+
+func TestDecodeTimeForms(t *testing.T) {
+	tm := time.Unix(1700000000, 0)
+
+	values := []interface{}{
+		tm,
+		int64(1700000000),
+		uint64(1700000000),
+		float32(1700000000),
+		"2023-11-14T22:13:20Z",
+		[]int64{1700000000, 0},
+	}
+	for _, v := range values {
+		b, err := msgpack.Marshal(map[string]interface{}{"Ext": v})
+		require.Nil(t, err)
+
+		var out timeFormatTag
+		require.Nil(t, msgpack.Unmarshal(b, &out), "%#v", v)
+		require.True(t, tm.Equal(out.Ext), "%#v: got %s", v, out.Ext)
+	}
+
+	b, err := msgpack.Marshal(map[string]interface{}{"ms": int64(1700000000000), "Ext": nil})
+	require.Nil(t, err)
+	var out timeFormatTag
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.True(t, tm.Equal(out.Milli))
+	require.Equal(t, time.Time{}, out.Ext)
+
+	err = msgpack.Unmarshal([]byte{0xa1, 'x'}, new(time.Time))
+	require.NotNil(t, err)
+}
+
+func TestTimeFormatTagInvalid(t *testing.T) {
+	type invalidFormat struct {
+		T time.Time `msgpack:",time=unixps"`
+	}
+	require.PanicsWithError(t, `msgpack: unknown time format "unixps"`, func() {
+		_, _ = msgpack.Marshal(invalidFormat{})
+	})
+
+	type invalidType struct {
+		T int64 `msgpack:",time=unix"`
+	}
+	require.PanicsWithError(t, "msgpack: time format is not supported on int64", func() {
+		_, _ = msgpack.Marshal(invalidType{})
+	})
+}
+
+func TestTimeFormatExtZone(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	locs := []*time.Location{
+		time.UTC,
+		time.FixedZone("", -7*3600),
+		time.FixedZone("CEST", 2*3600),
+		ny,
+	}
+	for _, loc := range locs {
+		tm := time.Date(2023, 7, 1, 9, 30, 0, 123, loc)
+		b := marshalTime(t, tm, msgpack.TimeFormatExtZone)
+
+		var out time.Time
+		require.Nil(t, msgpack.Unmarshal(b, &out))
+		require.True(t, tm.Equal(out))
+		require.Equal(t, loc.String(), out.Location().String())
+		require.Equal(t, tm.String(), out.String())
+
+		v, err := msgpack.NewDecoder(bytes.NewReader(b)).DecodeInterface()
+		require.Nil(t, err)
+		require.Equal(t, tm.String(), v.(time.Time).String())
+	}
+
+	// Later times in the location use its rules.
+	tm := time.Date(2023, 7, 1, 9, 30, 0, 0, ny)
+	var out time.Time
+	require.Nil(t, msgpack.Unmarshal(marshalTime(t, tm, msgpack.TimeFormatExtZone), &out))
+	require.Equal(t, "EST", out.AddDate(0, 6, 0).Format("MST"))
+
+	// Zero time keeps the location.
+	zero := time.Time{}.In(ny)
+	require.Nil(t, msgpack.Unmarshal(marshalTime(t, zero, msgpack.TimeFormatExtZone), &out))
+	require.Equal(t, ny.String(), out.Location().String())
+	require.True(t, out.IsZero())
+}
+
+func TestTimeFormatExtZoneUnknown(t *testing.T) {
+	// Named zone "Mars/Olympus" with the offset of 1 hour and the timestamp 1.
+	b := []byte{0xc7, 22, 0x7f, 2, 0, 0, 0x0e, 0x10, 12}
+	b = append(b, "Mars/Olympus"...)
+	b = append(b, 0, 0, 0, 1)
+
+	var out time.Time
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, "1970-01-01 01:00:01 +0100 Mars/Olympus", out.String())
+
+	err := msgpack.Unmarshal([]byte{0xd5, 0x7f, 7, 0}, &out)
+	require.EqualError(t, err, "msgpack: invalid time zone kind=7")
+
+	// Unknown names are cached, so decoding them again doesn't look them up.
+	for i := 0; i < 100; i++ {
+		b = []byte{0xc7, 22, 0x7f, 2, 0, 0, 0x0e, 0x10, 12}
+		b = append(b, "Mars/Olympus"...)
+		b = append(b, 0, 0, 0, byte(i))
+		require.Nil(t, msgpack.Unmarshal(b, &out))
+		require.Equal(t, "Mars/Olympus", out.Location().String())
+		require.Equal(t, int64(i), out.Unix())
+	}
+}
+
+func TestDecodeTimeExt(t *testing.T) {
+	for _, format := range []msgpack.TimeFormat{msgpack.TimeFormatExt, msgpack.TimeFormatExtZone} {
+		tm := time.Date(2023, 7, 1, 9, 30, 0, 123, time.FixedZone("CEST", 2*3600))
+		b := marshalTime(t, tm, format)
+
+		dec := msgpack.NewDecoder(bytes.NewReader(b))
+		id, n, err := dec.DecodeExtHeader()
+		require.Nil(t, err)
+		data := make([]byte, n)
+		require.Nil(t, dec.ReadFull(data))
+
+		out, err := msgpack.DecodeTimeExt(id, data)
+		require.Nil(t, err)
+		require.True(t, tm.Equal(out))
+		if id == msgpack.TimeZoneExtID {
+			require.Equal(t, tm.String(), out.String())
+		} else {
+			require.Equal(t, msgpack.TimeExtID, id)
+		}
+	}
+
+	_, err := msgpack.DecodeTimeExt(msgpack.TimeExtID, []byte{1, 2, 3})
+	require.EqualError(t, err, "msgpack: invalid ext len=3 decoding time")
+	_, err = msgpack.DecodeTimeExt(1, []byte{0, 0, 0, 1})
+	require.EqualError(t, err, "msgpack: invalid time ext id=1")
+}
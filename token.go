@@ -0,0 +1,133 @@
+package msgpack
+
+import (
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// Token holds a value of one of these types:
+//   - nil, for msgpack nil,
+//   - bool, for msgpack bool,
+//   - int64, for msgpack fixnum and int8/16/32/64,
+//   - uint64, for msgpack uint8/16/32/64,
+//   - float32 and float64, for msgpack float32/64,
+//   - string, for msgpack str,
+//   - []byte, for msgpack bin,
+//   - ArrayStart, for msgpack array header,
+//   - MapStart, for msgpack map header,
+//   - ExtHeader, for msgpack ext header.
+type Token interface{}
+
+// ArrayStart is a Token that starts an array with the given number of elements.
+type ArrayStart int
+
+// MapStart is a Token that starts a map with the given number of key-value pairs.
+type MapStart int
+
+// ExtHeader is a Token that starts an ext value. It is followed by Len bytes
+// of ext data that must be read with Decoder.ReadFull before the next token.
+type ExtHeader struct {
+	ID  int8
+	Len int
+}
+
+// Token returns the next token in the input stream. At the end of the input
+// stream, Token returns nil, io.EOF.
+//
+// Array and map elements are not consumed by Token: ArrayStart(n) is followed
+// by n tokens or values and MapStart(n) is followed by 2*n tokens or values.
+func (d *Decoder) Token() (Token, error) {
+	c, err := d.readCode()
+	if err != nil {
+		return nil, err
+	}
+
+	if msgpcode.IsFixedNum(c) {
+		return int64(int8(c)), nil
+	}
+	if msgpcode.IsFixedMap(c) {
+		return MapStart(c & msgpcode.FixedMapMask), nil
+	}
+	if msgpcode.IsFixedArray(c) {
+		return ArrayStart(c & msgpcode.FixedArrayMask), nil
+	}
+	if msgpcode.IsFixedString(c) {
+		return d.string(c)
+	}
+
+	switch c {
+	case msgpcode.Nil:
+		return nil, nil
+	case msgpcode.False, msgpcode.True:
+		return d.bool(c)
+	case msgpcode.Float:
+		return d.float32(c)
+	case msgpcode.Double:
+		return d.float64(c)
+	case msgpcode.Uint8, msgpcode.Uint16, msgpcode.Uint32, msgpcode.Uint64:
+		return d.uint(c)
+	case msgpcode.Int8, msgpcode.Int16, msgpcode.Int32, msgpcode.Int64:
+		return d.int(c)
+	case msgpcode.Bin8, msgpcode.Bin16, msgpcode.Bin32:
+		return d.bytes(c, nil)
+	case msgpcode.Str8, msgpcode.Str16, msgpcode.Str32:
+		return d.string(c)
+	case msgpcode.Array16, msgpcode.Array32:
+		n, err := d.arrayLen(c)
+		if err != nil {
+			return nil, err
+		}
+		return ArrayStart(n), nil
+	case msgpcode.Map16, msgpcode.Map32:
+		n, err := d.mapLen(c)
+		if err != nil {
+			return nil, err
+		}
+		return MapStart(n), nil
+	case msgpcode.FixExt1, msgpcode.FixExt2, msgpcode.FixExt4, msgpcode.FixExt8, msgpcode.FixExt16,
+		msgpcode.Ext8, msgpcode.Ext16, msgpcode.Ext32:
+		extID, extLen, err := d.extHeader(c)
+		if err != nil {
+			return nil, err
+		}
+		return ExtHeader{ID: extID, Len: extLen}, nil
+	}
+
+	return nil, fmt.Errorf("msgpack: unknown code %x decoding token", c)
+}
+
+// WriteToken writes the token t. It accepts all the types returned
+// by Decoder.Token as well as int and uint. Ext data that follows ExtHeader
+// must be written by the caller to the Encoder's writer.
+func (e *Encoder) WriteToken(t Token) error {
+	switch t := t.(type) {
+	case nil:
+		return e.EncodeNil()
+	case bool:
+		return e.EncodeBool(t)
+	case int64:
+		return e.EncodeInt(t)
+	case int:
+		return e.EncodeInt(int64(t))
+	case uint64:
+		return e.EncodeUint(t)
+	case uint:
+		return e.EncodeUint(uint64(t))
+	case float32:
+		return e.EncodeFloat32(t)
+	case float64:
+		return e.EncodeFloat64(t)
+	case string:
+		return e.encodeNormalString(t)
+	case []byte:
+		return e.EncodeBytes(t)
+	case ArrayStart:
+		return e.EncodeArrayLen(int(t))
+	case MapStart:
+		return e.EncodeMapLen(int(t))
+	case ExtHeader:
+		return e.EncodeExtHeader(t.ID, t.Len)
+	}
+	return fmt.Errorf("msgpack: WriteToken(unsupported %T)", t)
+}
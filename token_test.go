@@ -0,0 +1,77 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestToken(t *testing.T) {
+	in := map[string]interface{}{
+		"list": []interface{}{int64(-1), uint64(300), 1.5, true, nil},
+	}
+	b, err := msgpack.Marshal(in)
+	require.Nil(t, err)
+
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	var tokens []msgpack.Token
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		tokens = append(tokens, tok)
+	}
+
+	require.Equal(t, []msgpack.Token{
+		msgpack.MapStart(1),
+		"list",
+		msgpack.ArrayStart(5),
+		int64(-1),
+		uint64(300),
+		1.5,
+		true,
+		nil,
+	}, tokens)
+}
+
+func TestTokenTranscode(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]interface{}{
+		"bytes": []byte("hello"),
+		"time":  time.Unix(1e9, 0),
+		"float": float32(0.5),
+	})
+	require.Nil(t, err)
+
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+		require.Nil(t, enc.WriteToken(tok))
+
+		if ext, ok := tok.(msgpack.ExtHeader); ok {
+			data := make([]byte, ext.Len)
+			require.Nil(t, dec.ReadFull(data))
+			_, err := enc.Writer().Write(data)
+			require.Nil(t, err)
+		}
+	}
+
+	require.Equal(t, b, buf.Bytes())
+}
+
+func TestWriteTokenUnsupported(t *testing.T) {
+	enc := msgpack.NewEncoder(io.Discard)
+	err := enc.WriteToken(struct{}{})
+	require.EqualError(t, err, "msgpack: WriteToken(unsupported struct {})")
+}
@@ -0,0 +1,184 @@
+package msgpack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TypeNameExtID is the ext type of values wrapped with their registered
+// type name by Encoder.UseTypeNames. The ext data is the name encoded as
+// a string followed by the encoded value. Negative ext types are reserved by
+// the MessagePack spec, so it is an application type: programs that use type
+// names must not register the type for their own values.
+const TypeNameExtID int8 = 126
+
+// RegisterName registers the type of value under the name with the default
+// registry. See Registry.RegisterName.
+func RegisterName(name string, value interface{}) {
+	defaultRegistry.RegisterName(name, value)
+}
+
+// RegisterName registers the type of value under the name like gob.RegisterName.
+// An Encoder with UseTypeNames wraps interface values of the type in an ext
+// carrying the name, and decoding the ext into interface{} or a nil interface
+// of another type, e.g. a struct field of type Shape, produces a value of the
+// registered type.
+//
+// A pointer to the type and the type a registered pointer points to are
+// encoded with the same name, and decoded as the registered type or,
+// if the interface requires it, as a pointer to it or the value it points to.
+// RegisterName panics if the name or the type is already registered differently.
+func (r *Registry) RegisterName(name string, value interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	typ := reflect.TypeOf(value)
+	if t, ok := r.nameTypes[name]; ok && t != typ {
+		err := fmt.Errorf("msgpack: registering duplicate types for %q: %s != %s", name, t, typ)
+		panic(err)
+	}
+	if n, ok := r.typeNames[typ]; ok && n != name {
+		err := fmt.Errorf("msgpack: registering duplicate names for %s: %q != %q", typ, n, name)
+		panic(err)
+	}
+
+	r.nameTypes[name] = typ
+	r.typeNames[typ] = name
+}
+
+func (r *Registry) typeName(typ reflect.Type) (string, bool) {
+	r.mu.RLock()
+	name, ok := r.typeNames[typ]
+	r.mu.RUnlock()
+	return name, ok
+}
+
+// valueTypeName returns the name v is encoded with. A pointer and the type
+// it points to share the name, but nil pointers are encoded as nil.
+func (r *Registry) valueTypeName(v reflect.Value) (string, bool) {
+	typ := v.Type()
+	if name, ok := r.typeName(typ); ok {
+		return name, true
+	}
+	if typ.Kind() != reflect.Ptr {
+		return r.typeName(reflect.PtrTo(typ))
+	}
+	if !v.IsNil() {
+		return r.typeName(typ.Elem())
+	}
+	return "", false
+}
+
+func (r *Registry) nameType(name string) (reflect.Type, bool) {
+	r.mu.RLock()
+	typ, ok := r.nameTypes[name]
+	r.mu.RUnlock()
+	return typ, ok
+}
+
+//------------------------------------------------------------------------------
+
+// UseTypeNames causes the Encoder to wrap interface values, e.g. struct fields
+// of type interface{} or Shape and map[string]interface{} values, in an ext
+// carrying the type name if the type is registered with RegisterName.
+// Values of unregistered types are encoded as usual.
+func (e *Encoder) UseTypeNames(on bool) {
+	if on {
+		e.flags |= useTypeNamesFlag
+	} else {
+		e.flags &= ^useTypeNamesFlag
+	}
+}
+
+// encodeInterface encodes v stored in an interface.
+func (e *Encoder) encodeInterface(v interface{}) error {
+	if v != nil && e.flags&useTypeNamesFlag != 0 {
+		return e.encodeTypeName(reflect.ValueOf(v))
+	}
+	return e.Encode(v)
+}
+
+func (e *Encoder) encodeTypeName(v reflect.Value) error {
+	name, ok := e.registry().valueTypeName(v)
+	if !ok {
+		return e.EncodeValue(v)
+	}
+
+	// The ext length must be known before the value is written,
+	// so the value is encoded with a separate encoder sharing
+	// the dict and the pointer tracking.
+	ve := GetEncoder()
+	defer PutEncoder(ve)
+
+	ve.Reset(nil)
+	ve.ResetBytes(nil)
+	ve.flags = e.flags
+	ve.structTag = e.structTag
+	ve.timeFormat = e.timeFormat
+	ve.reg = e.reg
+	ve.cycleDepth = e.cycleDepth
+	ve.maxDepth = e.maxDepth
+	ve.depth = e.depth
+	ve.ptrLevel = e.ptrLevel
+	ve.ptrSeen = e.ptrSeen
+	ve.dict = e.dict
+
+	err := ve.EncodeString(name)
+	if err == nil {
+		err = ve.EncodeValue(v)
+	}
+	// The dict and the map of seen pointers may be allocated lazily.
+	e.dict = ve.dict
+	e.ptrSeen = ve.ptrSeen
+	if err != nil {
+		return err
+	}
+
+	b := ve.Bytes()
+	if err := e.EncodeExtHeader(TypeNameExtID, len(b)); err != nil {
+		return err
+	}
+	return e.write(b)
+}
+
+func (d *Decoder) decodeTypeName(extLen int) (interface{}, error) {
+	offset := d.InputOffset()
+
+	name, err := d.DecodeString()
+	if err != nil {
+		return nil, err
+	}
+
+	typ, ok := d.registry().nameType(name)
+	if !ok {
+		return nil, fmt.Errorf("msgpack: type name %q is not registered", name)
+	}
+
+	v := d.newValue(typ).Elem()
+	if err := d.DecodeValue(v); err != nil {
+		return nil, err
+	}
+
+	if n := d.InputOffset() - offset; n != int64(extLen) {
+		return nil, fmt.Errorf("msgpack: type name ext has %d bytes, wanted %d", n, extLen)
+	}
+	return v.Interface(), nil
+}
+
+// pointerOrElem returns a pointer to rv or the value rv points to if it is
+// assignable to typ. Values decoded from type names have the registered
+// type, which may be the pointer or the element type of the encoded value.
+func pointerOrElem(rv reflect.Value, typ reflect.Type) (reflect.Value, bool) {
+	if rv.Kind() == reflect.Ptr {
+		if !rv.IsNil() && rv.Type().Elem().AssignableTo(typ) {
+			return rv.Elem(), true
+		}
+		return rv, false
+	}
+	if reflect.PtrTo(rv.Type()).AssignableTo(typ) {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		return ptr, true
+	}
+	return rv, false
+}
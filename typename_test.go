@@ -0,0 +1,159 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type Shape interface {
+	Area() float64
+}
+
+type Circle struct {
+	R float64
+}
+
+func (c Circle) Area() float64 { return math.Pi * c.R * c.R }
+
+type Square struct {
+	Side float64
+}
+
+func (s *Square) Area() float64 { return s.Side * s.Side }
+
+type ShapeEvent struct {
+	Shape  Shape
+	Any    interface{}
+	Shapes []Shape
+	Meta   map[string]interface{}
+}
+
+// Label implements textSetter only with a pointer.
+type Label struct {
+	Text string
+}
+
+func (l *Label) SetText(s string) { l.Text = s }
+
+type textSetter interface {
+	SetText(string)
+}
+
+func init() {
+	msgpack.RegisterName("circle", Circle{})
+	msgpack.RegisterName("square", &Square{})
+}
+
+func marshalTypeNames(t *testing.T, v interface{}) []byte {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseTypeNames(true)
+	require.Nil(t, enc.Encode(v))
+	return buf.Bytes()
+}
+
+func TestTypeNames(t *testing.T) {
+	in := ShapeEvent{
+		Shape:  Circle{R: 1},
+		Any:    &Square{Side: 2},
+		Shapes: []Shape{&Square{Side: 3}, nil},
+		Meta:   map[string]interface{}{"shape": Circle{R: 4}, "n": int8(1)},
+	}
+	b := marshalTypeNames(t, in)
+
+	var out ShapeEvent
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, in, out)
+
+	var v interface{}
+	require.Nil(t, msgpack.Unmarshal(marshalTypeNames(t, []interface{}{Circle{R: 5}}), &v))
+	require.Equal(t, []interface{}{Circle{R: 5}}, v)
+
+	// Without type names the values are decoded as maps.
+	b, err := msgpack.Marshal(map[string]interface{}{"shape": Circle{R: 1}})
+	require.Nil(t, err)
+	v = nil
+	require.Nil(t, msgpack.Unmarshal(b, &v))
+	require.Equal(t, map[string]interface{}{"shape": map[string]interface{}{"R": 1.0}}, v)
+
+	// Unregistered types are encoded as usual.
+	b = marshalTypeNames(t, []interface{}{Label{Text: "a"}})
+	v = nil
+	require.Nil(t, msgpack.Unmarshal(b, &v))
+	require.Equal(t, []interface{}{map[string]interface{}{"Text": "a"}}, v)
+}
+
+func TestTypeNamesPointer(t *testing.T) {
+	type event struct {
+		Shape  Shape
+		Any    interface{}
+		Setter textSetter
+		Nil    interface{}
+	}
+
+	r := msgpack.NewRegistry()
+	r.RegisterName("circle", Circle{})
+	r.RegisterName("square", &Square{})
+	r.RegisterName("label", Label{})
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetRegistry(r)
+	enc.UseTypeNames(true)
+	require.Nil(t, enc.Encode(event{
+		Shape:  &Circle{R: 1},
+		Any:    Square{Side: 2},
+		Setter: &Label{Text: "a"},
+		Nil:    (*Circle)(nil),
+	}))
+
+	// The values are decoded as the registered types
+	// unless the interface requires a pointer.
+	var out event
+	dec := msgpack.NewDecoder(&buf)
+	dec.SetRegistry(r)
+	require.Nil(t, dec.Decode(&out))
+	require.Equal(t, event{
+		Shape:  Circle{R: 1},
+		Any:    &Square{Side: 2},
+		Setter: &Label{Text: "a"},
+	}, out)
+}
+
+func TestTypeNamesErrors(t *testing.T) {
+	r := msgpack.NewRegistry()
+	r.RegisterName("circle", Circle{})
+	r.RegisterName("label", Label{})
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetRegistry(r)
+	enc.UseTypeNames(true)
+	require.Nil(t, enc.Encode(map[string]interface{}{"Shape": Label{Text: "a"}}))
+
+	// Label does not implement Shape.
+	var out ShapeEvent
+	dec := msgpack.NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.SetRegistry(r)
+	err := dec.Decode(&out)
+	require.EqualError(t, err,
+		"msgpack: decoding ShapeEvent.Shape at offset 7: "+
+			"cannot decode msgpack_test.Label into msgpack_test.Shape")
+
+	// The default registry doesn't have Label.
+	err = msgpack.Unmarshal(buf.Bytes(), &out)
+	require.EqualError(t, err,
+		`msgpack: decoding ShapeEvent.Shape at offset 7: type name "label" is not registered`)
+
+	require.PanicsWithError(t,
+		`msgpack: registering duplicate types for "circle": msgpack_test.Circle != *msgpack_test.Circle`,
+		func() { r.RegisterName("circle", &Circle{}) })
+	require.PanicsWithError(t,
+		`msgpack: registering duplicate names for msgpack_test.Circle: "circle" != "round"`,
+		func() { r.RegisterName("round", Circle{}) })
+}
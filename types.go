@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/vmihailenco/tagparser/v2"
 )
@@ -37,32 +41,21 @@ type (
 	decoderFunc func(*Decoder, reflect.Value) error
 )
 
-var (
-	typeEncMap sync.Map
-	typeDecMap sync.Map
-)
-
-// Register registers encoder and decoder functions for a value.
+// Register registers encoder and decoder functions for a value
+// with the default registry.
 // This is low level API and in most cases you should prefer implementing
 // CustomEncoder/CustomDecoder or Marshaler/Unmarshaler interfaces.
 func Register(value interface{}, enc encoderFunc, dec decoderFunc) {
-	typ := reflect.TypeOf(value)
-	if enc != nil {
-		typeEncMap.Store(typ, enc)
-	}
-	if dec != nil {
-		typeDecMap.Store(typ, dec)
-	}
+	defaultRegistry.Register(value, enc, dec)
 }
 
 //------------------------------------------------------------------------------
 
 const defaultStructTag = "msgpack"
 
-var structs = newStructCache()
-
 type structCache struct {
 	m sync.Map
+	r *Registry
 }
 
 type structCacheKey struct {
@@ -70,8 +63,8 @@ type structCacheKey struct {
 	tag string
 }
 
-func newStructCache() *structCache {
-	return new(structCache)
+func newStructCache(r *Registry) *structCache {
+	return &structCache{r: r}
 }
 
 func (m *structCache) Fields(typ reflect.Type, tag string) *fields {
@@ -81,7 +74,7 @@ func (m *structCache) Fields(typ reflect.Type, tag string) *fields {
 		return v.(*fields)
 	}
 
-	fs := getFields(typ, tag)
+	fs := m.r.getFields(typ, tag)
 	m.m.Store(key, fs)
 
 	return fs
@@ -95,6 +88,18 @@ type field struct {
 	name      string
 	index     []int
 	omitEmpty bool
+	omitZero  bool
+	required  bool
+	// defaultValue is set with the default tag option.
+	defaultValue reflect.Value
+	// trackedIndex is the index of the field in fields.tracked.
+	trackedIndex int
+}
+
+// isTracked reports whether the decoder must know if the field is present
+// in the input, i.e. the field is required or has a default value.
+func (f *field) isTracked() bool {
+	return f.required || f.defaultValue.IsValid()
 }
 
 func (f *field) Omit(e *Encoder, strct reflect.Value) bool {
@@ -102,6 +107,9 @@ func (f *field) Omit(e *Encoder, strct reflect.Value) bool {
 	if !ok {
 		return true
 	}
+	if f.omitZero && isZeroValue(v) {
+		return true
+	}
 	forced := e.flags&omitEmptyFlag != 0
 	return (f.omitEmpty || forced) && e.isEmptyValue(v)
 }
@@ -127,7 +135,17 @@ type fields struct {
 	List    []*field
 	AsArray bool
 
+	// hasOmitEmpty is set if any field has the omitempty
+	// or omitzero tag option.
 	hasOmitEmpty bool
+	// remain is the field with the remain tag option that holds
+	// the map entries that don't match other fields.
+	remain *field
+	// tracked holds the fields with the required or default tag options.
+	tracked []*field
+
+	sortOnce sync.Once
+	sorted   []*field
 }
 
 func newFields(typ reflect.Type) *fields {
@@ -142,9 +160,50 @@ func (fs *fields) Add(field *field) {
 	fs.warnIfFieldExists(field.name)
 	fs.Map[field.name] = field
 	fs.List = append(fs.List, field)
-	if field.omitEmpty {
+	if field.omitEmpty || field.omitZero {
 		fs.hasOmitEmpty = true
 	}
+	if field.isTracked() {
+		field.trackedIndex = len(fs.tracked)
+		fs.tracked = append(fs.tracked, field)
+	}
+}
+
+// setAbsent sets the default values of the tracked fields that are not seen
+// and still have the zero value, so decoding into a populated struct keeps
+// its values, and returns *MissingFieldsError if any of them is required.
+// seen is indexed by field.trackedIndex.
+func (fs *fields) setAbsent(strct reflect.Value, seen []bool) error {
+	var missing []string
+	for i, f := range fs.tracked {
+		if seen[i] {
+			continue
+		}
+		if f.required {
+			missing = append(missing, f.name)
+			continue
+		}
+		if v := fieldByIndexAlloc(strct, f.index); isZeroValue(v) {
+			v.Set(f.defaultValue)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingFieldsError{Type: fs.Type, Fields: missing}
+	}
+	return nil
+}
+
+// canBeAbsent reports whether the fields starting at the index i may be
+// missing from an array-encoded struct: they have default values or the
+// omitempty or omitzero tag options, or they are required and reported by
+// setAbsent.
+func (fs *fields) canBeAbsent(i int) bool {
+	for _, f := range fs.List[i:] {
+		if !f.isTracked() && !f.omitEmpty && !f.omitZero {
+			return false
+		}
+	}
+	return true
 }
 
 func (fs *fields) warnIfFieldExists(name string) {
@@ -154,14 +213,19 @@ func (fs *fields) warnIfFieldExists(name string) {
 }
 
 func (fs *fields) OmitEmpty(e *Encoder, strct reflect.Value) []*field {
+	list := fs.List
+	if e.flags&canonicalEncodingFlag != 0 {
+		list = fs.sortedList()
+	}
+
 	forced := e.flags&omitEmptyFlag != 0
 	if !fs.hasOmitEmpty && !forced {
-		return fs.List
+		return list
 	}
 
-	fields := make([]*field, 0, len(fs.List))
+	fields := make([]*field, 0, len(list))
 
-	for _, f := range fs.List {
+	for _, f := range list {
 		if !f.Omit(e, strct) {
 			fields = append(fields, f)
 		}
@@ -170,7 +234,50 @@ func (fs *fields) OmitEmpty(e *Encoder, strct reflect.Value) []*field {
 	return fields
 }
 
-func getFields(typ reflect.Type, fallbackTag string) *fields {
+// hasRemainEntries reports whether the remain field of strct has entries
+// that are encoded, i.e. that don't have the same name as a field.
+func (fs *fields) hasRemainEntries(strct reflect.Value) bool {
+	if fs.remain == nil {
+		return false
+	}
+	m, ok := fieldByIndex(strct, fs.remain.index)
+	if !ok || m.Len() == 0 {
+		return false
+	}
+
+	iter := m.MapRange()
+	for iter.Next() {
+		if _, ok := fs.Map[iter.Key().String()]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedList returns the fields sorted by the encoded names
+// for canonical encoding.
+func (fs *fields) sortedList() []*field {
+	fs.sortOnce.Do(func() {
+		fs.sorted = make([]*field, len(fs.List))
+		copy(fs.sorted, fs.List)
+		sort.SliceStable(fs.sorted, func(i, j int) bool {
+			return canonicalLess(fs.sorted[i].name, fs.sorted[j].name)
+		})
+	})
+	return fs.sorted
+}
+
+// canonicalLess reports whether the encoding of the string a
+// sorts before the encoding of b.
+func canonicalLess(a, b string) bool {
+	// Encoded strings are ordered by the length header first.
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func (r *Registry) getFields(typ reflect.Type, fallbackTag string) *fields {
 	fs := newFields(typ)
 
 	var omitEmpty bool
@@ -202,6 +309,8 @@ func getFields(typ reflect.Type, fallbackTag string) *fields {
 			name:      tag.Name,
 			index:     f.Index,
 			omitEmpty: omitEmpty || tag.HasOption("omitempty"),
+			omitZero:  tag.HasOption("omitzero"),
+			required:  tag.HasOption("required"),
 		}
 
 		if tag.HasOption("intern") {
@@ -216,21 +325,76 @@ func getFields(typ reflect.Type, fallbackTag string) *fields {
 				err := fmt.Errorf("msgpack: intern strings are not supported on %s", f.Type)
 				panic(err)
 			}
+		} else if name, ok := tagOption(tag, "time"); ok {
+			format, err := ParseTimeFormat(name)
+			if err != nil {
+				panic(err)
+			}
+			if f.Type != timeType {
+				err := fmt.Errorf("msgpack: time format is not supported on %s", f.Type)
+				panic(err)
+			}
+			field.encoder = timeFormatEncoder(format)
+			field.decoder = timeFormatDecoder(format)
+		} else if key, ok := tagOption(tag, "union"); ok {
+			if f.Type.Kind() != reflect.Interface {
+				err := fmt.Errorf("msgpack: union option is not supported on %s", f.Type)
+				panic(err)
+			}
+			field.encoder = unionEncoder(key)
+			field.decoder = unionDecoder(key)
+		} else if tag.HasOption("string") {
+			switch {
+			case isNumberOrBool(f.Type.Kind()):
+				field.encoder = encodeNumberStringValue
+				field.decoder = decodeNumberStringValue
+			case f.Type.Kind() == reflect.Ptr && isNumberOrBool(f.Type.Elem().Kind()):
+				field.encoder = encodeNumberStringPtrValue
+				field.decoder = decodeNumberStringPtrValue
+			default:
+				err := fmt.Errorf("msgpack: string option is not supported on %s", f.Type)
+				panic(err)
+			}
 		} else {
-			field.encoder = getEncoder(f.Type)
-			field.decoder = getDecoder(f.Type)
+			field.encoder = r.getEncoder(f.Type)
+			field.decoder = r.getDecoder(f.Type)
 		}
 
 		if field.name == "" {
 			field.name = f.Name
 		}
 
+		if s, ok := tagOption(tag, "default"); ok {
+			if field.required {
+				err := fmt.Errorf("msgpack: required field %s.%s can't have a default value", typ, f.Name)
+				panic(err)
+			}
+			v, err := parseDefault(f.Type, s)
+			if err != nil {
+				panic(err)
+			}
+			field.defaultValue = v
+		}
+
+		if tag.HasOption("remain") {
+			if !isRemainType(f.Type) {
+				err := fmt.Errorf("msgpack: remain option is not supported on %s", f.Type)
+				panic(err)
+			}
+			if fs.remain != nil {
+				err := fmt.Errorf("msgpack: %s has more than one remain field", typ)
+				panic(err)
+			}
+			fs.remain = field
+			continue
+		}
+
 		if f.Anonymous && !tag.HasOption("noinline") {
 			inline := tag.HasOption("inline")
 			if inline {
-				inlineFields(fs, f.Type, field, fallbackTag)
+				r.inlineFields(fs, f.Type, field, fallbackTag)
 			} else {
-				inline = shouldInline(fs, f.Type, field, fallbackTag)
+				inline = r.shouldInline(fs, f.Type, field, fallbackTag)
 			}
 
 			if inline {
@@ -252,6 +416,82 @@ func getFields(typ reflect.Type, fallbackTag string) *fields {
 	return fs
 }
 
+// parseDefault parses the value of the default tag option: a bool, a number,
+// a string, a time.Duration like "1m30s" or a time.Time in RFC 3339 format.
+func parseDefault(typ reflect.Type, s string) (reflect.Value, error) {
+	var v interface{}
+	var err error
+	switch {
+	case typ == timeType:
+		v, err = parseDefaultTime(s)
+	case typ == durationType:
+		v, err = time.ParseDuration(s)
+	default:
+		switch typ.Kind() {
+		case reflect.Bool:
+			v, err = strconv.ParseBool(s)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v, err = strconv.ParseInt(s, 10, typ.Bits())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v, err = strconv.ParseUint(s, 10, typ.Bits())
+		case reflect.Float32, reflect.Float64:
+			v, err = strconv.ParseFloat(s, typ.Bits())
+		case reflect.String:
+			v = s
+		default:
+			return reflect.Value{}, fmt.Errorf("msgpack: default option is not supported on %s", typ)
+		}
+	}
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("msgpack: invalid default value %q for %s", s, typ)
+	}
+	return reflect.ValueOf(v).Convert(typ), nil
+}
+
+// parseDefaultTime parses the time in RFC 3339 format. Unlike time.Parse,
+// it does not depend on the local time zone: the time is in UTC if the offset
+// is zero and in a fixed zone otherwise.
+func parseDefaultTime(s string) (time.Time, error) {
+	tm, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if _, offset := tm.Zone(); offset != 0 {
+		return tm.In(time.FixedZone("", offset)), nil
+	}
+	return tm.UTC(), nil
+}
+
+// tagOption returns the value of the option written as name:value
+// or name=value.
+// isNumberOrBool reports whether the string tag option supports kind.
+func isNumberOrBool(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func tagOption(tag *tagparser.Tag, name string) (string, bool) {
+	if value, ok := tag.Options[name]; ok {
+		return value, true
+	}
+	for opt, value := range tag.Options {
+		if strings.HasPrefix(opt, name+"=") {
+			// The parser splits name=a:b into the option name=a and the value b.
+			if value != "" {
+				return opt[len(name)+1:] + ":" + value, true
+			}
+			return opt[len(name)+1:], true
+		}
+	}
+	return "", false
+}
+
 var (
 	encodeStructValuePtr uintptr
 	decodeStructValuePtr uintptr
@@ -263,9 +503,9 @@ func init() {
 	decodeStructValuePtr = reflect.ValueOf(decodeStructValue).Pointer()
 }
 
-func inlineFields(fs *fields, typ reflect.Type, f *field, tag string) {
-	inlinedFields := getFields(typ, tag).List
-	for _, field := range inlinedFields {
+func (r *Registry) inlineFields(fs *fields, typ reflect.Type, f *field, tag string) {
+	inlined := r.getFields(typ, tag)
+	for _, field := range inlined.List {
 		if _, ok := fs.Map[field.name]; ok {
 			// Don't inline shadowed fields.
 			continue
@@ -273,9 +513,10 @@ func inlineFields(fs *fields, typ reflect.Type, f *field, tag string) {
 		field.index = append(f.index, field.index...)
 		fs.Add(field)
 	}
+	fs.inlineRemain(inlined, f)
 }
 
-func shouldInline(fs *fields, typ reflect.Type, f *field, tag string) bool {
+func (r *Registry) shouldInline(fs *fields, typ reflect.Type, f *field, tag string) bool {
 	var encoder encoderFunc
 	var decoder decoderFunc
 
@@ -285,8 +526,8 @@ func shouldInline(fs *fields, typ reflect.Type, f *field, tag string) bool {
 	} else {
 		for typ.Kind() == reflect.Ptr {
 			typ = typ.Elem()
-			encoder = getEncoder(typ)
-			decoder = getDecoder(typ)
+			encoder = r.getEncoder(typ)
+			decoder = r.getDecoder(typ)
 		}
 		if typ.Kind() != reflect.Struct {
 			return false
@@ -300,25 +541,74 @@ func shouldInline(fs *fields, typ reflect.Type, f *field, tag string) bool {
 		return false
 	}
 
-	inlinedFields := getFields(typ, tag).List
-	for _, field := range inlinedFields {
+	inlined := r.getFields(typ, tag)
+	for _, field := range inlined.List {
 		if _, ok := fs.Map[field.name]; ok {
 			// Don't auto inline if there are shadowed fields.
 			return false
 		}
 	}
 
-	for _, field := range inlinedFields {
+	for _, field := range inlined.List {
 		field.index = append(f.index, field.index...)
 		fs.Add(field)
 	}
+	fs.inlineRemain(inlined, f)
 	return true
 }
 
+// inlineRemain uses the remain field of the inlined struct
+// unless fs already has one.
+func (fs *fields) inlineRemain(inlined *fields, f *field) {
+	if inlined.remain == nil || fs.remain != nil {
+		return
+	}
+	remain := *inlined.remain
+	remain.index = append(f.index, remain.index...)
+	fs.remain = &remain
+}
+
+// isRemainType reports whether the remain tag option is supported on typ:
+// a map with string keys and RawMessage or interface{} values.
+func isRemainType(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Map || typ.Key().Kind() != reflect.String {
+		return false
+	}
+	elem := typ.Elem()
+	return elem == rawMessageType || elem == interfaceType
+}
+
 type isZeroer interface {
 	IsZero() bool
 }
 
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// isZeroValue reports whether the omitzero tag option omits v: v is nil,
+// its IsZero method returns true or v is the zero value of its type.
+// Unlike isEmptyValue, empty slices and maps are not zero.
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return true
+		}
+	}
+	if v.Type().Implements(isZeroerType) {
+		return v.Interface().(isZeroer).IsZero()
+	}
+	return v.IsZero()
+}
+
+// IsEmpty reports whether the omitempty tag option omits v when it is
+// encoded by e. It is used by code generated by cmd/msgpackgen.
+func (e *Encoder) IsEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	return e.isEmptyValue(reflect.ValueOf(v))
+}
+
 func (e *Encoder) isEmptyValue(v reflect.Value) bool {
 	kind := v.Kind()
 
@@ -338,9 +628,9 @@ func (e *Encoder) isEmptyValue(v reflect.Value) bool {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
 		return v.Len() == 0
 	case reflect.Struct:
-		structFields := structs.Fields(v.Type(), e.structTag)
+		structFields := e.registry().structFields(v.Type(), e.structTag)
 		fields := structFields.OmitEmpty(e, v)
-		return len(fields) == 0
+		return len(fields) == 0 && !structFields.hasRemainEntries(v)
 	case reflect.Bool:
 		return !v.Bool()
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -3,6 +3,7 @@ package msgpack_test
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -307,6 +308,16 @@ func TestEncoder(t *testing.T) {
 	}
 }
 
+func TestEncoderCompactInts(t *testing.T) {
+	enc := msgpack.NewEncoder(nil)
+	require.False(t, enc.CompactInts())
+	enc.UseCompactInts(true)
+	require.True(t, enc.CompactInts())
+	enc.UseCompactInts(false)
+	enc.UseCanonicalEncoding(true)
+	require.True(t, enc.CompactInts())
+}
+
 type floatEncoderTest struct {
 	in      interface{}
 	wanted  string
@@ -1144,3 +1155,453 @@ func mustParseTime(format, s string) time.Time {
 	}
 	return tm
 }
+
+//------------------------------------------------------------------------------
+
+type StringOptionTest struct {
+	ID    uint64  `msgpack:"id,string"`
+	Delta int8    `msgpack:",string"`
+	Ratio float64 `msgpack:",string,omitempty"`
+	OK    bool    `json:"ok,string"`
+	Count int
+}
+
+func TestStringOption(t *testing.T) {
+	in := StringOptionTest{ID: math.MaxUint64, Delta: -5, Ratio: 0.1, OK: true, Count: 1}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	require.Nil(t, enc.Encode(in))
+	b := buf.Bytes()
+
+	var m map[string]interface{}
+	require.Nil(t, msgpack.Unmarshal(b, &m))
+	require.Equal(t, map[string]interface{}{
+		"id":    "18446744073709551615",
+		"Delta": "-5",
+		"Ratio": "0.1",
+		"ok":    "true",
+		"Count": int8(1),
+	}, m)
+
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.SetCustomStructTag("json")
+	var out StringOptionTest
+	require.Nil(t, dec.Decode(&out))
+	require.Equal(t, in, out)
+
+	// Numbers and bools are accepted too.
+	b, err := msgpack.Marshal(map[string]interface{}{
+		"id": uint64(7), "Delta": -1, "Ratio": 1.5, "OK": false,
+	})
+	require.Nil(t, err)
+	out = StringOptionTest{OK: true}
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, StringOptionTest{ID: 7, Delta: -1, Ratio: 1.5}, out)
+}
+
+func TestStringOptionPointer(t *testing.T) {
+	type pointers struct {
+		ID *int64 `msgpack:"id,string"`
+		OK *bool  `msgpack:",string,omitempty"`
+	}
+
+	id := int64(-7)
+	ok := true
+	in := pointers{ID: &id, OK: &ok}
+	b, err := msgpack.Marshal(in)
+	require.Nil(t, err)
+
+	var m map[string]interface{}
+	require.Nil(t, msgpack.Unmarshal(b, &m))
+	require.Equal(t, map[string]interface{}{"id": "-7", "OK": "true"}, m)
+
+	var out pointers
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, in, out)
+
+	// Nil pointers are encoded as nil and nil is decoded as a nil pointer.
+	b, err = msgpack.Marshal(pointers{})
+	require.Nil(t, err)
+	m = nil
+	require.Nil(t, msgpack.Unmarshal(b, &m))
+	require.Equal(t, map[string]interface{}{"id": nil}, m)
+
+	b, err = msgpack.Marshal(map[string]interface{}{"id": nil, "OK": false})
+	require.Nil(t, err)
+	out = pointers{ID: &id}
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Nil(t, out.ID)
+	require.False(t, *out.OK)
+}
+
+func TestStringOptionInvalid(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]interface{}{"Delta": "300"})
+	require.Nil(t, err)
+	var out StringOptionTest
+	err = msgpack.Unmarshal(b, &out)
+	require.EqualError(t, err,
+		`msgpack: decoding StringOptionTest.Delta at offset 7: strconv.ParseInt: parsing "300": value out of range`)
+
+	type invalidType struct {
+		S []int `msgpack:",string"`
+	}
+	require.PanicsWithError(t, "msgpack: string option is not supported on []int", func() {
+		_, _ = msgpack.Marshal(invalidType{})
+	})
+
+	type invalidPointer struct {
+		S *string `msgpack:",string"`
+	}
+	require.PanicsWithError(t, "msgpack: string option is not supported on *string", func() {
+		_, _ = msgpack.Marshal(invalidPointer{})
+	})
+}
+
+//------------------------------------------------------------------------------
+
+type zeroerTest struct {
+	S string
+}
+
+func (z zeroerTest) IsZero() bool {
+	return z.S == "zero"
+}
+
+type OmitZeroTest struct {
+	Int    int               `msgpack:",omitzero"`
+	Float  float64           `msgpack:",omitzero"`
+	Slice  []int             `msgpack:",omitzero"`
+	Map    map[string]int    `msgpack:",omitzero"`
+	Ptr    *int              `msgpack:",omitzero"`
+	Iface  interface{}       `msgpack:",omitzero"`
+	Time   time.Time         `msgpack:",omitzero"`
+	Zeroer zeroerTest        `msgpack:",omitzero"`
+	Struct struct{ A []int } `msgpack:",omitzero"`
+	Array  [2]int            `msgpack:",omitzero"`
+	Both   []int             `msgpack:",omitzero,omitempty"`
+}
+
+func TestOmitZero(t *testing.T) {
+	b, err := msgpack.Marshal(OmitZeroTest{Zeroer: zeroerTest{S: "zero"}})
+	require.Nil(t, err)
+	require.Equal(t, []byte{0x80}, b)
+
+	zero := 0
+	in := OmitZeroTest{
+		Slice:  []int{},
+		Map:    map[string]int{},
+		Ptr:    &zero,
+		Iface:  0,
+		Zeroer: zeroerTest{},
+		Struct: struct{ A []int }{A: []int{}},
+		Array:  [2]int{0, 1},
+		Both:   []int{},
+	}
+	b, err = msgpack.Marshal(in)
+	require.Nil(t, err)
+
+	var m map[string]interface{}
+	require.Nil(t, msgpack.Unmarshal(b, &m))
+	require.Len(t, m, 7)
+	require.Equal(t, []interface{}{}, m["Slice"])
+	require.Equal(t, map[string]interface{}{}, m["Map"])
+	require.NotContains(t, m, "Int")
+	require.NotContains(t, m, "Time")
+	require.NotContains(t, m, "Both")
+
+	var out OmitZeroTest
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.NotNil(t, out.Slice)
+	require.NotNil(t, out.Map)
+}
+
+type RemainTest struct {
+	ID    int                           `msgpack:"id"`
+	Extra map[string]msgpack.RawMessage `msgpack:",remain"`
+}
+
+type RemainEmbedded struct {
+	RemainTest
+	Name string `msgpack:"name"`
+}
+
+func TestRemain(t *testing.T) {
+	in := map[string]interface{}{"id": 1, "name": "foo", "tags": []string{"a"}}
+	b, err := msgpack.Marshal(in)
+	require.Nil(t, err)
+
+	var out RemainTest
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, 1, out.ID)
+	require.Len(t, out.Extra, 2)
+	require.Equal(t, msgpack.RawMessage{0xa3, 'f', 'o', 'o'}, out.Extra["name"])
+
+	// Unknown fields are written back.
+	b2, err := msgpack.Marshal(out)
+	require.Nil(t, err)
+	var m map[string]interface{}
+	require.Nil(t, msgpack.Unmarshal(b2, &m))
+	require.Equal(t, map[string]interface{}{
+		"id": int8(1), "name": "foo", "tags": []interface{}{"a"},
+	}, m)
+
+	// Known fields take precedence.
+	out.Extra["id"] = msgpack.RawMessage{0x02}
+	b2, err = msgpack.Marshal(out)
+	require.Nil(t, err)
+	require.Nil(t, msgpack.Unmarshal(b2, &m))
+	require.Equal(t, int8(1), m["id"])
+	require.Len(t, m, 3)
+
+	// Embedded remain fields are inlined.
+	var emb RemainEmbedded
+	require.Nil(t, msgpack.Unmarshal(b, &emb))
+	require.Equal(t, "foo", emb.Name)
+	require.Equal(t, map[string]msgpack.RawMessage{"tags": {0x91, 0xa1, 'a'}}, emb.Extra)
+
+	// DisallowUnknownFields does not apply to structs with a remain field.
+	dec := msgpack.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields(true)
+	require.Nil(t, dec.Decode(&out))
+}
+
+func TestRemainOmitEmpty(t *testing.T) {
+	type remainOnly struct {
+		ID    int                    `msgpack:"id,omitempty"`
+		Extra map[string]interface{} `msgpack:",remain"`
+	}
+	type outer struct {
+		Inner remainOnly `msgpack:"inner,omitempty"`
+	}
+
+	tests := []struct {
+		extra map[string]interface{}
+		empty bool
+	}{
+		{nil, true},
+		{map[string]interface{}{}, true},
+		// Entries with the name of a field are not encoded.
+		{map[string]interface{}{"id": 1}, true},
+		{map[string]interface{}{"foo": 1}, false},
+	}
+	for _, test := range tests {
+		b, err := msgpack.Marshal(outer{Inner: remainOnly{Extra: test.extra}})
+		require.Nil(t, err)
+
+		var m map[string]interface{}
+		require.Nil(t, msgpack.Unmarshal(b, &m))
+		_, ok := m["inner"]
+		require.Equal(t, test.empty, !ok, "%v", test.extra)
+	}
+}
+
+func TestRemainCanonical(t *testing.T) {
+	type remainIface struct {
+		B     int                    `msgpack:"bb"`
+		Extra map[string]interface{} `msgpack:",remain"`
+	}
+	in := remainIface{B: 1, Extra: map[string]interface{}{"a": 2, "ccc": 3}}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseCanonicalEncoding(true)
+	require.Nil(t, enc.Encode(in))
+	require.Equal(t, []byte{
+		0x83, 0xa1, 'a', 0x02, 0xa2, 'b', 'b', 0x01, 0xa3, 'c', 'c', 'c', 0x03,
+	}, buf.Bytes())
+
+	var out remainIface
+	require.Nil(t, msgpack.Unmarshal(buf.Bytes(), &out))
+	require.Equal(t, remainIface{B: 1, Extra: map[string]interface{}{"a": int8(2), "ccc": int8(3)}}, out)
+}
+
+func TestRemainInvalid(t *testing.T) {
+	type invalidType struct {
+		Extra map[string]string `msgpack:",remain"`
+	}
+	require.PanicsWithError(t, "msgpack: remain option is not supported on map[string]string", func() {
+		_, _ = msgpack.Marshal(invalidType{})
+	})
+}
+
+type RequiredTest struct {
+	ID    int    `msgpack:"id,required"`
+	Name  string `msgpack:"name,required"`
+	Notes string `msgpack:"notes"`
+}
+
+type RequiredParent struct {
+	Child RequiredTest
+}
+
+func TestRequired(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]interface{}{"id": 1, "name": "foo"})
+	require.Nil(t, err)
+	var out RequiredTest
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, RequiredTest{ID: 1, Name: "foo"}, out)
+
+	// Zero values are not missing.
+	b, err = msgpack.Marshal(RequiredTest{})
+	require.Nil(t, err)
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+
+	// Nil is decoded as the zero struct.
+	b, err = msgpack.Marshal(nil)
+	require.Nil(t, err)
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+
+	b, err = msgpack.Marshal(map[string]interface{}{"notes": "foo"})
+	require.Nil(t, err)
+	err = msgpack.Unmarshal(b, &out)
+	require.EqualError(t, err,
+		`msgpack: msgpack_test.RequiredTest is missing required fields "id", "name"`)
+
+	var missing *msgpack.MissingFieldsError
+	require.True(t, errors.As(err, &missing))
+	require.Equal(t, []string{"id", "name"}, missing.Fields)
+
+	b, err = msgpack.Marshal(map[string]interface{}{
+		"Child": map[string]interface{}{"name": "foo"},
+	})
+	require.Nil(t, err)
+	var parent RequiredParent
+	err = msgpack.Unmarshal(b, &parent)
+	require.EqualError(t, err, "msgpack: decoding RequiredParent.Child at offset 7: "+
+		`msgpack_test.RequiredTest is missing required field "id"`)
+	require.True(t, errors.As(err, &missing))
+	require.Equal(t, []string{"id"}, missing.Fields)
+}
+
+func TestRequiredAsArray(t *testing.T) {
+	type requiredArray struct {
+		_msgpack struct{} `msgpack:",as_array"`
+		ID       int      `msgpack:",required"`
+	}
+
+	b, err := msgpack.Marshal([]interface{}{})
+	require.Nil(t, err)
+	var out requiredArray
+	err = msgpack.Unmarshal(b, &out)
+	require.EqualError(t, err, `msgpack: msgpack_test.requiredArray is missing required field "ID"`)
+}
+
+type DefaultsTest struct {
+	Retries int           `msgpack:"retries,default=3"`
+	Ratio   float64       `msgpack:"ratio,default:0.5"`
+	Enabled bool          `msgpack:"enabled,default=true"`
+	Name    string        `msgpack:"name,default:'a, b'"`
+	Timeout time.Duration `msgpack:"timeout,default=1m30s"`
+	Since   time.Time     `msgpack:"since,default=2020-01-02T03:04:05+01:00"`
+	Notes   string        `msgpack:"notes"`
+	*DefaultsEmbedded
+}
+
+type DefaultsEmbedded struct {
+	Port uint16 `msgpack:"port,default=8080"`
+}
+
+func TestDefaults(t *testing.T) {
+	b, err := msgpack.Marshal(map[string]interface{}{"retries": 0, "notes": "foo"})
+	require.Nil(t, err)
+
+	var out DefaultsTest
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, DefaultsTest{
+		Ratio:            0.5,
+		Enabled:          true,
+		Name:             "a, b",
+		Timeout:          90 * time.Second,
+		Since:            time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600)),
+		Notes:            "foo",
+		DefaultsEmbedded: &DefaultsEmbedded{Port: 8080},
+	}, out)
+
+	// Nil is decoded as the zero struct.
+	b, err = msgpack.Marshal(nil)
+	require.Nil(t, err)
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, DefaultsTest{}, out)
+
+	// Absent fields of a populated struct keep their values.
+	b, err = msgpack.Marshal(map[string]interface{}{"notes": "bar"})
+	require.Nil(t, err)
+	out = DefaultsTest{Retries: 5, Name: "c"}
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, 5, out.Retries)
+	require.Equal(t, "c", out.Name)
+	require.Equal(t, 0.5, out.Ratio)
+	require.Equal(t, "bar", out.Notes)
+}
+
+func TestDefaultsAsArray(t *testing.T) {
+	type defaultsArray struct {
+		_msgpack struct{} `msgpack:",as_array"`
+		ID       int      `msgpack:",required"`
+		Retries  int      `msgpack:",default=3"`
+		Name     string   `msgpack:",omitempty"`
+	}
+
+	b, err := msgpack.Marshal([]interface{}{1})
+	require.Nil(t, err)
+	var out defaultsArray
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, defaultsArray{ID: 1, Retries: 3}, out)
+
+	out = defaultsArray{Retries: 5, Name: "bar"}
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, defaultsArray{ID: 1, Retries: 5, Name: "bar"}, out)
+
+	b, err = msgpack.Marshal([]interface{}{1, 2, "foo"})
+	require.Nil(t, err)
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, defaultsArray{ID: 1, Retries: 2, Name: "foo"}, out)
+
+	b, err = msgpack.Marshal([]interface{}{})
+	require.Nil(t, err)
+	err = msgpack.Unmarshal(b, &out)
+	require.EqualError(t, err, `msgpack: msgpack_test.defaultsArray is missing required field "ID"`)
+
+	b, err = msgpack.Marshal([]interface{}{1, 2, "foo", 4})
+	require.Nil(t, err)
+	err = msgpack.Unmarshal(b, &out)
+	require.EqualError(t, err, "msgpack: number of fields in array-encoded struct has changed")
+
+	// Only optional fields may be missing.
+	type plainArray struct {
+		_msgpack struct{} `msgpack:",as_array"`
+		ID       int
+		Retries  int `msgpack:",default=3"`
+		Name     string
+	}
+	b, err = msgpack.Marshal([]interface{}{1, 2})
+	require.Nil(t, err)
+	var plain plainArray
+	err = msgpack.Unmarshal(b, &plain)
+	require.EqualError(t, err, "msgpack: number of fields in array-encoded struct has changed")
+}
+
+func TestDefaultsInvalid(t *testing.T) {
+	type invalidValue struct {
+		Retries int `msgpack:",default=many"`
+	}
+	require.PanicsWithError(t, `msgpack: invalid default value "many" for int`, func() {
+		_, _ = msgpack.Marshal(invalidValue{})
+	})
+
+	type invalidType struct {
+		Tags []string `msgpack:",default=a"`
+	}
+	require.PanicsWithError(t, "msgpack: default option is not supported on []string", func() {
+		_, _ = msgpack.Marshal(invalidType{})
+	})
+
+	type invalidRequired struct {
+		ID int `msgpack:",required,default=1"`
+	}
+	require.PanicsWithError(t,
+		"msgpack: required field msgpack_test.invalidRequired.ID can't have a default value",
+		func() { _, _ = msgpack.Marshal(invalidRequired{}) })
+}
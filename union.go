@@ -0,0 +1,207 @@
+package msgpack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// unionEncoder returns the encoder of interface fields with the union=key
+// tag option. The value is encoded as a map of the struct fields with the key
+// set to the type name registered with RegisterName,
+// e.g. {"type": "circle", "r": 2}.
+func unionEncoder(key string) encoderFunc {
+	return func(e *Encoder, v reflect.Value) error {
+		if v.IsNil() {
+			return e.EncodeNil()
+		}
+		return e.encodeUnion(key, v.Elem())
+	}
+}
+
+// unionDecoder returns the decoder of interface fields with the union=key
+// tag option. The key may be anywhere in the map, so maps produced by other
+// languages can be decoded too.
+func unionDecoder(key string) decoderFunc {
+	return func(d *Decoder, v reflect.Value) error {
+		return d.decodeUnion(key, v)
+	}
+}
+
+// EncodeUnion encodes v the same way as an interface field with
+// the union=key tag option.
+func (e *Encoder) EncodeUnion(key string, v interface{}) error {
+	if v == nil {
+		return e.EncodeNil()
+	}
+	return e.encodeUnion(key, reflect.ValueOf(v))
+}
+
+func (e *Encoder) encodeUnion(key string, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return e.EncodeNil()
+	}
+	name, ok := e.registry().valueTypeName(v)
+	if !ok {
+		return fmt.Errorf("msgpack: union type %s is not registered with RegisterName", v.Type())
+	}
+
+	strct := v
+	if strct.Kind() == reflect.Ptr {
+		strct = strct.Elem()
+	}
+	if strct.Kind() != reflect.Struct {
+		return fmt.Errorf("msgpack: union type %s is not a struct", v.Type())
+	}
+
+	structFields := e.registry().structFields(strct.Type(), e.structTag)
+	if _, ok := structFields.Map[key]; ok {
+		return fmt.Errorf("msgpack: union type %s already has field=%s", v.Type(), key)
+	}
+
+	if err := e.enter(); err != nil {
+		return err
+	}
+	defer e.leave()
+
+	head := []structEntry{{name: key, value: reflect.ValueOf(name)}}
+	return e.encodeStructMap(strct, structFields, head)
+}
+
+// DecodeUnion decodes into v, which must be a pointer to an interface,
+// the same way as an interface field with the union=key tag option.
+func (d *Decoder) DecodeUnion(key string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Interface {
+		return fmt.Errorf("msgpack: DecodeUnion(non-pointer to interface %T)", v)
+	}
+	return d.decodeUnion(key, rv.Elem())
+}
+
+func (d *Decoder) decodeUnion(key string, v reflect.Value) error {
+	if d.hasNilCode() {
+		if !v.IsNil() {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return d.DecodeNil()
+	}
+
+	// The map is decoded twice: to find the type name and then into the struct.
+	offset := d.InputOffset()
+	raw, err := d.readRaw(0)
+	if err != nil {
+		return err
+	}
+
+	sub := d.subDecoder(raw)
+	name, err := sub.unionName(key)
+	PutDecoder(sub)
+	if err != nil {
+		return err
+	}
+
+	typ, ok := d.registry().nameType(name)
+	if !ok {
+		return fmt.Errorf("msgpack: type name %q is not registered", name)
+	}
+	// As with type names, the interface may require a pointer
+	// to the registered type or the value it points to.
+	assignable := typ.AssignableTo(v.Type())
+	if !assignable && !assignableIndirect(typ, v.Type()) {
+		return fmt.Errorf("msgpack: cannot decode %s into %s", typ, v.Type())
+	}
+
+	elem := d.newValue(typ).Elem()
+	strct := elem
+	if strct.Kind() == reflect.Ptr {
+		strct.Set(d.newValue(typ.Elem()))
+		strct = strct.Elem()
+	}
+	if strct.Kind() != reflect.Struct {
+		return fmt.Errorf("msgpack: union type %s is not a struct", typ)
+	}
+
+	sub = d.subDecoder(raw)
+	err = sub.decodeUnionFields(key, strct)
+	d.dict = sub.dict
+	PutDecoder(sub)
+	if err != nil {
+		if de, ok := err.(*DecodeError); ok {
+			de.Offset += offset
+		}
+		return err
+	}
+
+	if !assignable {
+		elem, _ = pointerOrElem(elem, v.Type())
+	}
+	v.Set(elem)
+	return nil
+}
+
+// assignableIndirect reports whether a pointer to typ or the type typ points
+// to is assignable to the interface type.
+func assignableIndirect(typ, iface reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		return typ.Elem().AssignableTo(iface)
+	}
+	return reflect.PtrTo(typ).AssignableTo(iface)
+}
+
+func (d *Decoder) unionName(key string) (string, error) {
+	n, err := d.DecodeMapLen()
+	if err != nil {
+		return "", err
+	}
+	for i := 0; i < n; i++ {
+		name, err := d.decodeStringTemp()
+		if err != nil {
+			return "", err
+		}
+		if name == key {
+			return d.DecodeString()
+		}
+		if err := d.Skip(); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("msgpack: union key %q is missing", key)
+}
+
+func (d *Decoder) decodeUnionFields(key string, strct reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+
+	n, err := d.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+
+	fields := d.registry().structFields(strct.Type(), d.structTag)
+	var seen []bool
+	if len(fields.tracked) > 0 {
+		seen = make([]bool, len(fields.tracked))
+	}
+
+	for i := 0; i < n; i++ {
+		name, err := d.decodeStringTemp()
+		if err != nil {
+			return err
+		}
+		if name == key {
+			if _, err := d.DecodeString(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decodeStructField(strct, fields, name, seen); err != nil {
+			return err
+		}
+	}
+
+	if seen != nil {
+		return fields.setAbsent(strct, seen)
+	}
+	return nil
+}
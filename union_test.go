@@ -0,0 +1,159 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type UnionTest struct {
+	Shape  Shape   `msgpack:"shape,union=type"`
+	Shapes []Shape `msgpack:",omitempty"`
+	Other  Shape   `msgpack:",union:kind,omitempty"`
+}
+
+type Triangle struct {
+	Base   float64 `msgpack:"base"`
+	Height float64 `msgpack:"height,omitempty"`
+}
+
+func (t Triangle) Area() float64 { return t.Base * t.Height / 2 }
+
+func init() {
+	msgpack.RegisterName("triangle", Triangle{})
+}
+
+func TestUnion(t *testing.T) {
+	in := UnionTest{Shape: Triangle{Base: 2, Height: 3}, Other: &Square{Side: 4}}
+	b, err := msgpack.Marshal(in)
+	require.Nil(t, err)
+
+	var m map[string]interface{}
+	require.Nil(t, msgpack.Unmarshal(b, &m))
+	require.Equal(t, map[string]interface{}{
+		"shape": map[string]interface{}{"type": "triangle", "base": 2.0, "height": 3.0},
+		"Other": map[string]interface{}{"kind": "square", "Side": 4.0},
+	}, m)
+
+	var out UnionTest
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, in, out)
+
+	// The key may be anywhere in the map.
+	b, err = msgpack.Marshal(map[string]interface{}{
+		"shape": map[string]interface{}{"base": 1.0, "type": "triangle", "extra": []int{1}},
+	})
+	require.Nil(t, err)
+	out = UnionTest{}
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, UnionTest{Shape: Triangle{Base: 1}}, out)
+
+	// Nil interfaces are encoded as nil.
+	b, err = msgpack.Marshal(UnionTest{})
+	require.Nil(t, err)
+	out = UnionTest{Shape: Triangle{}}
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, UnionTest{}, out)
+}
+
+func TestUnionCanonical(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseCanonicalEncoding(true)
+	require.Nil(t, enc.EncodeUnion("type", Triangle{Base: 1, Height: 2}))
+
+	dec := msgpack.NewDecoder(bytes.NewReader(buf.Bytes()))
+	var keys []string
+	n, err := dec.DecodeMapLen()
+	require.Nil(t, err)
+	for i := 0; i < n; i++ {
+		key, err := dec.DecodeString()
+		require.Nil(t, err)
+		keys = append(keys, key)
+		require.Nil(t, dec.Skip())
+	}
+	require.Equal(t, []string{"base", "type", "height"}, keys)
+
+	var shape Shape
+	dec = msgpack.NewDecoder(bytes.NewReader(buf.Bytes()))
+	require.Nil(t, dec.DecodeUnion("type", &shape))
+	require.Equal(t, Triangle{Base: 1, Height: 2}, shape)
+}
+
+func TestUnionPointer(t *testing.T) {
+	// Triangle is registered as a value and Square as a pointer,
+	// but a pointer and the type it points to share the name.
+	in := UnionTest{Shape: &Triangle{Base: 2}, Other: &Square{Side: 4}}
+	b, err := msgpack.Marshal(in)
+	require.Nil(t, err)
+
+	var out UnionTest
+	require.Nil(t, msgpack.Unmarshal(b, &out))
+	require.Equal(t, UnionTest{Shape: Triangle{Base: 2}, Other: &Square{Side: 4}}, out)
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	require.Nil(t, enc.EncodeUnion("type", Square{Side: 3}))
+
+	var v interface{}
+	dec := msgpack.NewDecoder(bytes.NewReader(buf.Bytes()))
+	require.Nil(t, dec.DecodeUnion("type", &v))
+	require.Equal(t, &Square{Side: 3}, v)
+
+	// Shape requires a pointer to the registered Square.
+	r := msgpack.NewRegistry()
+	r.RegisterName("square", Square{})
+
+	var shape Shape
+	dec = msgpack.NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.SetRegistry(r)
+	require.Nil(t, dec.DecodeUnion("type", &shape))
+	require.Equal(t, &Square{Side: 3}, shape)
+}
+
+type Hexagon struct{}
+
+func (Hexagon) Area() float64 { return 0 }
+
+func TestUnionErrors(t *testing.T) {
+	_, err := msgpack.Marshal(UnionTest{Shape: Hexagon{}})
+	require.EqualError(t, err,
+		"msgpack: union type msgpack_test.Hexagon is not registered with RegisterName")
+
+	tests := []struct {
+		in  map[string]interface{}
+		err string
+	}{
+		{
+			map[string]interface{}{"base": 1},
+			`msgpack: decoding UnionTest.Shape at offset 7: union key "type" is missing`,
+		},
+		{
+			map[string]interface{}{"type": "hexagon"},
+			`msgpack: decoding UnionTest.Shape at offset 7: type name "hexagon" is not registered`,
+		},
+		{
+			map[string]interface{}{"type": "triangle", "base": "wide"},
+			`msgpack: decoding UnionTest.Shape.Base at offset 13: invalid code=a4 decoding float64`,
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetSortMapKeys(true)
+		require.Nil(t, enc.Encode(map[string]interface{}{"shape": test.in}))
+		var out UnionTest
+		err = msgpack.Unmarshal(buf.Bytes(), &out)
+		require.EqualError(t, err, test.err)
+	}
+
+	type invalidType struct {
+		Shape Triangle `msgpack:",union=type"`
+	}
+	require.PanicsWithError(t, "msgpack: union option is not supported on msgpack_test.Triangle", func() {
+		_, _ = msgpack.Marshal(invalidType{})
+	})
+}
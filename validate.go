@@ -0,0 +1,278 @@
+package msgpack
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// Valid reports whether data holds exactly one well-formed MessagePack value.
+// It returns nil on success and otherwise a *DecodeError with the offset
+// of the first problem. See Decoder.Validate for the checks performed.
+func Valid(data []byte) error {
+	return ValidMulti(data, 1)
+}
+
+// ValidMulti is like Valid, but data must hold exactly n concatenated values.
+// A negative n accepts any number of values, including none.
+func ValidMulti(data []byte, n int) error {
+	d := GetDecoder()
+	d.Reset(nil)
+	d.ResetBytes(data, true)
+
+	err := d.validateMulti(n)
+
+	PutDecoder(d)
+
+	return err
+}
+
+func (d *Decoder) validateMulti(n int) error {
+	for i := 0; n < 0 || i < n; i++ {
+		if d.pos == len(d.data) {
+			if n < 0 {
+				return nil
+			}
+			return validateError(int64(d.pos), -1, "missing value", io.ErrUnexpectedEOF)
+		}
+		if err := d.Validate(); err != nil {
+			return err
+		}
+	}
+	if d.pos < len(d.data) {
+		return validateError(int64(d.pos), -1, "unexpected data after top-level value", nil)
+	}
+	return nil
+}
+
+// Validate reads the next value from the input and checks that it is
+// well-formed without decoding it: every code is defined, str, bin and ext
+// bodies and array and map elements are complete and lengths fit in the
+// input. Validate enforces the limits set with SetLimits.
+//
+// Unlike Skip, Validate never allocates memory proportionally to the lengths
+// found in the input, so it is suitable for checking untrusted data before
+// decoding it. Problems are reported as *DecodeError with the offset of the
+// offending value. Validate returns io.EOF if the input is exhausted.
+func (d *Decoder) Validate() error {
+	if _, err := d.PeekCode(); err != nil {
+		return err
+	}
+
+	depth := d.depth
+	defer func() {
+		d.depth = depth
+	}()
+
+	// stack holds the number of values left in the open arrays and maps.
+	var buf [16]int64
+	stack := buf[:0]
+	for {
+		kind := "value"
+		if len(stack) > 0 {
+			kind = "element"
+		}
+
+		offset := d.InputOffset()
+		n, err := d.validateValue(offset, kind)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			if err := d.enter(); err != nil {
+				return invalidValue(offset, "value", err)
+			}
+			stack = append(stack, n)
+			continue
+		}
+
+		for len(stack) > 0 {
+			stack[len(stack)-1]--
+			if stack[len(stack)-1] > 0 {
+				break
+			}
+			stack = stack[:len(stack)-1]
+			d.leave()
+		}
+		if len(stack) == 0 {
+			return nil
+		}
+	}
+}
+
+// validateValue checks the next value except for the elements of arrays
+// and maps and returns the number of values the elements consist of.
+// Negative lengths are 32-bit lengths that overflow int on 32-bit platforms;
+// they can't fit in the input, so they are reported as truncated.
+func (d *Decoder) validateValue(offset int64, kind string) (int64, error) {
+	c, err := d.readCode()
+	if err != nil {
+		if err == io.EOF {
+			return 0, validateError(offset, -1, "missing "+kind, io.ErrUnexpectedEOF)
+		}
+		return 0, invalidValue(offset, kind, err)
+	}
+
+	switch {
+	case msgpcode.IsFixedNum(c):
+		return 0, nil
+	case msgpcode.IsFixedMap(c), c == msgpcode.Map16, c == msgpcode.Map32:
+		n, err := d.parseMapLen(c)
+		if err == nil && n < 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil {
+			err = d.checkLen("MaxMapLen", d.limits.MaxMapLen, n)
+		}
+		if err != nil {
+			return 0, invalidValue(offset, "map", err)
+		}
+		return d.validateLen(offset, "map", n, 2)
+	case msgpcode.IsFixedArray(c), c == msgpcode.Array16, c == msgpcode.Array32:
+		n, err := d.parseArrayLen(c)
+		if err == nil && n < 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil {
+			err = d.checkLen("MaxArrayLen", d.limits.MaxArrayLen, n)
+		}
+		if err != nil {
+			return 0, invalidValue(offset, "array", err)
+		}
+		return d.validateLen(offset, "array", n, 1)
+	case msgpcode.IsString(c), msgpcode.IsBin(c):
+		kind := "str"
+		if msgpcode.IsBin(c) {
+			kind = "bin"
+		}
+		n, err := d.parseBytesLen(c)
+		if err == nil && n < 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil {
+			err = d.checkLen("MaxStringLen", d.limits.MaxStringLen, n)
+		}
+		if err == nil {
+			err = d.discard(n)
+		}
+		if err != nil {
+			return 0, invalidValue(offset, kind, err)
+		}
+		return 0, nil
+	case msgpcode.IsExt(c):
+		n, err := d.parseExtLen(c)
+		if err == nil && n < 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil {
+			err = d.checkLen("MaxStringLen", d.limits.MaxStringLen, n)
+		}
+		if err == nil {
+			// The body is preceded by the ext type.
+			err = d.discard(1)
+		}
+		if err == nil {
+			err = d.discard(n)
+		}
+		if err != nil {
+			return 0, invalidValue(offset, "ext", err)
+		}
+		return 0, nil
+	}
+
+	var size int
+	switch c {
+	case msgpcode.Nil, msgpcode.False, msgpcode.True:
+		return 0, nil
+	case msgpcode.Uint8, msgpcode.Int8:
+		size = 1
+	case msgpcode.Uint16, msgpcode.Int16:
+		size = 2
+	case msgpcode.Uint32, msgpcode.Int32, msgpcode.Float:
+		size = 4
+	case msgpcode.Uint64, msgpcode.Int64, msgpcode.Double:
+		size = 8
+	default:
+		return 0, validateError(offset, int(c), fmt.Sprintf("invalid code=%x", c), nil)
+	}
+	if err := d.discard(size); err != nil {
+		return 0, invalidValue(offset, "number", err)
+	}
+	return 0, nil
+}
+
+// validateLen returns the number of values in an array or a map with n
+// entries of per values. Every value takes at least one byte, so it fails
+// early if the input can't hold them.
+func (d *Decoder) validateLen(offset int64, kind string, n, per int) (int64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	values := int64(n) * int64(per)
+	if d.data != nil {
+		if left := int64(len(d.data) - d.pos); values > left {
+			msg := fmt.Sprintf("%s len=%d exceeds the remaining %d bytes", kind, n, left)
+			return 0, validateError(offset, -1, msg, io.ErrUnexpectedEOF)
+		}
+	}
+	return values, nil
+}
+
+// discard skips the next n bytes without allocating a buffer for them.
+func (d *Decoder) discard(n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if err := d.checkBytes(n); err != nil {
+		return err
+	}
+	if d.data != nil {
+		if n > len(d.data)-d.pos {
+			d.pos = len(d.data)
+			return io.ErrUnexpectedEOF
+		}
+		d.pos += n
+		return nil
+	}
+	m, err := io.CopyN(io.Discard, d.r, int64(n))
+	d.off += m
+	return err
+}
+
+// invalidValue reports an error reading the value of the kind at offset.
+// Running out of input is reported as a truncated value.
+func invalidValue(offset int64, kind string, err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return validateError(offset, -1, "truncated "+kind, io.ErrUnexpectedEOF)
+	}
+	return validateError(offset, -1, kind, err)
+}
+
+// validateError returns a *DecodeError for a malformed value at offset.
+func validateError(offset int64, code int, msg string, err error) error {
+	return &DecodeError{
+		Offset: offset,
+		Code:   code,
+		Err:    &malformedError{offset: offset, msg: msg, err: err},
+	}
+}
+
+type malformedError struct {
+	offset int64
+	msg    string
+	err    error
+}
+
+func (e *malformedError) Error() string {
+	s := fmt.Sprintf("msgpack: %s at offset %d", e.msg, e.offset)
+	if e.err != nil {
+		s += ": " + strings.TrimPrefix(e.err.Error(), "msgpack: ")
+	}
+	return s
+}
+
+func (e *malformedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,136 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func requireValidateError(t *testing.T, err error, offset int64, msg string) *msgpack.DecodeError {
+	var derr *msgpack.DecodeError
+	require.True(t, errors.As(err, &derr), "got %v", err)
+	require.Equal(t, offset, derr.Offset)
+	require.EqualError(t, err, msg)
+	return derr
+}
+
+func TestValid(t *testing.T) {
+	values := []interface{}{
+		nil, true, -1, 300, uint64(1 << 40), 1.5, float32(2.5), "hello", []byte{1, 2},
+		[]interface{}{1, "a", []int{}}, map[string]interface{}{"a": map[int]bool{1: true}},
+		time.Unix(1, 1),
+	}
+	for _, v := range values {
+		b, err := msgpack.Marshal(v)
+		require.Nil(t, err)
+		require.Nil(t, msgpack.Valid(b), "%#v", v)
+	}
+}
+
+func TestValidInvalid(t *testing.T) {
+	type test struct {
+		data   []byte
+		offset int64
+		msg    string
+	}
+	tests := []test{
+		{nil, 0, "msgpack: missing value at offset 0: unexpected EOF"},
+		{[]byte{0x92, 0x01, 0xc1}, 2, "msgpack: invalid code=c1 at offset 2"},
+		{[]byte{0x91, 0xa3, 'a', 'b'}, 1, "msgpack: truncated str at offset 1: unexpected EOF"},
+		{[]byte{0xc5, 0x01}, 0, "msgpack: truncated bin at offset 0: unexpected EOF"},
+		{[]byte{0xd6, 0xff, 0, 0}, 0, "msgpack: truncated ext at offset 0: unexpected EOF"},
+		{[]byte{0xcd, 0x01}, 0, "msgpack: truncated number at offset 0: unexpected EOF"},
+		{[]byte{0x82, 0xa1, 'a', 0x01, 0xa1}, 4, "msgpack: truncated str at offset 4: unexpected EOF"},
+		{[]byte{0x93, 0xcd, 0, 1, 0x01}, 5, "msgpack: missing element at offset 5: unexpected EOF"},
+		{
+			[]byte{0xdc, 0xff, 0xff, 0x01}, 0,
+			"msgpack: array len=65535 exceeds the remaining 1 bytes at offset 0: unexpected EOF",
+		},
+		{[]byte{0x01, 0x02}, 1, "msgpack: unexpected data after top-level value at offset 1"},
+	}
+	if strconv.IntSize == 64 {
+		tests = append(tests, test{
+			[]byte{0xdd, 0xff, 0xff, 0xff, 0xff, 0x01}, 0,
+			"msgpack: array len=4294967295 exceeds the remaining 1 bytes at offset 0: unexpected EOF",
+		})
+	}
+	for _, test := range tests {
+		err := msgpack.Valid(test.data)
+		requireValidateError(t, err, test.offset, test.msg)
+	}
+
+	err := msgpack.Valid([]byte{0xc1})
+	derr := requireValidateError(t, err, 0, "msgpack: invalid code=c1 at offset 0")
+	require.Equal(t, 0xc1, derr.Code)
+
+	err = msgpack.Valid([]byte{0xa2, 'a'})
+	require.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+func TestValidMulti(t *testing.T) {
+	data := []byte{0x01, 0xa1, 'a', 0x90}
+	require.Nil(t, msgpack.ValidMulti(data, 3))
+	require.Nil(t, msgpack.ValidMulti(data, -1))
+	require.Nil(t, msgpack.ValidMulti(nil, -1))
+
+	err := msgpack.ValidMulti(data, 4)
+	requireValidateError(t, err, 4, "msgpack: missing value at offset 4: unexpected EOF")
+
+	err = msgpack.ValidMulti(data, 2)
+	requireValidateError(t, err, 3, "msgpack: unexpected data after top-level value at offset 3")
+}
+
+func TestValidAllocs(t *testing.T) {
+	inputs := [][]byte{
+		// Claims a 2GB ext body.
+		{0xc9, 0x7f, 0xff, 0xff, 0xff, 0x01, 0x02},
+	}
+	if strconv.IntSize == 64 {
+		// Claims a 4GB ext body, which doesn't fit in int on 32-bit platforms.
+		inputs = append(inputs, []byte{0xc9, 0xff, 0xff, 0xff, 0xff, 0x01, 0x02})
+	}
+
+	for _, data := range inputs {
+		allocs := testing.AllocsPerRun(10, func() {
+			_ = msgpack.Valid(data)
+		})
+		require.LessOrEqual(t, allocs, 5.0)
+
+		dec := msgpack.NewDecoder(bytes.NewReader(data))
+		err := dec.Validate()
+		require.True(t, errors.Is(err, io.ErrUnexpectedEOF), "got %v", err)
+	}
+}
+
+func TestDecoderValidate(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	require.Nil(t, enc.Encode(map[string]interface{}{"a": []int{1, 2}}))
+	require.Nil(t, enc.Encode("next"))
+
+	dec := msgpack.NewDecoder(&buf)
+	require.Nil(t, dec.Validate())
+	s, err := dec.DecodeString()
+	require.Nil(t, err)
+	require.Equal(t, "next", s)
+	require.Equal(t, io.EOF, dec.Validate())
+}
+
+func TestDecoderValidateLimits(t *testing.T) {
+	dec := msgpack.NewDecoder(bytes.NewReader(nestedArrays(3)))
+	dec.SetLimits(msgpack.DecoderLimits{MaxDepth: 2})
+	err := dec.Validate()
+	requireLimitError(t, err, "MaxDepth")
+	requireValidateError(t, err, 2, "msgpack: value at offset 2: MaxDepth limit exceeded: 3 > 2")
+
+	dec = msgpack.NewDecoder(bytes.NewReader([]byte{0xa3, 'a', 'b', 'c'}))
+	dec.SetLimits(msgpack.DecoderLimits{MaxStringLen: 2})
+	requireLimitError(t, dec.Validate(), "MaxStringLen")
+}